@@ -0,0 +1,157 @@
+package chaos
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	k8sErrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+)
+
+// ErrNotSupported experiment or operation is not supported by the backend
+var ErrNotSupported = errors.New("not supported by chaos backend")
+
+// Backend applies experiments rendered as Chaosmesh resources to the cluster
+type Backend interface {
+	// Create applies experiment
+	Create(payload *CRDPayload) error
+	// Delete removes experiment and recovers its targets
+	Delete(info *ExperimentInfo) error
+	// Status reads experiment status
+	Status(info *ExperimentInfo) (*ExperimentStatus, error)
+	// List discovers experiments created by helmenv in the namespace
+	List() ([]*ExperimentInfo, error)
+	// SetPaused pauses or resumes experiment
+	SetPaused(info *ExperimentInfo, paused bool) error
+}
+
+// ChaosMeshBackend backend creating Chaosmesh CRD instances through the REST API
+type ChaosMeshBackend struct {
+	Client *kubernetes.Clientset
+	// RESTClient client for Chaosmesh API calls, defaults to the REST client of Client
+	RESTClient rest.Interface
+	Namespace  string
+	// Backoff retries of transient API server failures, defaults to DefaultRetryBackoff
+	Backoff wait.Backoff
+}
+
+func (b *ChaosMeshBackend) restClient() rest.Interface {
+	if b.RESTClient != nil {
+		return b.RESTClient
+	}
+	return b.Client.RESTClient()
+}
+
+// Create creates experiment CRD instance
+func (b *ChaosMeshBackend) Create(payload *CRDPayload) error {
+	_, err := b.do(http.MethodPost, payload.Resource, payload.Name, func() *rest.Request {
+		return b.restClient().
+			Post().
+			AbsPath(APIBasePath).
+			Name(payload.Name).
+			Namespace(b.Namespace).
+			Resource(payload.Resource).
+			Body(payload.Data)
+	})
+	return err
+}
+
+// Delete deletes experiment CRD instance
+func (b *ChaosMeshBackend) Delete(info *ExperimentInfo) error {
+	_, err := b.do(http.MethodDelete, info.Resource, info.Name, func() *rest.Request {
+		return b.restClient().
+			Delete().
+			AbsPath(APIBasePath).
+			Name(info.Name).
+			Resource(info.Resource).
+			Namespace(b.Namespace)
+	})
+	return err
+}
+
+// Status reads status of experiment CRD instance
+func (b *ChaosMeshBackend) Status(info *ExperimentInfo) (*ExperimentStatus, error) {
+	raw, err := b.do(http.MethodGet, info.Resource, info.Name, func() *rest.Request {
+		return b.restClient().
+			Get().
+			AbsPath(APIBasePath).
+			Namespace(b.Namespace).
+			Resource(info.Resource).
+			Name(info.Name)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return parseStatus(info, raw)
+}
+
+// List lists experiments labelled by helmenv in every resource, resources which CRDs are not installed are skipped
+func (b *ChaosMeshBackend) List() ([]*ExperimentInfo, error) {
+	selector := labels.SelectorFromSet(labels.Set{ManagedByLabelKey: ManagedByLabelValue}).String()
+	infos := make([]*ExperimentInfo, 0)
+	for _, resource := range Resources {
+		resource := resource
+		raw, err := b.do(http.MethodGet, resource, "", func() *rest.Request {
+			return b.restClient().
+				Get().
+				AbsPath(APIBasePath).
+				Namespace(b.Namespace).
+				Resource(resource).
+				Param("labelSelector", selector)
+		})
+		if k8sErrors.IsNotFound(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		var list crdList
+		if err := json.Unmarshal(raw, &list); err != nil {
+			return nil, err
+		}
+		for _, item := range list.Items {
+			infos = append(infos, &ExperimentInfo{
+				Name:     item.Metadata.Name,
+				Resource: resource,
+				RunID:    item.Metadata.Labels[RunIDLabelKey],
+				Created:  created(item.Metadata.CreationTimestamp),
+			})
+		}
+	}
+	return infos, nil
+}
+
+// SetPaused sets or removes pause annotation of experiment
+func (b *ChaosMeshBackend) SetPaused(info *ExperimentInfo, paused bool) error {
+	if info.Resource == "workflows" {
+		return fmt.Errorf("experiment %s: workflows can't be paused", info.Name)
+	}
+	var value interface{}
+	if paused {
+		value = "true"
+	}
+	patch, err := json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"annotations": map[string]interface{}{PauseAnnotation: value},
+		},
+	})
+	if err != nil {
+		return err
+	}
+	_, err = b.do(http.MethodPatch, info.Resource, info.Name, func() *rest.Request {
+		return b.restClient().
+			Patch(types.MergePatchType).
+			AbsPath(APIBasePath).
+			Namespace(b.Namespace).
+			Resource(info.Resource).
+			Name(info.Name).
+			Body(patch)
+	})
+	return err
+}
@@ -0,0 +1,198 @@
+package chaos
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+
+	"github.com/ghodss/yaml"
+	"github.com/goplugin/helmenv/chaos/experiments"
+)
+
+// embeddedChaos experiment rendered for embedding into a schedule or a workflow step
+type embeddedChaos struct {
+	Kind string
+	// Key spec key of the kind, for example networkChaos
+	Key string
+	// Spec experiment spec as inline JSON
+	Spec string
+}
+
+type scheduleData struct {
+	*experiments.Schedule
+	Embedded embeddedChaos
+}
+
+type workflowTemplate struct {
+	Name     string
+	Type     string
+	Deadline string
+	Children []string
+	Embedded *embeddedChaos
+}
+
+type workflowData struct {
+	*experiments.Workflow
+	Templates []workflowTemplate
+}
+
+// templateData returns data the experiment template is rendered with,
+// experiments nested into schedules and workflows are rendered beforehand
+func (c *Controller) templateData(exp Experimentable) (interface{}, error) {
+	switch e := exp.(type) {
+	case *experiments.Schedule:
+		if e.Experiment == nil {
+			return nil, fmt.Errorf("schedule %s has no experiment", e.Name)
+		}
+		embedded, err := c.embed(experiments.Base{Name: e.Name, Namespace: e.Namespace}, e.Experiment)
+		if err != nil {
+			return nil, err
+		}
+		return scheduleData{Schedule: e, Embedded: *embedded}, nil
+	case *experiments.Workflow:
+		if len(e.Steps) == 0 {
+			return nil, fmt.Errorf("workflow %s has no steps", e.Name)
+		}
+		data := workflowData{Workflow: e}
+		if len(e.Entry) == 0 {
+			e.Entry = e.Steps[0].Name
+		}
+		for _, step := range e.Steps {
+			tmpl := workflowTemplate{Name: step.Name, Type: step.Type, Children: step.Children}
+			if step.Deadline > 0 {
+				tmpl.Deadline = step.Deadline.String()
+			}
+			if step.Experiment != nil {
+				embedded, err := c.embed(experiments.Base{Name: e.Name, Namespace: e.Namespace}, step.Experiment)
+				if err != nil {
+					return nil, err
+				}
+				tmpl.Type = embedded.Kind
+				tmpl.Embedded = embedded
+			}
+			data.Templates = append(data.Templates, tmpl)
+		}
+		return data, nil
+	default:
+		return exp, nil
+	}
+}
+
+// embed renders an experiment nested into a schedule or a workflow, only kind and spec are kept
+func (c *Controller) embed(base experiments.Base, exp Experimentable) (*embeddedChaos, error) {
+	exp.SetBase(base)
+	data, err := c.render(exp)
+	if err != nil {
+		return nil, err
+	}
+	var crd struct {
+		Kind string          `json:"kind"`
+		Spec json.RawMessage `json:"spec"`
+	}
+	if err := json.Unmarshal(data, &crd); err != nil {
+		return nil, err
+	}
+	if len(crd.Kind) == 0 || len(crd.Spec) == 0 {
+		return nil, fmt.Errorf("chaos template %s must have kind and spec", exp.Filename())
+	}
+	return &embeddedChaos{Kind: crd.Kind, Key: specKey(crd.Kind), Spec: string(crd.Spec)}, nil
+}
+
+// render validates experiment and renders it as JSON, experiments are built directly unless
+// their template is overridden, other experiments are rendered from templates
+func (c *Controller) render(exp Experimentable) ([]byte, error) {
+	if b, ok := exp.(experiments.Builder); ok {
+		if c.templateOverridden(exp.Filename()) {
+			// fields are up to the user template
+			return c.renderTemplate(exp)
+		}
+		return experiments.Marshal(b)
+	}
+	if v, ok := exp.(experiments.Validator); ok {
+		if err := v.Validate(); err != nil {
+			return nil, err
+		}
+	}
+	return c.renderTemplate(exp)
+}
+
+// renderTemplate renders experiment template as JSON
+func (c *Controller) renderTemplate(exp Experimentable) ([]byte, error) {
+	fileBytes, err := c.readTemplate(exp.Filename())
+	if err != nil {
+		return nil, err
+	}
+	data, err := c.templateData(exp)
+	if err != nil {
+		return nil, err
+	}
+	d, err := marshallTemplate(data, "Chaos template", string(fileBytes))
+	if err != nil {
+		return nil, err
+	}
+	return yaml.YAMLToJSON([]byte(d))
+}
+
+// specKey returns spec field name of a kind in schedules and workflows, for example IOChaos is ioChaos
+func specKey(kind string) string {
+	runes := []rune(kind)
+	upper := 0
+	for upper < len(runes) && unicode.IsUpper(runes[upper]) {
+		upper++
+	}
+	// the last capital of an abbreviation starts the next word
+	if upper > 1 && upper < len(runes) {
+		upper--
+	}
+	return strings.ToLower(string(runes[:upper])) + string(runes[upper:])
+}
+
+// setTemplateSelector fills in selector of a template experiment, or of the experiment scheduled by a template
+func setTemplateSelector(tmplMap map[string]interface{}, target *experiments.Selector) error {
+	spec, ok := tmplMap["spec"].(map[string]interface{})
+	if !ok {
+		return errors.New("template has no spec")
+	}
+	switch tmplMap["kind"] {
+	case "Workflow":
+		return errors.New("workflow steps have their own selectors, target can't be set")
+	case "Schedule":
+		kind, _ := spec["type"].(string)
+		if spec, ok = spec[specKey(kind)].(map[string]interface{}); !ok {
+			return fmt.Errorf("schedule has no %s spec", specKey(kind))
+		}
+	}
+	targetSpec, err := target.Merge("", "", "", "").Spec()
+	if err != nil {
+		return err
+	}
+	selector, _ := spec["selector"].(map[string]interface{})
+	if selector == nil {
+		selector = map[string]interface{}{}
+	}
+	var targetSelector map[string]interface{}
+	if err := json.Unmarshal([]byte(targetSpec), &targetSelector); err != nil {
+		return err
+	}
+	for k, v := range targetSelector {
+		existing, isMap := selector[k].(map[string]interface{})
+		values, ok := v.(map[string]interface{})
+		if !isMap || !ok {
+			selector[k] = v
+			continue
+		}
+		for vk, vv := range values {
+			existing[vk] = vv
+		}
+	}
+	spec["selector"] = selector
+	if len(target.Mode) > 0 {
+		spec["mode"] = target.Mode
+	}
+	if len(target.Value) > 0 {
+		spec["value"] = target.Value
+	}
+	return nil
+}
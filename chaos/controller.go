@@ -2,18 +2,24 @@ package chaos
 
 import (
 	"bytes"
-	"context"
+	"embed"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io/fs"
 	"io/ioutil"
-	"path/filepath"
+	"path"
+	"sync"
 	"text/template"
+	"time"
 
 	"github.com/goplugin/helmenv/chaos/experiments"
-	"github.com/goplugin/helmenv/tools"
 
 	"github.com/ghodss/yaml"
 	"github.com/rs/zerolog/log"
 	uuid "github.com/satori/go.uuid"
+	k8sErrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/util/wait"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
 )
@@ -21,8 +27,16 @@ import (
 const (
 	// APIBasePath in form of /apis/<spec.group>/<spec.versions.name>, see Chaosmesh CRD 2.0.0
 	APIBasePath = "/apis/chaos-mesh.org/v1alpha1"
-	// TemplatesPath path to the chaos templates
+	// TemplatesPath path to the chaos templates in the project, templates are embedded into TemplatesFS
 	TemplatesPath = "chaos/templates"
+	// PauseAnnotation Chaosmesh annotation of paused experiments
+	PauseAnnotation = "experiment.chaos-mesh.org/pause"
+)
+
+var (
+	// TemplatesFS embedded chaos templates, so experiments can be run when helmenv is used as a dependency
+	//go:embed templates/*.yml
+	TemplatesFS embed.FS
 )
 
 // Experimentable interface for chaos experiments
@@ -34,54 +48,133 @@ type Experimentable interface {
 
 // Controller is controller that manages Chaosmesh CRD instances to run experiments
 type Controller struct {
-	Client   *kubernetes.Clientset
+	Client *kubernetes.Clientset
+	// Backend backend experiments are applied with
+	Backend Backend
+	// Requests names of experiments started by this controller and not stopped yet, requests are always nil
+	//
+	// Deprecated: experiments aren't applied with a single request anymore, use Experiment and Started
 	Requests map[string]*rest.Request
-	Cfg      *Config
+	// Support chaos kinds supported by the cluster, set by Preflight
+	Support *Support
+	Cfg     *Config
+
+	// newName names experiments, tests set it to render experiments with stable names
+	newName func(resource string) string
+
+	// mu guards experiments started by this controller by name
+	mu          sync.Mutex
+	experiments map[string]*ExperimentInfo
+
+	tmu       sync.Mutex
+	timeline  []TimelineEvent
+	injected  map[string][]string
+	recovered map[string]bool
 }
 
 // Config Chaosmesh controller config
 type Config struct {
 	Client        *kubernetes.Clientset
 	NamespaceName string
+	// Templates extra chaos templates, templates with the same file names override embedded ones
+	Templates fs.FS
+	// RESTClient client for Chaosmesh API calls, defaults to the REST client of Client
+	RESTClient rest.Interface
+	// Backend applies experiments, defaults to ChaosMeshBackend, see NativeBackend for clusters without Chaosmesh
+	Backend Backend
+	// Backoff retries of transient API server failures, defaults to DefaultRetryBackoff
+	Backoff wait.Backoff
+	// RunID labels every created experiment, so experiments of a run can be told apart, generated when empty
+	RunID string
+	// MaxDuration experiments with a longer duration are rejected, and experiments older than that, except schedules,
+	// are deleted by Watchdog, no limit when zero
+	MaxDuration time.Duration
 }
 
 // ExperimentInfo persistent experiment info
 type ExperimentInfo struct {
 	Name     string `json:"name,omitempty" mapstructure:"name"`
 	Resource string `json:"resource,omitempty" mapstructure:"resource"`
+	RunID    string `json:"run_id,omitempty" mapstructure:"run_id"`
+	// Created creation time of the experiment, only known for listed experiments
+	Created *time.Time `json:"created,omitempty" mapstructure:"created"`
 }
 
 // NewController creates controller to run and stop chaos experiments
 func NewController(cfg *Config) (*Controller, error) {
+	if len(cfg.RunID) == 0 {
+		cfg.RunID = uuid.NewV4().String()
+	}
+	backend := cfg.Backend
+	if backend == nil {
+		backend = &ChaosMeshBackend{
+			Client:     cfg.Client,
+			RESTClient: cfg.RESTClient,
+			Namespace:  cfg.NamespaceName,
+			Backoff:    cfg.Backoff,
+		}
+	}
 	return &Controller{
-		Client:   cfg.Client,
-		Requests: make(map[string]*rest.Request),
-		Cfg:      cfg,
+		Client:      cfg.Client,
+		Backend:     backend,
+		Requests:    make(map[string]*rest.Request),
+		Cfg:         cfg,
+		experiments: make(map[string]*ExperimentInfo),
 	}, nil
 }
 
+// name returns unique experiment name
+func (c *Controller) name(resource string) string {
+	if c.newName != nil {
+		return c.newName(resource)
+	}
+	return fmt.Sprintf("%s-%s", resource, uuid.NewV4().String())
+}
+
 func (c *Controller) payloadFromStruct(exp Experimentable) (*CRDPayload, error) {
-	name := fmt.Sprintf("%s-%s", exp.Resource(), uuid.NewV4().String())
+	name := c.name(exp.Resource())
 	exp.SetBase(experiments.Base{
 		Name:      name,
 		Namespace: c.Cfg.NamespaceName,
 	})
-	fileBytes, err := ioutil.ReadFile(filepath.Join(tools.ProjectRoot, TemplatesPath, exp.Filename()))
+	data, err := c.render(exp)
 	if err != nil {
 		return nil, err
 	}
-	d, err := marshallTemplate(exp, "Chaos template", string(fileBytes))
-	if err != nil {
+	if data, err = c.label(data); err != nil {
 		return nil, err
 	}
-	data, err := yaml.YAMLToJSON([]byte(d))
+	return &CRDPayload{Name: name, Resource: exp.Resource(), Data: data}, nil
+}
+
+// readTemplate reads experiment template from user supplied templates first, then from embedded ones
+func (c *Controller) readTemplate(filename string) ([]byte, error) {
+	if c.Cfg.Templates != nil {
+		b, err := fs.ReadFile(c.Cfg.Templates, filename)
+		if err == nil {
+			return b, nil
+		}
+		if !errors.Is(err, fs.ErrNotExist) {
+			return nil, err
+		}
+	}
+	b, err := fs.ReadFile(TemplatesFS, path.Join("templates", filename))
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("chaos template %s not found: %w", filename, err)
 	}
-	return &CRDPayload{Name: name, Resource: exp.Resource(), Data: data}, nil
+	return b, nil
+}
+
+// templateOverridden returns true if user supplied templates have the file
+func (c *Controller) templateOverridden(filename string) bool {
+	if c.Cfg.Templates == nil {
+		return false
+	}
+	_, err := fs.Stat(c.Cfg.Templates, filename)
+	return err == nil
 }
 
-func (c *Controller) payloadFromTemplate(tmplPath string) (*CRDPayload, error) {
+func (c *Controller) payloadFromTemplate(tmplPath string, target *experiments.Selector) (*CRDPayload, error) {
 	tmplData, err := ioutil.ReadFile(tmplPath)
 	if err != nil {
 		return nil, err
@@ -94,7 +187,12 @@ func (c *Controller) payloadFromTemplate(tmplPath string) (*CRDPayload, error) {
 	if !ok {
 		return nil, fmt.Errorf("chaos template must have 'resource' field, see Chaosmesh CRD resource types")
 	}
-	name := fmt.Sprintf("%s-%s", resource, uuid.NewV4().String())
+	if target != nil {
+		if err := setTemplateSelector(tmplMap, target); err != nil {
+			return nil, fmt.Errorf("chaos template %s: %w", tmplPath, err)
+		}
+	}
+	name := c.name(resource)
 	tmplMap["metadata"] = map[string]interface{}{
 		"name":      name,
 		"namespace": c.Cfg.NamespaceName,
@@ -107,9 +205,34 @@ func (c *Controller) payloadFromTemplate(tmplPath string) (*CRDPayload, error) {
 	if err != nil {
 		return nil, err
 	}
+	if data, err = c.label(data); err != nil {
+		return nil, err
+	}
 	return &CRDPayload{Name: name, Resource: resource, Data: data}, nil
 }
 
+// label adds helmenv labels to the rendered experiment, keeping labels set by its template
+func (c *Controller) label(data []byte) ([]byte, error) {
+	var crd map[string]interface{}
+	if err := json.Unmarshal(data, &crd); err != nil {
+		return nil, err
+	}
+	metadata, _ := crd["metadata"].(map[string]interface{})
+	if metadata == nil {
+		metadata = map[string]interface{}{}
+		crd["metadata"] = metadata
+	}
+	labels, _ := metadata["labels"].(map[string]interface{})
+	if labels == nil {
+		labels = map[string]interface{}{}
+		metadata["labels"] = labels
+	}
+	for k, v := range c.labels() {
+		labels[k] = v
+	}
+	return json.Marshal(crd)
+}
+
 // CRDPayload Custom Resource Definition call payload
 type CRDPayload struct {
 	Name     string
@@ -119,57 +242,125 @@ type CRDPayload struct {
 
 // RunTemplate applies chaos from yaml template to a particular environment
 func (c *Controller) RunTemplate(tmplPath string) (*ExperimentInfo, error) {
-	payload, err := c.payloadFromTemplate(tmplPath)
+	return c.RunTemplateOn(tmplPath, nil)
+}
+
+// RunTemplateOn applies chaos from yaml template with its selector filled in from the target,
+// target labels and criteria override ones of the template
+func (c *Controller) RunTemplateOn(tmplPath string, target *experiments.Selector) (*ExperimentInfo, error) {
+	payload, err := c.payloadFromTemplate(tmplPath, target)
 	if err != nil {
 		return nil, err
 	}
-	log.Info().
-		Str("Name", payload.Name).
-		Str("Resource", payload.Resource).
-		Msg("Starting chaos experiment")
-	req := c.Client.RESTClient().
-		Post().
-		AbsPath(APIBasePath).
-		Name(payload.Name).
-		Namespace(c.Cfg.NamespaceName).
-		Resource(payload.Resource).
-		Body(payload.Data)
-	resp := req.Do(context.Background())
-	if resp.Error() != nil {
+	if err := c.create(payload); err != nil {
 		return nil, err
 	}
-	return &ExperimentInfo{Name: payload.Name, Resource: payload.Resource}, nil
+	info := &ExperimentInfo{Name: payload.Name, Resource: payload.Resource, RunID: c.Cfg.RunID}
+	c.track(info)
+	return info, nil
 }
 
 // Run runs experiment and saves it's ID
 func (c *Controller) Run(exp Experimentable) (string, error) {
-	payload, err := c.payloadFromStruct(exp)
+	info, err := c.run(exp)
 	if err != nil {
 		return "", err
 	}
+	return info.Name, nil
+}
+
+func (c *Controller) run(exp Experimentable) (*ExperimentInfo, error) {
+	payload, err := c.payloadFromStruct(exp)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.create(payload); err != nil {
+		return nil, err
+	}
+	info := &ExperimentInfo{Name: payload.Name, Resource: payload.Resource, RunID: c.Cfg.RunID}
+	c.track(info)
+	return info, nil
+}
+
+// Experiment returns experiment started by this controller
+func (c *Controller) Experiment(name string) (*ExperimentInfo, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	info, ok := c.experiments[name]
+	return info, ok
+}
+
+// Started returns experiments started by this controller and not stopped yet
+func (c *Controller) Started() []*ExperimentInfo {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	infos := make([]*ExperimentInfo, 0, len(c.experiments))
+	for _, info := range c.experiments {
+		infos = append(infos, info)
+	}
+	return infos
+}
+
+func (c *Controller) track(info *ExperimentInfo) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.experiments[info.Name] = info
+	c.Requests[info.Name] = nil
+}
+
+func (c *Controller) untrack(name string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.experiments, name)
+	delete(c.Requests, name)
+}
+
+// create creates experiment
+func (c *Controller) create(payload *CRDPayload) error {
 	log.Info().
 		Str("Name", payload.Name).
-		Str("Resource", exp.Resource()).
+		Str("Resource", payload.Resource).
 		Msg("Starting chaos experiment")
-	req := c.Client.RESTClient().
-		Post().
-		AbsPath(APIBasePath).
-		Name(payload.Name).
-		Namespace(c.Cfg.NamespaceName).
-		Resource(exp.Resource()).
-		Body(payload.Data)
-	resp := req.Do(context.Background())
-	if resp.Error() != nil {
-		return "", err
+	if c.Support != nil {
+		if err := c.Support.Check(payload.Resource); err != nil {
+			c.record(EventApplied, payload.Name, payload.Resource, nil, err)
+			return err
+		}
+	}
+	if err := c.checkDuration(payload); err != nil {
+		c.record(EventApplied, payload.Name, payload.Resource, nil, err)
+		return err
+	}
+	if err := c.Backend.Create(payload); err != nil {
+		c.record(EventApplied, payload.Name, payload.Resource, nil, err)
+		return err
+	}
+	// status right after apply has pods already selected, injection is recorded after the apply
+	s := c.statusForTimeline(&ExperimentInfo{Name: payload.Name, Resource: payload.Resource, RunID: c.Cfg.RunID})
+	c.record(EventApplied, payload.Name, payload.Resource, statusPods(s), nil)
+	c.observe(s)
+	return nil
+}
+
+// delete deletes experiment, status is read before to record injection and pods of the stopped experiment
+func (c *Controller) delete(info *ExperimentInfo) error {
+	log.Info().Str("ID", info.Name).Msg("Deleting chaos experiment")
+	s := c.statusForTimeline(info)
+	c.observe(s)
+	err := c.Backend.Delete(info)
+	if !k8sErrors.IsNotFound(err) {
+		c.record(EventStopped, info.Name, info.Resource, statusPods(s), err)
 	}
-	c.Requests[payload.Name] = req
-	return payload.Name, nil
+	return err
 }
 
-// StopAllStandalone stops all chaos experiments for a presets env
+// StopAllStandalone stops all chaos experiments for a presets env, experiments already deleted are skipped
 func (c *Controller) StopAllStandalone(expInfos map[string]*ExperimentInfo) error {
 	for _, e := range expInfos {
-		if err := c.StopStandalone(e); err != nil {
+		if e == nil {
+			continue
+		}
+		if err := c.StopStandalone(e); err != nil && !k8sErrors.IsNotFound(err) {
 			return err
 		}
 	}
@@ -178,46 +369,90 @@ func (c *Controller) StopAllStandalone(expInfos map[string]*ExperimentInfo) erro
 
 // StopStandalone removes experiment's entity for a presets env
 func (c *Controller) StopStandalone(expInfo *ExperimentInfo) error {
-	log.Info().Str("ID", expInfo.Name).Msg("Deleting chaos experiment")
-	req := c.Client.RESTClient().
-		Delete().
-		AbsPath(APIBasePath).
-		Name(expInfo.Name).
-		Resource(expInfo.Resource).
-		Namespace(c.Cfg.NamespaceName)
-	resp := req.Do(context.Background())
-	if resp.Error() != nil {
-		return resp.Error()
+	if err := c.delete(expInfo); err != nil {
+		return err
 	}
-	return resp.Error()
+	c.untrack(expInfo.Name)
+	return nil
 }
 
 // Stop removes experiment's entity
 func (c *Controller) Stop(name string) error {
-	log.Info().Str("ID", name).Msg("Deleting chaos experiment")
-	exp, ok := c.Requests[name]
+	info, ok := c.Experiment(name)
 	if !ok {
 		return fmt.Errorf("experiment %s not found", name)
 	}
-	res := exp.Verb("DELETE").Do(context.Background())
-	if res.Error() != nil {
-		return res.Error()
+	// experiment could be deleted by the watchdog already
+	if err := c.delete(info); err != nil && !k8sErrors.IsNotFound(err) {
+		return err
 	}
-	delete(c.Requests, name)
+	c.untrack(name)
 	return nil
 }
 
-// StopAll removes all experiments entities
+// Pause pauses experiment, injected chaos is recovered until the experiment is resumed
+func (c *Controller) Pause(name string) error {
+	info, ok := c.Experiment(name)
+	if !ok {
+		return fmt.Errorf("experiment %s not found", name)
+	}
+	return c.PauseOf(info)
+}
+
+// Resume resumes paused experiment
+func (c *Controller) Resume(name string) error {
+	info, ok := c.Experiment(name)
+	if !ok {
+		return fmt.Errorf("experiment %s not found", name)
+	}
+	return c.ResumeOf(info)
+}
+
+// PauseOf pauses any experiment, including ones started by another process, paused schedules don't spawn experiments
+func (c *Controller) PauseOf(info *ExperimentInfo) error {
+	log.Info().Str("ID", info.Name).Msg("Pausing chaos experiment")
+	err := c.Backend.SetPaused(info, true)
+	c.record(EventPaused, info.Name, info.Resource, nil, err)
+	return err
+}
+
+// ResumeOf resumes any paused experiment
+func (c *Controller) ResumeOf(info *ExperimentInfo) error {
+	log.Info().Str("ID", info.Name).Msg("Resuming chaos experiment")
+	err := c.Backend.SetPaused(info, false)
+	c.record(EventResumed, info.Name, info.Resource, nil, err)
+	return err
+}
+
+// StopAll removes every experiment created by helmenv in the namespace, including ones started by another process
 func (c *Controller) StopAll() error {
-	for id := range c.Requests {
-		err := c.Stop(id)
-		if err != nil {
+	infos, err := c.List()
+	if err != nil {
+		return err
+	}
+	for _, info := range infos {
+		if err := c.delete(info); err != nil && !k8sErrors.IsNotFound(err) {
 			return err
 		}
+		c.untrack(info.Name)
 	}
 	return nil
 }
 
+// StopStarted removes experiments started by this controller, stopping as many as possible
+func (c *Controller) StopStarted() error {
+	var firstErr error
+	for _, info := range c.Started() {
+		if err := c.Stop(info.Name); err != nil {
+			log.Error().Err(err).Str("ID", info.Name).Msg("Failed to stop chaos experiment")
+			if firstErr == nil {
+				firstErr = err
+			}
+		}
+	}
+	return firstErr
+}
+
 func marshallTemplate(any interface{}, name, templateString string) (string, error) {
 	var buf bytes.Buffer
 	tmpl, err := template.New(name).Parse(templateString)
@@ -0,0 +1,422 @@
+package chaos
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"sync"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/goplugin/helmenv/chaos/experiments"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEmbeddedTemplates(t *testing.T) {
+	t.Parallel()
+
+	c, err := NewController(&Config{NamespaceName: "test"})
+	require.NoError(t, err)
+	data, err := c.renderTemplate(&experiments.PodKill{Mode: "one", LabelKey: "app", LabelValue: "plugin-node"})
+	require.NoError(t, err)
+	require.Contains(t, string(data), `"action":"pod-kill"`)
+}
+
+func TestTemplatesOverride(t *testing.T) {
+	t.Parallel()
+
+	c, err := NewController(&Config{
+		NamespaceName: "test",
+		Templates: fstest.MapFS{
+			"pod-kill.yml": {Data: []byte("kind: PodChaos\nmetadata:\n  name: {{ .Base.Name }}\nspec:\n  action: custom-kill\n")},
+		},
+	})
+	require.NoError(t, err)
+	payload, err := c.payloadFromStruct(&experiments.PodKill{})
+	require.NoError(t, err)
+	require.Contains(t, string(payload.Data), `"action":"custom-kill"`)
+
+	// templates missing in the user FS are still resolved from embedded ones
+	payload, err = c.payloadFromStruct(&experiments.PodFailure{Mode: "one"})
+	require.NoError(t, err)
+	require.Contains(t, string(payload.Data), `"action":"pod-failure"`)
+}
+
+func TestSchedulePayload(t *testing.T) {
+	t.Parallel()
+
+	c, err := NewController(&Config{NamespaceName: "test"})
+	require.NoError(t, err)
+	payload, err := c.payloadFromStruct(&experiments.Schedule{
+		Cron:              "@every 5m",
+		ConcurrencyPolicy: experiments.ConcurrencyForbid,
+		HistoryLimit:      2,
+		Experiment: &experiments.NetworkDelay{
+			Mode:       "all",
+			LabelKey:   "app",
+			LabelValue: "plugin-node",
+			Latency:    300 * time.Millisecond,
+			Duration:   time.Minute,
+		},
+	})
+	require.NoError(t, err)
+	require.Equal(t, "schedules", payload.Resource)
+
+	var crd map[string]interface{}
+	require.NoError(t, json.Unmarshal(payload.Data, &crd))
+	spec := crd["spec"].(map[string]interface{})
+	require.Equal(t, "Schedule", crd["kind"])
+	require.Equal(t, "@every 5m", spec["schedule"])
+	require.Equal(t, "Forbid", spec["concurrencyPolicy"])
+	require.Equal(t, float64(2), spec["historyLimit"])
+	require.NotContains(t, spec, "startingDeadlineSeconds")
+	require.Equal(t, "NetworkChaos", spec["type"])
+	networkChaos := spec["networkChaos"].(map[string]interface{})
+	require.Equal(t, "delay", networkChaos["action"])
+	require.Equal(t, "300ms", networkChaos["delay"].(map[string]interface{})["latency"])
+}
+
+func TestWorkflowPayload(t *testing.T) {
+	t.Parallel()
+
+	c, err := NewController(&Config{NamespaceName: "test"})
+	require.NoError(t, err)
+	payload, err := c.payloadFromStruct(&experiments.Workflow{
+		Steps: []experiments.WorkflowStep{
+			experiments.Serial("entry", "kill", "pause", "faults"),
+			experiments.Chaos("kill", 30*time.Second, &experiments.PodKill{Mode: "one", LabelKey: "app", LabelValue: "geth"}),
+			experiments.Suspend("pause", 10*time.Second),
+			experiments.Parallel("faults", "io"),
+			experiments.Chaos("io", time.Minute, &experiments.IODelay{
+				Mode:       "one",
+				LabelKey:   "app",
+				LabelValue: "postgres",
+				VolumePath: "/var/lib/postgresql",
+				Delay:      time.Second,
+			}),
+		},
+	})
+	require.NoError(t, err)
+	require.Equal(t, "workflows", payload.Resource)
+
+	var crd struct {
+		Kind string `json:"kind"`
+		Spec struct {
+			Entry     string                   `json:"entry"`
+			Templates []map[string]interface{} `json:"templates"`
+		} `json:"spec"`
+	}
+	require.NoError(t, json.Unmarshal(payload.Data, &crd))
+	require.Equal(t, "Workflow", crd.Kind)
+	require.Equal(t, "entry", crd.Spec.Entry)
+	require.Len(t, crd.Spec.Templates, 5)
+	require.Equal(t, "Serial", crd.Spec.Templates[0]["templateType"])
+	require.Equal(t, []interface{}{"kill", "pause", "faults"}, crd.Spec.Templates[0]["children"])
+	require.Equal(t, "PodChaos", crd.Spec.Templates[1]["templateType"])
+	require.Equal(t, "30s", crd.Spec.Templates[1]["deadline"])
+	require.Equal(t, "pod-kill", crd.Spec.Templates[1]["podChaos"].(map[string]interface{})["action"])
+	require.Equal(t, "Suspend", crd.Spec.Templates[2]["templateType"])
+	require.Equal(t, "IOChaos", crd.Spec.Templates[4]["templateType"])
+	require.Contains(t, crd.Spec.Templates[4], "ioChaos")
+}
+
+func TestEmptyComposites(t *testing.T) {
+	t.Parallel()
+
+	c, err := NewController(&Config{NamespaceName: "test"})
+	require.NoError(t, err)
+	_, err = c.payloadFromStruct(&experiments.Workflow{})
+	require.Error(t, err)
+	_, err = c.payloadFromStruct(&experiments.Schedule{Cron: "@every 5m"})
+	require.Error(t, err)
+
+	// template data is checked as well, as it's rendered from nested experiments too
+	_, err = c.templateData(&experiments.Workflow{Base: experiments.Base{Name: "empty"}})
+	require.EqualError(t, err, "workflow empty has no steps")
+	_, err = c.templateData(&experiments.Schedule{Base: experiments.Base{Name: "empty"}, Cron: "@every 5m"})
+	require.EqualError(t, err, "schedule empty has no experiment")
+}
+
+func TestSpecKey(t *testing.T) {
+	t.Parallel()
+
+	for kind, key := range map[string]string{
+		"PodChaos":     "podChaos",
+		"NetworkChaos": "networkChaos",
+		"IOChaos":      "ioChaos",
+		"DNSChaos":     "dnsChaos",
+		"Workflow":     "workflow",
+	} {
+		require.Equal(t, key, specKey(kind))
+	}
+}
+
+// validExperiments returns a valid experiment of every kind with every field set
+func validExperiments() []Experimentable {
+	return []Experimentable{
+		&experiments.ContainerKill{Mode: "one", Container: "node"},
+		&experiments.CPUHog{Mode: "one", Workers: 1, Load: 50, OptsCPU: 1, OptsTimeout: 10, OptsHDD: 1, Duration: time.Minute},
+		&experiments.DNSChaos{Patterns: []string{"google.com"}, Duration: time.Minute},
+		&experiments.IODelay{Mode: "one", VolumePath: "/data", Path: "/data/*", Delay: time.Second, Percent: 50, Duration: time.Minute},
+		&experiments.IOFault{Mode: "one", VolumePath: "/data", Path: "/data/*", Errno: 5, Percent: 50, Duration: time.Minute},
+		&experiments.NetworkBandwidth{Mode: "one", Rate: "1mbps", Limit: 100, Buffer: 1000, PeakRate: 2, MinBurst: 3, Duration: time.Minute},
+		&experiments.NetworkCorrupt{Mode: "one", Corrupt: 10, Correlation: 20, Duration: time.Minute},
+		&experiments.NetworkDelay{
+			Mode:      "one",
+			Latency:   time.Second,
+			Duration:  time.Minute,
+			Direction: experiments.DirectionBoth,
+			Target:    &experiments.Selector{Mode: experiments.ModeFixed, Value: "2", Labels: map[string]string{"app": "geth"}},
+		},
+		&experiments.NetworkDuplicate{Mode: "one", Duplicate: 10, Correlation: 20, Duration: time.Minute},
+		&experiments.NetworkLoss{
+			Mode:        "one",
+			Loss:        10,
+			Correlation: 20,
+			Duration:    time.Minute,
+			Target:      &experiments.Selector{Namespaces: []string{"other"}},
+		},
+		&experiments.NetworkPartition{FromMode: "one", ToMode: "all", ToLabelKey: "app", ToLabelValue: "geth"},
+		&experiments.PodFailure{Mode: "one", Duration: time.Minute},
+		&experiments.PodKill{Mode: "one"},
+		&experiments.TimeShift{Mode: "one", TimeOffset: time.Hour, Duration: time.Minute},
+	}
+}
+
+// TestBuildersMatchTemplates experiments built directly must match the embedded templates users can override
+func TestBuildersMatchTemplates(t *testing.T) {
+	t.Parallel()
+
+	c, err := NewController(&Config{NamespaceName: "test"})
+	require.NoError(t, err)
+	for _, exp := range validExperiments() {
+		exp.SetBase(experiments.Base{Name: "chaos", Namespace: "test", Selector: &experiments.Selector{
+			Labels: map[string]string{"app": "plugin-node"},
+		}})
+		built, err := c.render(exp)
+		require.NoError(t, err)
+		rendered, err := c.renderTemplate(exp)
+		require.NoError(t, err)
+		require.JSONEq(t, string(rendered), string(built), exp.Filename())
+	}
+}
+
+func TestRenderValidates(t *testing.T) {
+	t.Parallel()
+
+	c, err := NewController(&Config{NamespaceName: "test"})
+	require.NoError(t, err)
+	_, err = c.payloadFromStruct(&experiments.NetworkDelay{Mode: "one"})
+	var vErr *experiments.ValidationError
+	require.True(t, errors.As(err, &vErr), err)
+	_, err = c.payloadFromStruct(&experiments.Schedule{Experiment: &experiments.PodKill{Mode: "one"}})
+	require.EqualError(t, err, "invalid Schedule experiment: cron is required")
+}
+
+func TestSelectorRendering(t *testing.T) {
+	t.Parallel()
+
+	all := validExperiments()
+	for _, exp := range all {
+		exp := exp
+		t.Run(exp.Filename(), func(t *testing.T) {
+			t.Parallel()
+
+			c, err := NewController(&Config{NamespaceName: "test"})
+			require.NoError(t, err)
+			exp.SetBase(experiments.Base{Selector: &experiments.Selector{
+				Mode:   experiments.ModeFixedPercent,
+				Value:  "50",
+				Labels: map[string]string{"app": "plugin-node"},
+				Expressions: []experiments.Expression{
+					{Key: "instance", Operator: experiments.OpIn, Values: []string{"1", "3"}},
+				},
+				Annotations: map[string]string{"chaos": "enabled"},
+				Fields:      map[string]string{"status.phase": "Running"},
+			}})
+			payload, err := c.payloadFromStruct(exp)
+			require.NoError(t, err)
+			rendered, err := c.renderTemplate(exp)
+			require.NoError(t, err)
+
+			var crd, tmplCRD struct {
+				Spec struct {
+					Mode     string                 `json:"mode"`
+					Value    string                 `json:"value"`
+					Selector map[string]interface{} `json:"selector"`
+				} `json:"spec"`
+			}
+			require.NoError(t, json.Unmarshal(payload.Data, &crd))
+			require.NoError(t, json.Unmarshal(rendered, &tmplCRD))
+			require.Equal(t, crd, tmplCRD)
+			require.Equal(t, "fixed-percent", crd.Spec.Mode)
+			require.Equal(t, "50", crd.Spec.Value)
+			require.Equal(t, map[string]interface{}{
+				"namespaces":     []interface{}{"test"},
+				"labelSelectors": map[string]interface{}{"app": "plugin-node"},
+				"expressionSelectors": []interface{}{
+					map[string]interface{}{"key": "instance", "operator": "In", "values": []interface{}{"1", "3"}},
+				},
+				"annotationSelectors": map[string]interface{}{"chaos": "enabled"},
+				"fieldSelectors":      map[string]interface{}{"status.phase": "Running"},
+			}, crd.Spec.Selector)
+		})
+	}
+}
+
+func TestLegacySelector(t *testing.T) {
+	t.Parallel()
+
+	c, err := NewController(&Config{NamespaceName: "test"})
+	require.NoError(t, err)
+	payload, err := c.payloadFromStruct(&experiments.NetworkPartition{
+		FromMode:       "one",
+		FromLabelKey:   "app",
+		FromLabelValue: "plugin-node",
+		ToMode:         "all",
+		ToLabelKey:     "app",
+		ToLabelValue:   "geth",
+	})
+	require.NoError(t, err)
+	require.Contains(t, string(payload.Data),
+		`"mode":"one","selector":{"labelSelectors":{"app":"plugin-node"},"namespaces":["test"]}`)
+	require.Contains(t, string(payload.Data),
+		`"target":{"mode":"all","selector":{"labelSelectors":{"app":"geth"},"namespaces":["test"]}}`)
+
+	// pods by name are merged with the legacy label
+	payload, err = c.payloadFromStruct(&experiments.PodKill{
+		Base:       experiments.Base{Selector: &experiments.Selector{Pods: map[string][]string{"test": {"geth-0"}}}},
+		Mode:       "all",
+		LabelKey:   "app",
+		LabelValue: "geth",
+	})
+	require.NoError(t, err)
+	require.Contains(t, string(payload.Data),
+		`"mode":"all","selector":{"labelSelectors":{"app":"geth"},"namespaces":["test"],"pods":{"test":["geth-0"]}}`)
+}
+
+func TestTemplateTarget(t *testing.T) {
+	t.Parallel()
+
+	c, err := NewController(&Config{NamespaceName: "test"})
+	require.NoError(t, err)
+	target := &experiments.Selector{
+		Mode:        experiments.ModeAll,
+		Labels:      map[string]string{"release": "plugin", "app": "plugin-node"},
+		Expressions: []experiments.Expression{{Key: "instance", Operator: experiments.OpIn, Values: []string{"2"}}},
+	}
+
+	payload, err := c.payloadFromTemplate("../examples/chaos/schedule-pod-kill.yml", target)
+	require.NoError(t, err)
+	var schedule struct {
+		Spec struct {
+			PodChaos struct {
+				Mode     string                 `json:"mode"`
+				Selector map[string]interface{} `json:"selector"`
+			} `json:"podChaos"`
+		} `json:"spec"`
+	}
+	require.NoError(t, json.Unmarshal(payload.Data, &schedule))
+	require.Equal(t, "all", schedule.Spec.PodChaos.Mode)
+	require.Equal(t, map[string]interface{}{
+		"labelSelectors": map[string]interface{}{"release": "plugin", "app": "plugin-node"},
+		"expressionSelectors": []interface{}{
+			map[string]interface{}{"key": "instance", "operator": "In", "values": []interface{}{"2"}},
+		},
+	}, schedule.Spec.PodChaos.Selector)
+
+	_, err = c.payloadFromTemplate("../examples/chaos/workflow-network.yml", target)
+	require.Error(t, err)
+}
+
+func TestPauseResume(t *testing.T) {
+	t.Parallel()
+
+	c, api := newFakeController(t, fakeResponse{201, `{}`}, fakeResponse{200, `{}`})
+	name, err := c.Run(&experiments.PodKill{Mode: "one"})
+	require.NoError(t, err)
+	require.Contains(t, c.Requests, name)
+	require.NoError(t, c.Pause(name))
+	require.NoError(t, c.Resume(name))
+	// status is read after apply for the timeline
+	require.Len(t, api.requests, 4)
+	for i, annotation := range []string{`"true"`, `null`} {
+		req := api.requests[i+2]
+		require.Equal(t, http.MethodPatch, req.Method)
+		require.Equal(t, "application/merge-patch+json", req.Header.Get("Content-Type"))
+		require.Equal(t, APIBasePath+"/namespaces/test/podchaos/"+name, req.URL.Path)
+		body, err := io.ReadAll(req.Body)
+		require.NoError(t, err)
+		require.JSONEq(t, `{"metadata":{"annotations":{"experiment.chaos-mesh.org/pause":`+annotation+`}}}`, string(body))
+	}
+
+	require.EqualError(t, c.Pause("missing"), "experiment missing not found")
+	require.EqualError(t, c.PauseOf(&ExperimentInfo{Name: "workflow-1", Resource: "workflows"}),
+		"experiment workflow-1: workflows can't be paused")
+}
+
+// memBackend keeps experiments in memory
+type memBackend struct {
+	mu    sync.Mutex
+	items map[string]*CRDPayload
+}
+
+func (b *memBackend) Create(payload *CRDPayload) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.items[payload.Name] = payload
+	return nil
+}
+
+func (b *memBackend) Delete(info *ExperimentInfo) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	delete(b.items, info.Name)
+	return nil
+}
+
+func (b *memBackend) Status(info *ExperimentInfo) (*ExperimentStatus, error) {
+	return &ExperimentStatus{Name: info.Name, Resource: info.Resource}, nil
+}
+
+func (b *memBackend) List() ([]*ExperimentInfo, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	var infos []*ExperimentInfo
+	for _, p := range b.items {
+		infos = append(infos, &ExperimentInfo{Name: p.Name, Resource: p.Resource})
+	}
+	return infos, nil
+}
+
+func (b *memBackend) SetPaused(*ExperimentInfo, bool) error {
+	return nil
+}
+
+func TestControllerConcurrentUse(t *testing.T) {
+	t.Parallel()
+
+	backend := &memBackend{items: map[string]*CRDPayload{}}
+	c, err := NewController(&Config{NamespaceName: "test", Backend: backend})
+	require.NoError(t, err)
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			name, err := c.Run(&experiments.PodKill{Mode: "one"})
+			require.NoError(t, err)
+			_, ok := c.Experiment(name)
+			require.True(t, ok)
+			require.NotEmpty(t, c.Started())
+			require.NoError(t, c.Pause(name))
+			require.NoError(t, c.Stop(name))
+		}()
+	}
+	wg.Wait()
+	require.Empty(t, c.Started())
+	require.Empty(t, c.Requests)
+	require.Empty(t, backend.items)
+}
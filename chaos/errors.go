@@ -0,0 +1,141 @@
+package chaos
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	k8sErrors "k8s.io/apimachinery/pkg/api/errors"
+	utilnet "k8s.io/apimachinery/pkg/util/net"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/util/retry"
+)
+
+var (
+	// ErrCRDNotInstalled Chaosmesh CRD of the experiment resource is not installed in the cluster
+	ErrCRDNotInstalled = errors.New("chaos mesh CRD is not installed")
+	// ErrInvalidExperiment experiment was rejected by API server or Chaosmesh webhook validation
+	ErrInvalidExperiment = errors.New("chaos experiment rejected by validation")
+	// ErrNameConflict experiment with the same name already exists
+	ErrNameConflict = errors.New("chaos experiment name conflict")
+	// ErrForbidden client is not allowed to manage chaos experiments
+	ErrForbidden = errors.New("forbidden to manage chaos experiments")
+
+	// DefaultRetryBackoff backoff of transient API server failures, used when Backoff is not set
+	DefaultRetryBackoff = wait.Backoff{
+		Steps:    5,
+		Duration: 200 * time.Millisecond,
+		Factor:   2.0,
+		Jitter:   0.1,
+	}
+)
+
+// APIError API server failure of a chaos experiment request, matches one of the Err* kinds with errors.Is
+// and the underlying k8s status error with errors.As
+type APIError struct {
+	Kind     error
+	Verb     string
+	Resource string
+	Name     string
+	// Message API server message
+	Message string
+	Err     error
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("%s: %s %s/%s: %s", e.Kind, e.Verb, e.Resource, e.Name, e.Message)
+}
+
+// Is reports whether target is the kind of the error
+func (e *APIError) Is(target error) bool {
+	return target == e.Kind
+}
+
+func (e *APIError) Unwrap() error {
+	return e.Err
+}
+
+// newAPIError classifies API server error of a request, unclassified errors are only annotated with the request
+func newAPIError(verb, resource, name string, err error) error {
+	var kind error
+	switch {
+	case verb == http.MethodPost && k8sErrors.IsNotFound(err):
+		kind = ErrCRDNotInstalled
+	case k8sErrors.IsInvalid(err), k8sErrors.IsBadRequest(err):
+		kind = ErrInvalidExperiment
+	case k8sErrors.IsAlreadyExists(err), k8sErrors.IsConflict(err):
+		kind = ErrNameConflict
+	case k8sErrors.IsForbidden(err), k8sErrors.IsUnauthorized(err):
+		kind = ErrForbidden
+	default:
+		return fmt.Errorf("%s %s/%s: %w", verb, resource, name, err)
+	}
+	msg := err.Error()
+	var status k8sErrors.APIStatus
+	if errors.As(err, &status) && len(status.Status().Message) > 0 {
+		msg = status.Status().Message
+	}
+	return &APIError{
+		Kind:     kind,
+		Verb:     verb,
+		Resource: resource,
+		Name:     name,
+		Message:  msg,
+		Err:      err,
+	}
+}
+
+// isTransient returns true if request may succeed when retried
+func isTransient(err error) bool {
+	return k8sErrors.IsServerTimeout(err) ||
+		k8sErrors.IsTimeout(err) ||
+		k8sErrors.IsTooManyRequests(err) ||
+		k8sErrors.IsInternalError(err) ||
+		k8sErrors.IsServiceUnavailable(err) ||
+		utilnet.IsConnectionReset(err) ||
+		utilnet.IsConnectionRefused(err) ||
+		utilnet.IsProbableEOF(err)
+}
+
+func (b *ChaosMeshBackend) backoff() wait.Backoff {
+	if b.Backoff.Steps <= 0 {
+		return DefaultRetryBackoff
+	}
+	return b.Backoff
+}
+
+// do executes request built by req, retrying transient failures, and returns response body
+func (b *ChaosMeshBackend) do(verb, resource, name string, req func() *rest.Request) ([]byte, error) {
+	var body []byte
+	attempt := 0
+	err := retry.OnError(b.backoff(), isTransient, func() error {
+		attempt++
+		res := req().Do(context.Background())
+		// Error decodes API server status from the response body, unlike the error returned by Raw
+		err := res.Error()
+		body, _ = res.Raw()
+		switch {
+		case err == nil:
+			return nil
+		case verb == http.MethodPost && attempt > 1 && k8sErrors.IsAlreadyExists(err):
+			// experiment names are unique, so the previous attempt was applied despite the failure
+			return nil
+		case isTransient(err):
+			log.Warn().
+				Err(err).
+				Str("Name", name).
+				Str("Resource", resource).
+				Int("Attempt", attempt).
+				Msg("Chaos experiment request failed, retrying")
+		}
+		return err
+	})
+	if err != nil {
+		return nil, newAPIError(verb, resource, name, err)
+	}
+	return body, nil
+}
@@ -0,0 +1,188 @@
+package chaos
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/goplugin/helmenv/chaos/experiments"
+	"github.com/stretchr/testify/require"
+	k8sErrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/rest/fake"
+)
+
+// fakeAPI replies to every request with the next response, the last response is repeated
+type fakeAPI struct {
+	mu        sync.Mutex
+	responses []fakeResponse
+	requests  []*http.Request
+}
+
+type fakeResponse struct {
+	code int
+	body string
+}
+
+func statusBody(code int, reason, msg string) string {
+	return fmt.Sprintf(
+		`{"kind":"Status","apiVersion":"v1","status":"Failure","message":%q,"reason":%q,"code":%d}`,
+		msg, reason, code,
+	)
+}
+
+func (f *fakeAPI) roundTrip(req *http.Request) (*http.Response, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.requests = append(f.requests, req)
+	r := f.responses[0]
+	if len(f.responses) > 1 {
+		f.responses = f.responses[1:]
+	}
+	return &http.Response{
+		StatusCode: r.code,
+		Header:     http.Header{"Content-Type": []string{"application/json"}},
+		Body:       io.NopCloser(strings.NewReader(r.body)),
+	}, nil
+}
+
+func newFakeController(t *testing.T, responses ...fakeResponse) (*Controller, *fakeAPI) {
+	api := &fakeAPI{responses: responses}
+	c, err := NewController(&Config{
+		NamespaceName: "test",
+		RESTClient: &fake.RESTClient{
+			NegotiatedSerializer: scheme.Codecs.WithoutConversion(),
+			GroupVersion:         schema.GroupVersion{Version: "v1"},
+			Client:               fake.CreateHTTPClient(api.roundTrip),
+		},
+		Backoff: wait.Backoff{Steps: 3, Duration: time.Millisecond, Factor: 1},
+	})
+	require.NoError(t, err)
+	return c, api
+}
+
+func TestRunErrors(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		response fakeResponse
+		kind     error
+		message  string
+	}{
+		{
+			name:     "CRD not installed",
+			response: fakeResponse{404, statusBody(404, "NotFound", "the server could not find the requested resource")},
+			kind:     ErrCRDNotInstalled,
+			message:  "the server could not find the requested resource",
+		},
+		{
+			name:     "validation rejected",
+			response: fakeResponse{422, statusBody(422, "Invalid", "PodChaos.chaos-mesh.org is invalid: spec.mode: Unsupported value")},
+			kind:     ErrInvalidExperiment,
+			message:  "spec.mode: Unsupported value",
+		},
+		{
+			name:     "webhook rejected",
+			response: fakeResponse{400, statusBody(400, "BadRequest", "admission webhook denied the request: invalid duration")},
+			kind:     ErrInvalidExperiment,
+			message:  "invalid duration",
+		},
+		{
+			name:     "name conflict",
+			response: fakeResponse{409, statusBody(409, "AlreadyExists", "podchaos already exists")},
+			kind:     ErrNameConflict,
+			message:  "podchaos already exists",
+		},
+		{
+			name:     "forbidden",
+			response: fakeResponse{403, statusBody(403, "Forbidden", "User cannot create resource podchaos")},
+			kind:     ErrForbidden,
+			message:  "User cannot create resource podchaos",
+		},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			c, api := newFakeController(t, test.response)
+			_, err := c.Run(&experiments.PodKill{Mode: "one"})
+			require.Error(t, err)
+			require.True(t, errors.Is(err, test.kind), err.Error())
+			require.Contains(t, err.Error(), test.message)
+			var apiErr *APIError
+			require.True(t, errors.As(err, &apiErr))
+			require.Equal(t, "podchaos", apiErr.Resource)
+			require.Len(t, api.requests, 1, "permanent failures must not be retried")
+			require.Empty(t, c.Started())
+
+			tmpl := t.TempDir() + "/chaos.yml"
+			require.NoError(t, os.WriteFile(tmpl, []byte("resource: podchaos\nkind: PodChaos\nspec:\n  action: pod-kill\n"), 0644))
+			_, err = c.RunTemplate(tmpl)
+			require.True(t, errors.Is(err, test.kind), err.Error())
+			require.Empty(t, c.Started())
+		})
+	}
+}
+
+func TestRunRetriesTransientErrors(t *testing.T) {
+	t.Parallel()
+
+	c, api := newFakeController(t,
+		fakeResponse{503, statusBody(503, "ServiceUnavailable", "etcd is unavailable")},
+		fakeResponse{429, statusBody(429, "TooManyRequests", "slow down")},
+		fakeResponse{201, `{}`},
+	)
+	name, err := c.Run(&experiments.PodKill{Mode: "one"})
+	require.NoError(t, err)
+	// 3 create attempts and the status read for the timeline
+	require.Len(t, api.requests, 4)
+	require.Equal(t, http.MethodGet, api.requests[3].Method)
+	require.Contains(t, c.experiments, name)
+}
+
+func TestRunTransientErrorsExhausted(t *testing.T) {
+	t.Parallel()
+
+	c, api := newFakeController(t, fakeResponse{500, statusBody(500, "InternalError", "internal error")})
+	_, err := c.Run(&experiments.PodKill{Mode: "one"})
+	require.Error(t, err)
+	require.True(t, k8sErrors.IsInternalError(err))
+	require.Len(t, api.requests, 3)
+	require.Empty(t, c.Started())
+}
+
+func TestRunAppliedDespiteTransientError(t *testing.T) {
+	t.Parallel()
+
+	c, _ := newFakeController(t,
+		fakeResponse{504, statusBody(504, "Timeout", "request timed out")},
+		fakeResponse{409, statusBody(409, "AlreadyExists", "podchaos already exists")},
+	)
+	name, err := c.Run(&experiments.PodKill{Mode: "one"})
+	require.NoError(t, err)
+	require.Contains(t, c.experiments, name)
+}
+
+func TestStopErrors(t *testing.T) {
+	t.Parallel()
+
+	c, _ := newFakeController(t,
+		fakeResponse{201, `{}`},
+		fakeResponse{403, statusBody(403, "Forbidden", "User cannot delete resource podchaos")},
+	)
+	name, err := c.Run(&experiments.PodKill{Mode: "one"})
+	require.NoError(t, err)
+	err = c.Stop(name)
+	require.True(t, errors.Is(err, ErrForbidden), err.Error())
+	require.Contains(t, c.experiments, name, "experiment must be kept until it's deleted")
+}
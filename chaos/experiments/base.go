@@ -5,4 +5,25 @@ type Base struct {
 	Name string
 	// Namespace is a namespace where experiment entity will be stored
 	Namespace string
+	// Selector target pods, its mode overrides Mode of experiments and its criteria are combined with
+	// LabelKey and LabelValue, so pods must match both, selector labels win over a legacy label with the same key
+	Selector *Selector
+}
+
+// SetBase sets name and namespace, selector is kept if base has none
+func (b *Base) SetBase(base Base) {
+	if base.Selector == nil {
+		base.Selector = b.Selector
+	}
+	*b = base
+}
+
+// Select returns the experiment selector merged with experiment's legacy mode and label,
+// pods are selected in the experiment namespace unless selector has namespaces
+func (b Base) Select(mode, labelKey, labelValue string) Selector {
+	return b.Selector.Merge(mode, labelKey, labelValue, b.Namespace)
+}
+
+func (b Base) base() Base {
+	return b
 }
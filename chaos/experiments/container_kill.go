@@ -9,11 +9,6 @@ type ContainerKill struct {
 	Container  string
 }
 
-// SetBase sets the base
-func (e *ContainerKill) SetBase(base Base) {
-	e.Base = base
-}
-
 // Resource returns the resource
 func (e *ContainerKill) Resource() string {
 	return "podchaos"
@@ -23,3 +18,25 @@ func (e *ContainerKill) Resource() string {
 func (e *ContainerKill) Filename() string {
 	return "container-kill.yml"
 }
+
+// Kind returns Chaosmesh CRD kind
+func (e *ContainerKill) Kind() string {
+	return "PodChaos"
+}
+
+// Validate checks experiment fields
+func (e *ContainerKill) Validate() error {
+	v := newValidation(e.Kind())
+	v.selector("", e.Select(e.Mode, e.LabelKey, e.LabelValue))
+	v.required("container", e.Container)
+	return v.err()
+}
+
+// Spec returns Chaosmesh PodChaos spec
+func (e *ContainerKill) Spec() interface{} {
+	return &PodChaosSpec{
+		PodSelectorSpec: e.Select(e.Mode, e.LabelKey, e.LabelValue).PodSelector(),
+		Action:          "container-kill",
+		ContainerNames:  []string{e.Container},
+	}
+}
@@ -1,6 +1,9 @@
 package experiments
 
-import "time"
+import (
+	"fmt"
+	"time"
+)
 
 // CPUHog struct for cpu hog testing
 type CPUHog struct {
@@ -16,11 +19,6 @@ type CPUHog struct {
 	Duration    time.Duration
 }
 
-// SetBase sets the base
-func (e *CPUHog) SetBase(base Base) {
-	e.Base = base
-}
-
 // Resource returns the resource
 func (e *CPUHog) Resource() string {
 	return "stresschaos"
@@ -30,3 +28,41 @@ func (e *CPUHog) Resource() string {
 func (e *CPUHog) Filename() string {
 	return "cpu-chaos.yml"
 }
+
+// Kind returns Chaosmesh CRD kind
+func (e *CPUHog) Kind() string {
+	return "StressChaos"
+}
+
+// Validate checks experiment fields
+func (e *CPUHog) Validate() error {
+	v := newValidation(e.Kind())
+	v.selector("", e.Select(e.Mode, e.LabelKey, e.LabelValue))
+	v.positive("workers", e.Workers)
+	v.percent("load", e.Load)
+	v.check(e.OptsCPU >= 0 && e.OptsTimeout >= 0 && e.OptsHDD >= 0, "stress-ng options must not be negative")
+	v.duration("duration", e.Duration)
+	return v.err()
+}
+
+// Spec returns Chaosmesh StressChaos spec
+func (e *CPUHog) Spec() interface{} {
+	var opts []string
+	for _, o := range []struct {
+		name  string
+		value int
+	}{{"--cpu", e.OptsCPU}, {"--timeout", e.OptsTimeout}, {"--hdd", e.OptsHDD}} {
+		if o.value > 0 {
+			opts = append(opts, fmt.Sprintf("%s %d", o.name, o.value))
+		}
+	}
+	return &StressChaosSpec{
+		PodSelectorSpec: e.Select(e.Mode, e.LabelKey, e.LabelValue).PodSelector(),
+		Stressors: Stressors{CPU: &CPUStressor{
+			Workers: e.Workers,
+			Load:    e.Load,
+			Options: opts,
+		}},
+		Duration: formatDuration(e.Duration),
+	}
+}
@@ -9,11 +9,6 @@ type DNSChaos struct {
 	Patterns []string
 }
 
-// SetBase sets the base
-func (e *DNSChaos) SetBase(base Base) {
-	e.Base = base
-}
-
 // Resource returns the resource for dns chaos
 func (e *DNSChaos) Resource() string {
 	return "dnschaos"
@@ -23,3 +18,27 @@ func (e *DNSChaos) Resource() string {
 func (e *DNSChaos) Filename() string {
 	return "dns-chaos.yml"
 }
+
+// Kind returns Chaosmesh CRD kind
+func (e *DNSChaos) Kind() string {
+	return "DNSChaos"
+}
+
+// Validate checks experiment fields
+func (e *DNSChaos) Validate() error {
+	v := newValidation(e.Kind())
+	v.selector("", e.Select(ModeAll, "", ""))
+	v.check(len(e.Patterns) > 0, "patterns are required")
+	v.duration("duration", e.Duration)
+	return v.err()
+}
+
+// Spec returns Chaosmesh DNSChaos spec
+func (e *DNSChaos) Spec() interface{} {
+	return &DNSChaosSpec{
+		PodSelectorSpec: e.Select(ModeAll, "", "").PodSelector(),
+		Action:          "error",
+		Patterns:        e.Patterns,
+		Duration:        formatDuration(e.Duration),
+	}
+}
@@ -0,0 +1,64 @@
+package experiments
+
+import "encoding/json"
+
+// APIVersion Chaosmesh CRD API version
+const APIVersion = "chaos-mesh.org/v1alpha1"
+
+// Experiment chaos experiment rendered from a template, experiments can be nested into schedules and workflows
+type Experiment interface {
+	SetBase(base Base)
+	Filename() string
+	Resource() string
+}
+
+// Validator experiment that can be checked before it's sent to API server
+type Validator interface {
+	Validate() error
+}
+
+// Builder experiment that builds its Chaosmesh spec directly, without a template
+type Builder interface {
+	Experiment
+	Validator
+	// Kind Chaosmesh CRD kind
+	Kind() string
+	// Spec Chaosmesh CRD spec, experiment must be valid
+	Spec() interface{}
+}
+
+// Metadata CRD metadata
+type Metadata struct {
+	Name      string `json:"name"`
+	Namespace string `json:"namespace,omitempty"`
+}
+
+// CRD Chaosmesh custom resource
+type CRD struct {
+	APIVersion string      `json:"apiVersion"`
+	Kind       string      `json:"kind"`
+	Metadata   Metadata    `json:"metadata"`
+	Spec       interface{} `json:"spec"`
+}
+
+// Marshal validates the experiment and marshals it as Chaosmesh custom resource JSON
+func Marshal(b Builder) ([]byte, error) {
+	if err := b.Validate(); err != nil {
+		return nil, err
+	}
+	return json.Marshal(NewCRD(b))
+}
+
+// NewCRD returns Chaosmesh custom resource of the experiment, name and namespace are taken from its Base
+func NewCRD(b Builder) *CRD {
+	var base Base
+	if e, ok := b.(interface{ base() Base }); ok {
+		base = e.base()
+	}
+	return &CRD{
+		APIVersion: APIVersion,
+		Kind:       b.Kind(),
+		Metadata:   Metadata{Name: base.Name, Namespace: base.Namespace},
+		Spec:       b.Spec(),
+	}
+}
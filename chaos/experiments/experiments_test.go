@@ -0,0 +1,447 @@
+package experiments_test
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/goplugin/helmenv/chaos/experiments"
+	"github.com/stretchr/testify/require"
+)
+
+var base = experiments.Base{Name: "chaos-1", Namespace: "env"}
+
+func marshal(t *testing.T, b experiments.Builder) map[string]interface{} {
+	b.SetBase(base)
+	data, err := experiments.Marshal(b)
+	require.NoError(t, err)
+	var crd map[string]interface{}
+	require.NoError(t, json.Unmarshal(data, &crd))
+	require.Equal(t, experiments.APIVersion, crd["apiVersion"])
+	require.Equal(t, b.Kind(), crd["kind"])
+	require.Equal(t, map[string]interface{}{"name": "chaos-1", "namespace": "env"}, crd["metadata"])
+	return crd["spec"].(map[string]interface{})
+}
+
+func requireInvalid(t *testing.T, b experiments.Builder, msgs ...string) {
+	err := b.Validate()
+	require.Error(t, err)
+	var vErr *experiments.ValidationError
+	require.True(t, errors.As(err, &vErr))
+	require.Equal(t, msgs, vErr.Errors)
+	_, err = experiments.Marshal(b)
+	require.Error(t, err)
+}
+
+// selector legacy selector of the "app: node" label in env namespace
+func selector() map[string]interface{} {
+	return map[string]interface{}{
+		"labelSelectors": map[string]interface{}{"app": "node"},
+		"namespaces":     []interface{}{"env"},
+	}
+}
+
+func TestPodKill(t *testing.T) {
+	t.Parallel()
+
+	spec := marshal(t, &experiments.PodKill{Mode: "one", LabelKey: "app", LabelValue: "node"})
+	require.Equal(t, map[string]interface{}{
+		"action":   "pod-kill",
+		"mode":     "one",
+		"selector": selector(),
+	}, spec)
+	requireInvalid(t, &experiments.PodKill{}, "mode is required")
+}
+
+func TestPodFailure(t *testing.T) {
+	t.Parallel()
+
+	spec := marshal(t, &experiments.PodFailure{Mode: "all", LabelKey: "app", LabelValue: "node", Duration: time.Minute})
+	require.Equal(t, "pod-failure", spec["action"])
+	require.Equal(t, "1m0s", spec["duration"])
+
+	spec = marshal(t, &experiments.PodFailure{Mode: "all"})
+	require.NotContains(t, spec, "duration", "zero duration lasts until experiment is deleted")
+	requireInvalid(t, &experiments.PodFailure{Mode: "all", Duration: -time.Second}, "duration must not be negative, got -1s")
+}
+
+func TestContainerKill(t *testing.T) {
+	t.Parallel()
+
+	spec := marshal(t, &experiments.ContainerKill{Mode: "one", LabelKey: "app", LabelValue: "node", Container: "node"})
+	require.Equal(t, "container-kill", spec["action"])
+	require.Equal(t, []interface{}{"node"}, spec["containerNames"])
+	requireInvalid(t, &experiments.ContainerKill{Mode: "one"}, "container is required")
+}
+
+func TestCPUHog(t *testing.T) {
+	t.Parallel()
+
+	spec := marshal(t, &experiments.CPUHog{Mode: "one", Workers: 2, Load: 80, OptsCPU: 1, Duration: time.Minute})
+	require.Equal(t, map[string]interface{}{
+		"cpu": map[string]interface{}{
+			"workers": float64(2),
+			"load":    float64(80),
+			"options": []interface{}{"--cpu 1"},
+		},
+	}, spec["stressors"])
+	requireInvalid(t, &experiments.CPUHog{Mode: "one", Load: 120},
+		"workers must be positive, got 0",
+		"load must be a percent in 0-100 range, got 120",
+	)
+}
+
+func TestDNSChaos(t *testing.T) {
+	t.Parallel()
+
+	spec := marshal(t, &experiments.DNSChaos{Patterns: []string{"google.com"}, Duration: time.Minute})
+	require.Equal(t, "error", spec["action"])
+	require.Equal(t, "all", spec["mode"])
+	require.Equal(t, []interface{}{"google.com"}, spec["patterns"])
+	require.Equal(t, map[string]interface{}{"namespaces": []interface{}{"env"}}, spec["selector"])
+	requireInvalid(t, &experiments.DNSChaos{}, "patterns are required")
+}
+
+func TestIODelay(t *testing.T) {
+	t.Parallel()
+
+	spec := marshal(t, &experiments.IODelay{
+		Mode:       "one",
+		VolumePath: "/data",
+		Path:       "/data/**/*",
+		Delay:      100 * time.Millisecond,
+		Percent:    50,
+	})
+	require.Equal(t, "latency", spec["action"])
+	require.Equal(t, "/data", spec["volumePath"])
+	require.Equal(t, "100ms", spec["delay"])
+	require.Equal(t, float64(50), spec["percent"])
+	requireInvalid(t, &experiments.IODelay{Mode: "one", Percent: -1},
+		"volume path is required",
+		"delay must be positive, got 0s",
+		"percent must be a percent in 0-100 range, got -1",
+	)
+}
+
+func TestIOFault(t *testing.T) {
+	t.Parallel()
+
+	spec := marshal(t, &experiments.IOFault{Mode: "one", VolumePath: "/data", Errno: 5, Percent: 100})
+	require.Equal(t, "fault", spec["action"])
+	require.Equal(t, float64(5), spec["errno"])
+	requireInvalid(t, &experiments.IOFault{Mode: "one", VolumePath: "/data"}, "errno must be positive, got 0")
+}
+
+func TestNetworkBandwidth(t *testing.T) {
+	t.Parallel()
+
+	spec := marshal(t, &experiments.NetworkBandwidth{Mode: "all", Rate: "1mbps", Limit: 100, Buffer: 10000})
+	require.Equal(t, "bandwidth", spec["action"])
+	require.Equal(t, map[string]interface{}{
+		"rate":   "1mbps",
+		"limit":  float64(100),
+		"buffer": float64(10000),
+	}, spec["bandwidth"])
+	requireInvalid(t, &experiments.NetworkBandwidth{Mode: "all", Rate: "1 mb", Limit: 1, Buffer: 1},
+		"rate must be a number with bps, kbps, mbps, gbps or tbps unit, got '1 mb'",
+	)
+}
+
+func TestNetworkCorrupt(t *testing.T) {
+	t.Parallel()
+
+	spec := marshal(t, &experiments.NetworkCorrupt{Mode: "all", Corrupt: 40, Correlation: 25})
+	require.Equal(t, "corrupt", spec["action"])
+	require.Equal(t, map[string]interface{}{"corrupt": "40", "correlation": "25"}, spec["corrupt"])
+	requireInvalid(t, &experiments.NetworkCorrupt{Mode: "all", Corrupt: 101}, "corrupt must be a percent in 0-100 range, got 101")
+}
+
+func TestNetworkDelay(t *testing.T) {
+	t.Parallel()
+
+	spec := marshal(t, &experiments.NetworkDelay{Mode: "all", Latency: 300 * time.Millisecond, Duration: time.Minute})
+	require.Equal(t, "delay", spec["action"])
+	require.Equal(t, map[string]interface{}{"latency": "300ms"}, spec["delay"])
+	require.Equal(t, "1m0s", spec["duration"])
+	requireInvalid(t, &experiments.NetworkDelay{Mode: "all"}, "latency must be positive, got 0s")
+}
+
+func TestNetworkDuplicate(t *testing.T) {
+	t.Parallel()
+
+	spec := marshal(t, &experiments.NetworkDuplicate{Mode: "all", Duplicate: 40, Correlation: 25})
+	require.Equal(t, "duplicate", spec["action"])
+	require.Equal(t, map[string]interface{}{"duplicate": "40", "correlation": "25"}, spec["duplicate"])
+	requireInvalid(t, &experiments.NetworkDuplicate{Mode: "all", Correlation: 200}, "correlation must be a percent in 0-100 range, got 200")
+}
+
+func TestNetworkLoss(t *testing.T) {
+	t.Parallel()
+
+	spec := marshal(t, &experiments.NetworkLoss{Mode: "all", Loss: 40, Correlation: 25})
+	require.Equal(t, "loss", spec["action"])
+	require.Equal(t, map[string]interface{}{"loss": "40", "correlation": "25"}, spec["loss"])
+	requireInvalid(t, &experiments.NetworkLoss{Mode: "all", Loss: -5}, "loss must be a percent in 0-100 range, got -5")
+}
+
+func TestNetworkPartition(t *testing.T) {
+	t.Parallel()
+
+	spec := marshal(t, &experiments.NetworkPartition{
+		FromMode:       "all",
+		FromLabelKey:   "app",
+		FromLabelValue: "node",
+		ToMode:         "all",
+		ToLabelKey:     "app",
+		ToLabelValue:   "geth",
+	})
+	require.Equal(t, "partition", spec["action"])
+	require.Equal(t, "both", spec["direction"])
+	require.Equal(t, map[string]interface{}{
+		"mode": "all",
+		"selector": map[string]interface{}{
+			"labelSelectors": map[string]interface{}{"app": "geth"},
+			"namespaces":     []interface{}{"env"},
+		},
+	}, spec["target"])
+	requireInvalid(t, &experiments.NetworkPartition{FromMode: "all"}, "target mode is required")
+}
+
+func TestTimeShift(t *testing.T) {
+	t.Parallel()
+
+	spec := marshal(t, &experiments.TimeShift{Mode: "one", TimeOffset: -time.Hour})
+	require.Equal(t, "-1h0m0s", spec["timeOffset"])
+	requireInvalid(t, &experiments.TimeShift{Mode: "one"}, "time offset is required")
+}
+
+func TestNetworkTarget(t *testing.T) {
+	t.Parallel()
+
+	spec := marshal(t, &experiments.NetworkLoss{
+		Mode:   "all",
+		Loss:   40,
+		Target: &experiments.Selector{Labels: map[string]string{"app": "geth"}},
+	})
+	require.Equal(t, "to", spec["direction"])
+	require.Equal(t, map[string]interface{}{
+		"mode": "all",
+		"selector": map[string]interface{}{
+			"labelSelectors": map[string]interface{}{"app": "geth"},
+			"namespaces":     []interface{}{"env"},
+		},
+	}, spec["target"])
+
+	spec = marshal(t, &experiments.NetworkDelay{Mode: "all", Latency: time.Second})
+	require.NotContains(t, spec, "direction")
+	require.NotContains(t, spec, "target")
+
+	requireInvalid(t, &experiments.NetworkDelay{Mode: "all", Latency: time.Second, Direction: experiments.DirectionFrom},
+		"direction from requires target",
+	)
+	requireInvalid(t, &experiments.NetworkLoss{
+		Mode:      "all",
+		Direction: "up",
+		Target:    &experiments.Selector{Mode: experiments.ModeFixed},
+	},
+		"direction up is unknown",
+		"target value must be a positive number of pods for mode fixed, got ''",
+	)
+}
+
+func TestHTTPChaos(t *testing.T) {
+	t.Parallel()
+
+	rule := experiments.HTTPRule{Mode: "all", LabelKey: "app", LabelValue: "mockserver", Port: 1080, Path: "/api/*"}
+	spec := marshal(t, &experiments.HTTPAbort{HTTPRule: rule})
+	require.Equal(t, map[string]interface{}{
+		"mode":     "all",
+		"selector": map[string]interface{}{"labelSelectors": map[string]interface{}{"app": "mockserver"}, "namespaces": []interface{}{"env"}},
+		"target":   "Request",
+		"port":     float64(1080),
+		"path":     "/api/*",
+		"abort":    true,
+	}, spec)
+
+	spec = marshal(t, &experiments.HTTPDelay{HTTPRule: rule, Delay: time.Second})
+	require.Equal(t, "1s", spec["delay"])
+
+	response := rule
+	response.Target = experiments.HTTPTargetResponse
+	spec = marshal(t, &experiments.HTTPReplace{HTTPRule: response, Code: 503, Body: []byte("unavailable")})
+	require.Equal(t, map[string]interface{}{
+		"code": float64(503),
+		"body": "dW5hdmFpbGFibGU=",
+	}, spec["replace"])
+
+	requireInvalid(t, &experiments.HTTPAbort{HTTPRule: experiments.HTTPRule{Mode: "all", Target: "Body", Path: "api"}},
+		"target Body is unknown",
+		"port must be in 1-65535 range, got 0",
+		"path must start with /",
+	)
+	requireInvalid(t, &experiments.HTTPDelay{HTTPRule: rule}, "delay must be positive, got 0s")
+	requireInvalid(t, &experiments.HTTPReplace{HTTPRule: rule}, "nothing to replace")
+	requireInvalid(t, &experiments.HTTPReplace{HTTPRule: rule, Code: 500}, "code can only be replaced in responses")
+	requireInvalid(t, &experiments.HTTPReplace{HTTPRule: response, ReplacePath: "/health"},
+		"path, method and queries can only be replaced in requests",
+	)
+}
+
+func TestMemoryStress(t *testing.T) {
+	t.Parallel()
+
+	spec := marshal(t, &experiments.MemoryStress{Mode: "one", Workers: 1, Size: "256MB", Duration: time.Minute})
+	require.Equal(t, map[string]interface{}{
+		"memory": map[string]interface{}{"workers": float64(1), "size": "256MB"},
+	}, spec["stressors"])
+	marshal(t, &experiments.MemoryStress{Mode: "one", Workers: 1, Size: "50%"})
+	requireInvalid(t, &experiments.MemoryStress{Mode: "one", Workers: 1, Size: "lots"},
+		"size must be an amount like 256MB or a percent like 50%, got 'lots'",
+	)
+}
+
+func TestKernelFault(t *testing.T) {
+	t.Parallel()
+
+	spec := marshal(t, &experiments.KernelFault{
+		Mode:        "one",
+		Callchain:   []string{"__x64_sys_mount"},
+		FailType:    experiments.KernelFailSlab,
+		Probability: 50,
+	})
+	require.Equal(t, map[string]interface{}{
+		"callchain":   []interface{}{map[string]interface{}{"funcname": "__x64_sys_mount"}},
+		"failtype":    float64(0),
+		"probability": float64(50),
+	}, spec["failKernRequest"])
+	requireInvalid(t, &experiments.KernelFault{Mode: "one", FailType: 3, Probability: 120},
+		"fail type 3 is unknown",
+		"probability must be a percent in 0-100 range, got 120",
+	)
+}
+
+func TestJVMChaos(t *testing.T) {
+	t.Parallel()
+
+	spec := marshal(t, &experiments.JVMChaos{
+		Mode:    "one",
+		Action:  experiments.JVMActionLatency,
+		Class:   "Main",
+		Method:  "handle",
+		Port:    9277,
+		Latency: time.Second,
+	})
+	require.Equal(t, "latency", spec["action"])
+	require.Equal(t, float64(1000), spec["latency"])
+
+	spec = marshal(t, &experiments.JVMChaos{Mode: "one", Action: experiments.JVMActionReturn, Class: "Main", Method: "ok", Return: "false"})
+	require.Equal(t, "false", spec["value"])
+
+	requireInvalid(t, &experiments.JVMChaos{Mode: "one", Action: experiments.JVMActionException},
+		"class is required",
+		"method is required",
+		"exception is required",
+	)
+	requireInvalid(t, &experiments.JVMChaos{
+		Base:   experiments.Base{Selector: &experiments.Selector{Mode: experiments.ModeFixed, Value: "1"}},
+		Action: experiments.JVMActionReturn,
+		Class:  "Main",
+		Method: "ok",
+		Return: "false",
+	}, "return action can't be used with mode fixed")
+	requireInvalid(t, &experiments.JVMChaos{Mode: "one", Action: experiments.JVMActionStress}, "cpu count or memory type is required")
+	requireInvalid(t, &experiments.JVMChaos{Mode: "one"}, "action is required")
+}
+
+func TestSelectorWithLegacyFields(t *testing.T) {
+	t.Parallel()
+
+	spec := marshal(t, &experiments.PodKill{
+		Base: experiments.Base{Selector: &experiments.Selector{
+			Mode:   experiments.ModeAll,
+			Labels: map[string]string{"instance": "1"},
+		}},
+		Mode:       experiments.ModeOne,
+		LabelKey:   "app",
+		LabelValue: "node",
+	})
+	require.Equal(t, "all", spec["mode"])
+	require.Equal(t, map[string]interface{}{
+		"labelSelectors": map[string]interface{}{"app": "node", "instance": "1"},
+		"namespaces":     []interface{}{"env"},
+	}, spec["selector"])
+
+	spec = marshal(t, &experiments.PodKill{
+		Base:       experiments.Base{Selector: &experiments.Selector{Labels: map[string]string{"app": "geth"}}},
+		Mode:       experiments.ModeOne,
+		LabelKey:   "app",
+		LabelValue: "node",
+	})
+	require.Equal(t, "one", spec["mode"])
+	require.Equal(t, map[string]interface{}{"app": "geth"}, spec["selector"].(map[string]interface{})["labelSelectors"])
+}
+
+func TestSelectorValidation(t *testing.T) {
+	t.Parallel()
+
+	for _, test := range []struct {
+		selector experiments.Selector
+		err      string
+	}{
+		{experiments.Selector{Mode: "any"}, "mode any is unknown"},
+		{experiments.Selector{Mode: experiments.ModeOne, Value: "1"}, "value must be empty for mode one"},
+		{experiments.Selector{Mode: experiments.ModeFixed, Value: "0"}, "value must be a positive number of pods for mode fixed, got '0'"},
+		{experiments.Selector{Mode: experiments.ModeFixedPercent, Value: "150"}, "value must be a percent in 1-100 range for mode fixed-percent, got '150'"},
+		{experiments.Selector{Mode: experiments.ModeRandomMaxPercent}, "value must be a percent in 1-100 range for mode random-max-percent, got ''"},
+		{experiments.Selector{Mode: experiments.ModeAll, Labels: map[string]string{"": "node"}}, "label key must not be empty"},
+		{
+			experiments.Selector{Mode: experiments.ModeAll, Expressions: []experiments.Expression{{Key: "instance", Operator: experiments.OpIn}}},
+			"expression instance In requires values",
+		},
+		{
+			experiments.Selector{Mode: experiments.ModeAll, Expressions: []experiments.Expression{{Key: "instance", Operator: "Is"}}},
+			"expression instance operator Is is unknown",
+		},
+	} {
+		sel := test.selector
+		requireInvalid(t, &experiments.PodKill{Base: experiments.Base{Selector: &sel}}, test.err)
+	}
+
+	spec := marshal(t, &experiments.PodKill{Base: experiments.Base{Selector: &experiments.Selector{
+		Mode:  experiments.ModeFixedPercent,
+		Value: "50",
+	}}})
+	require.Equal(t, "50", spec["value"])
+}
+
+func TestScheduleValidation(t *testing.T) {
+	t.Parallel()
+
+	s := &experiments.Schedule{ConcurrencyPolicy: "Replace", Experiment: &experiments.NetworkDelay{Mode: "all"}}
+	require.EqualError(t, s.Validate(), "invalid Schedule experiment: cron is required; "+
+		"concurrency policy Replace is unknown; "+
+		"experiment: invalid NetworkChaos experiment: latency must be positive, got 0s")
+	s = &experiments.Schedule{Cron: "@every 1m", Experiment: &experiments.PodKill{Mode: "one"}}
+	require.NoError(t, s.Validate())
+}
+
+func TestWorkflowValidation(t *testing.T) {
+	t.Parallel()
+
+	w := &experiments.Workflow{
+		Entry: "start",
+		Steps: []experiments.WorkflowStep{
+			experiments.Serial("entry", "kill", "missing"),
+			experiments.Chaos("kill", time.Minute, &experiments.PodKill{}),
+			experiments.Suspend("pause", 0),
+			{Name: "pause"},
+		},
+	}
+	require.EqualError(t, w.Validate(), "invalid Workflow experiment: step pause is duplicated; "+
+		"entry step start not found; "+
+		"child missing of step entry not found; "+
+		"step kill: invalid PodChaos experiment: mode is required; "+
+		"suspend step pause requires deadline; "+
+		"step pause has neither type nor experiment")
+}
@@ -0,0 +1,188 @@
+package experiments
+
+import (
+	"strings"
+	"time"
+)
+
+const (
+	// HTTPTargetRequest experiment affects requests
+	HTTPTargetRequest = "Request"
+	// HTTPTargetResponse experiment affects responses
+	HTTPTargetResponse = "Response"
+)
+
+// HTTPRule requests or responses affected by HTTP chaos, served on the port and matching method and path
+type HTTPRule struct {
+	Mode       string
+	LabelKey   string
+	LabelValue string
+	// Target HTTPTargetRequest or HTTPTargetResponse, defaults to requests
+	Target string
+	Port   int
+	// Method matched HTTP method, every method by default
+	Method string
+	// Path matched path, supports wildcards like /api/*, every path by default
+	Path     string
+	Duration time.Duration
+}
+
+// HTTPAbort struct for aborting HTTP connections
+type HTTPAbort struct {
+	Base
+	HTTPRule
+}
+
+// HTTPDelay struct for delaying HTTP requests or responses
+type HTTPDelay struct {
+	Base
+	HTTPRule
+	Delay time.Duration
+}
+
+// HTTPReplace struct for replacing parts of HTTP requests or responses
+type HTTPReplace struct {
+	Base
+	HTTPRule
+	// ReplacePath and ReplaceMethod replace request path and method
+	ReplacePath   string
+	ReplaceMethod string
+	// Code replaces response code
+	Code    int
+	Body    []byte
+	Headers map[string]string
+	// Queries replace request query parameters
+	Queries map[string]string
+}
+
+// Resource returns the resource
+func (e *HTTPAbort) Resource() string {
+	return "httpchaos"
+}
+
+// Filename returns the file name for http abort
+func (e *HTTPAbort) Filename() string {
+	return "http-abort.yml"
+}
+
+// Kind returns Chaosmesh CRD kind
+func (e *HTTPAbort) Kind() string {
+	return "HTTPChaos"
+}
+
+// Validate checks experiment fields
+func (e *HTTPAbort) Validate() error {
+	v := newValidation(e.Kind())
+	e.HTTPRule.validate(v, e.Base)
+	return v.err()
+}
+
+// Spec returns Chaosmesh HTTPChaos spec
+func (e *HTTPAbort) Spec() interface{} {
+	spec := e.HTTPRule.spec(e.Base)
+	spec.Abort = true
+	return spec
+}
+
+// Resource returns the resource
+func (e *HTTPDelay) Resource() string {
+	return "httpchaos"
+}
+
+// Filename returns the file name for http delay
+func (e *HTTPDelay) Filename() string {
+	return "http-delay.yml"
+}
+
+// Kind returns Chaosmesh CRD kind
+func (e *HTTPDelay) Kind() string {
+	return "HTTPChaos"
+}
+
+// Validate checks experiment fields
+func (e *HTTPDelay) Validate() error {
+	v := newValidation(e.Kind())
+	e.HTTPRule.validate(v, e.Base)
+	v.check(e.Delay > 0, "delay must be positive, got %s", e.Delay)
+	return v.err()
+}
+
+// Spec returns Chaosmesh HTTPChaos spec
+func (e *HTTPDelay) Spec() interface{} {
+	spec := e.HTTPRule.spec(e.Base)
+	spec.Delay = e.Delay.String()
+	return spec
+}
+
+// Resource returns the resource
+func (e *HTTPReplace) Resource() string {
+	return "httpchaos"
+}
+
+// Filename returns the file name for http replace
+func (e *HTTPReplace) Filename() string {
+	return "http-replace.yml"
+}
+
+// Kind returns Chaosmesh CRD kind
+func (e *HTTPReplace) Kind() string {
+	return "HTTPChaos"
+}
+
+// Validate checks experiment fields, request parts can only be replaced in requests and response code in responses
+func (e *HTTPReplace) Validate() error {
+	v := newValidation(e.Kind())
+	e.HTTPRule.validate(v, e.Base)
+	response := e.Target == HTTPTargetResponse
+	v.check(
+		len(e.ReplacePath) > 0 || len(e.ReplaceMethod) > 0 || e.Code != 0 ||
+			e.Body != nil || len(e.Headers) > 0 || len(e.Queries) > 0,
+		"nothing to replace",
+	)
+	v.check(!response || (len(e.ReplacePath) == 0 && len(e.ReplaceMethod) == 0 && len(e.Queries) == 0),
+		"path, method and queries can only be replaced in requests")
+	v.check(response || e.Code == 0, "code can only be replaced in responses")
+	v.check(e.Code == 0 || (e.Code >= 100 && e.Code <= 599), "code must be a HTTP status code, got %d", e.Code)
+	v.check(len(e.ReplacePath) == 0 || strings.HasPrefix(e.ReplacePath, "/"), "replace path must start with /")
+	return v.err()
+}
+
+// Spec returns Chaosmesh HTTPChaos spec
+func (e *HTTPReplace) Spec() interface{} {
+	spec := e.HTTPRule.spec(e.Base)
+	spec.Replace = &HTTPReplaceSpec{
+		Path:    e.ReplacePath,
+		Method:  e.ReplaceMethod,
+		Code:    e.Code,
+		Body:    e.Body,
+		Headers: e.Headers,
+		Queries: e.Queries,
+	}
+	return spec
+}
+
+func (r HTTPRule) target() string {
+	if len(r.Target) == 0 {
+		return HTTPTargetRequest
+	}
+	return r.Target
+}
+
+func (r HTTPRule) validate(v *validation, base Base) {
+	v.selector("", base.Select(r.Mode, r.LabelKey, r.LabelValue))
+	v.check(r.target() == HTTPTargetRequest || r.target() == HTTPTargetResponse, "target %s is unknown", r.Target)
+	v.check(r.Port > 0 && r.Port <= 65535, "port must be in 1-65535 range, got %d", r.Port)
+	v.check(len(r.Path) == 0 || strings.HasPrefix(r.Path, "/"), "path must start with /")
+	v.duration("duration", r.Duration)
+}
+
+func (r HTTPRule) spec(base Base) *HTTPChaosSpec {
+	return &HTTPChaosSpec{
+		PodSelectorSpec: base.Select(r.Mode, r.LabelKey, r.LabelValue).PodSelector(),
+		Target:          r.target(),
+		Port:            r.Port,
+		Method:          r.Method,
+		Path:            r.Path,
+		Duration:        formatDuration(r.Duration),
+	}
+}
@@ -15,11 +15,6 @@ type IODelay struct {
 	Duration   time.Duration
 }
 
-// SetBase sets the base
-func (e *IODelay) SetBase(base Base) {
-	e.Base = base
-}
-
 // Resource returns the resource
 func (e *IODelay) Resource() string {
 	return "iochaos"
@@ -29,3 +24,32 @@ func (e *IODelay) Resource() string {
 func (e *IODelay) Filename() string {
 	return "io-delay.yml"
 }
+
+// Kind returns Chaosmesh CRD kind
+func (e *IODelay) Kind() string {
+	return "IOChaos"
+}
+
+// Validate checks experiment fields
+func (e *IODelay) Validate() error {
+	v := newValidation(e.Kind())
+	v.selector("", e.Select(e.Mode, e.LabelKey, e.LabelValue))
+	v.required("volume path", e.VolumePath)
+	v.check(e.Delay > 0, "delay must be positive, got %s", e.Delay)
+	v.percent("percent", e.Percent)
+	v.duration("duration", e.Duration)
+	return v.err()
+}
+
+// Spec returns Chaosmesh IOChaos spec
+func (e *IODelay) Spec() interface{} {
+	return &IOChaosSpec{
+		PodSelectorSpec: e.Select(e.Mode, e.LabelKey, e.LabelValue).PodSelector(),
+		Action:          "latency",
+		VolumePath:      e.VolumePath,
+		Path:            e.Path,
+		Delay:           e.Delay.String(),
+		Percent:         e.Percent,
+		Duration:        formatDuration(e.Duration),
+	}
+}
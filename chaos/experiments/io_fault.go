@@ -15,11 +15,6 @@ type IOFault struct {
 	Duration   time.Duration
 }
 
-// SetBase sets the base
-func (e *IOFault) SetBase(base Base) {
-	e.Base = base
-}
-
 // Resource returns the resource
 func (e *IOFault) Resource() string {
 	return "iochaos"
@@ -29,3 +24,32 @@ func (e *IOFault) Resource() string {
 func (e *IOFault) Filename() string {
 	return "io-fault.yml"
 }
+
+// Kind returns Chaosmesh CRD kind
+func (e *IOFault) Kind() string {
+	return "IOChaos"
+}
+
+// Validate checks experiment fields
+func (e *IOFault) Validate() error {
+	v := newValidation(e.Kind())
+	v.selector("", e.Select(e.Mode, e.LabelKey, e.LabelValue))
+	v.required("volume path", e.VolumePath)
+	v.positive("errno", e.Errno)
+	v.percent("percent", e.Percent)
+	v.duration("duration", e.Duration)
+	return v.err()
+}
+
+// Spec returns Chaosmesh IOChaos spec
+func (e *IOFault) Spec() interface{} {
+	return &IOChaosSpec{
+		PodSelectorSpec: e.Select(e.Mode, e.LabelKey, e.LabelValue).PodSelector(),
+		Action:          "fault",
+		VolumePath:      e.VolumePath,
+		Path:            e.Path,
+		Errno:           e.Errno,
+		Percent:         e.Percent,
+		Duration:        formatDuration(e.Duration),
+	}
+}
@@ -0,0 +1,111 @@
+package experiments
+
+import "time"
+
+const (
+	// JVMActionLatency delays the method
+	JVMActionLatency = "latency"
+	// JVMActionException throws Exception from the method
+	JVMActionException = "exception"
+	// JVMActionReturn returns Return from the method
+	JVMActionReturn = "return"
+	// JVMActionStress stresses CPU or memory of the JVM
+	JVMActionStress = "stress"
+	// JVMActionGC triggers garbage collection
+	JVMActionGC = "gc"
+)
+
+// JVMChaos struct for JVM faults injected with the Chaosmesh byteman agent
+type JVMChaos struct {
+	Base
+	Mode       string
+	LabelKey   string
+	LabelValue string
+	// Action JVMActionLatency, JVMActionException, JVMActionReturn, JVMActionStress or JVMActionGC
+	Action string
+	// Class and Method the fault is injected into, required for latency, exception and return
+	Class  string
+	Method string
+	// Port of the agent, Chaosmesh uses 9277 by default
+	Port    int
+	Latency time.Duration
+	// Exception thrown exception, for example java.io.IOException("BOOM")
+	Exception string
+	// Return returned value
+	Return string
+	// CPUCount and MemoryType ("stack" or "heap") of stress action, one of them is required
+	CPUCount   int
+	MemoryType string
+	Duration   time.Duration
+}
+
+// Resource returns the resource
+func (e *JVMChaos) Resource() string {
+	return "jvmchaos"
+}
+
+// Filename returns the file name for jvm chaos
+func (e *JVMChaos) Filename() string {
+	return "jvm-chaos.yml"
+}
+
+// Kind returns Chaosmesh CRD kind
+func (e *JVMChaos) Kind() string {
+	return "JVMChaos"
+}
+
+// Validate checks fields required by the action
+func (e *JVMChaos) Validate() error {
+	v := newValidation(e.Kind())
+	sel := e.Select(e.Mode, e.LabelKey, e.LabelValue)
+	v.selector("", sel)
+	v.check(e.Port >= 0 && e.Port <= 65535, "port must be in 1-65535 range, got %d", e.Port)
+	switch e.Action {
+	case JVMActionLatency, JVMActionException, JVMActionReturn:
+		v.required("class", e.Class)
+		v.required("method", e.Method)
+	}
+	switch e.Action {
+	case JVMActionLatency:
+		v.check(e.Latency >= time.Millisecond, "latency must be at least 1ms, got %s", e.Latency)
+	case JVMActionException:
+		v.required("exception", e.Exception)
+	case JVMActionReturn:
+		v.required("return", e.Return)
+		// Chaosmesh reads both the return value and the selector value from the same field
+		v.check(len(sel.Value) == 0, "return action can't be used with mode %s", sel.Mode)
+	case JVMActionStress:
+		v.check(e.CPUCount > 0 || len(e.MemoryType) > 0, "cpu count or memory type is required")
+		v.check(e.MemoryType == "" || e.MemoryType == "stack" || e.MemoryType == "heap",
+			"memory type must be stack or heap, got %s", e.MemoryType)
+	case JVMActionGC:
+	case "":
+		v.check(false, "action is required")
+	default:
+		v.check(false, "action %s is unknown", e.Action)
+	}
+	v.duration("duration", e.Duration)
+	return v.err()
+}
+
+// Spec returns Chaosmesh JVMChaos spec
+func (e *JVMChaos) Spec() interface{} {
+	sel := e.Select(e.Mode, e.LabelKey, e.LabelValue).PodSelector()
+	value := sel.Value
+	if e.Action == JVMActionReturn {
+		value = e.Return
+	}
+	return &JVMChaosSpec{
+		PodSelectorSpec: sel,
+		Action:          e.Action,
+		Class:           e.Class,
+		Method:          e.Method,
+		Port:            e.Port,
+		Latency:         e.Latency.Milliseconds(),
+		Exception:       e.Exception,
+		Value:           value,
+		CPUCount:        e.CPUCount,
+		MemoryType:      e.MemoryType,
+		Duration:        formatDuration(e.Duration),
+	}
+}
@@ -0,0 +1,79 @@
+package experiments
+
+import "time"
+
+const (
+	// KernelFailSlab fails slab allocations
+	KernelFailSlab = 0
+	// KernelFailBio fails block IO
+	KernelFailBio = 1
+	// KernelFailPage fails page allocations
+	KernelFailPage = 2
+)
+
+// KernelFault struct for kernel fault injection testing, requires Chaosmesh kernel chaos support on nodes
+type KernelFault struct {
+	Base
+	Mode       string
+	LabelKey   string
+	LabelValue string
+	// Callchain kernel functions the fault is injected under, for example __x64_sys_mount
+	Callchain []string
+	// FailType KernelFailSlab, KernelFailBio or KernelFailPage
+	FailType int
+	// Headers kernel headers used in predicates
+	Headers []string
+	// Probability percent of failed calls
+	Probability int
+	// Times max amount of failures, zero means unlimited
+	Times    int
+	Duration time.Duration
+}
+
+// Resource returns the resource
+func (e *KernelFault) Resource() string {
+	return "kernelchaos"
+}
+
+// Filename returns the file name for kernel fault
+func (e *KernelFault) Filename() string {
+	return "kernel-fault.yml"
+}
+
+// Kind returns Chaosmesh CRD kind
+func (e *KernelFault) Kind() string {
+	return "KernelChaos"
+}
+
+// Validate checks experiment fields
+func (e *KernelFault) Validate() error {
+	v := newValidation(e.Kind())
+	v.selector("", e.Select(e.Mode, e.LabelKey, e.LabelValue))
+	v.check(e.FailType >= KernelFailSlab && e.FailType <= KernelFailPage, "fail type %d is unknown", e.FailType)
+	v.percent("probability", e.Probability)
+	v.check(e.Times >= 0, "times must not be negative, got %d", e.Times)
+	for _, f := range e.Callchain {
+		v.check(len(f) > 0, "callchain function must not be empty")
+	}
+	v.duration("duration", e.Duration)
+	return v.err()
+}
+
+// Spec returns Chaosmesh KernelChaos spec
+func (e *KernelFault) Spec() interface{} {
+	frames := make([]Frame, 0, len(e.Callchain))
+	for _, f := range e.Callchain {
+		frames = append(frames, Frame{Funcname: f})
+	}
+	return &KernelChaosSpec{
+		PodSelectorSpec: e.Select(e.Mode, e.LabelKey, e.LabelValue).PodSelector(),
+		FailKernRequest: FailKernRequest{
+			Callchain:   frames,
+			FailType:    e.FailType,
+			Headers:     e.Headers,
+			Probability: e.Probability,
+			Times:       e.Times,
+		},
+		Duration: formatDuration(e.Duration),
+	}
+}
@@ -0,0 +1,58 @@
+package experiments
+
+import (
+	"regexp"
+	"time"
+)
+
+// memorySize stress-ng memory size, for example 256MB or 50%
+var memorySize = regexp.MustCompile(`^(\d+(B|KB|MB|GB|TB|KiB|MiB|GiB|TiB)|\d{1,3}%)$`)
+
+// MemoryStress struct for memory stress testing
+type MemoryStress struct {
+	Base
+	Mode       string
+	LabelKey   string
+	LabelValue string
+	Workers    int
+	// Size memory allocated by every worker, like 256MB or 50% of total memory
+	Size     string
+	Duration time.Duration
+}
+
+// Resource returns the resource
+func (e *MemoryStress) Resource() string {
+	return "stresschaos"
+}
+
+// Filename returns the file name for memory stress
+func (e *MemoryStress) Filename() string {
+	return "memory-stress.yml"
+}
+
+// Kind returns Chaosmesh CRD kind
+func (e *MemoryStress) Kind() string {
+	return "StressChaos"
+}
+
+// Validate checks experiment fields
+func (e *MemoryStress) Validate() error {
+	v := newValidation(e.Kind())
+	v.selector("", e.Select(e.Mode, e.LabelKey, e.LabelValue))
+	v.positive("workers", e.Workers)
+	v.check(memorySize.MatchString(e.Size), "size must be an amount like 256MB or a percent like 50%%, got '%s'", e.Size)
+	v.duration("duration", e.Duration)
+	return v.err()
+}
+
+// Spec returns Chaosmesh StressChaos spec
+func (e *MemoryStress) Spec() interface{} {
+	return &StressChaosSpec{
+		PodSelectorSpec: e.Select(e.Mode, e.LabelKey, e.LabelValue).PodSelector(),
+		Stressors: Stressors{Memory: &MemoryStressor{
+			Workers: e.Workers,
+			Size:    e.Size,
+		}},
+		Duration: formatDuration(e.Duration),
+	}
+}
@@ -0,0 +1,40 @@
+package experiments
+
+const (
+	// DirectionTo chaos affects packets sent to the target
+	DirectionTo = "to"
+	// DirectionFrom chaos affects packets received from the target
+	DirectionFrom = "from"
+	// DirectionBoth chaos affects packets in both directions
+	DirectionBoth = "both"
+)
+
+// target checks direction and target of network chaos, from and both directions require a target
+func (v *validation) target(direction string, target *Selector, namespace string) {
+	switch direction {
+	case "", DirectionTo:
+	case DirectionFrom, DirectionBoth:
+		v.check(target != nil, "direction %s requires target", direction)
+	default:
+		v.check(false, "direction %s is unknown", direction)
+	}
+	if target != nil {
+		v.selector("target ", target.Merge(ModeAll, "", "", namespace))
+	}
+}
+
+// networkTarget sets direction and target of network chaos spec, direction defaults to DirectionTo
+// and target mode to ModeAll
+func networkTarget(spec *NetworkChaosSpec, direction string, target *Selector, namespace string) {
+	if len(direction) == 0 && target == nil {
+		return
+	}
+	spec.Direction = direction
+	if len(spec.Direction) == 0 {
+		spec.Direction = DirectionTo
+	}
+	if target != nil {
+		t := target.Merge(ModeAll, "", "", namespace).PodSelector()
+		spec.Target = &t
+	}
+}
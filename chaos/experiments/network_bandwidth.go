@@ -1,8 +1,6 @@
 package experiments
 
-import (
-	"time"
-)
+import "time"
 
 // NetworkBandwidth struct with objects for NetworkConfig Bandwidth testing
 type NetworkBandwidth struct {
@@ -19,11 +17,6 @@ type NetworkBandwidth struct {
 	Duration time.Duration
 }
 
-// SetBase sets the base
-func (e *NetworkBandwidth) SetBase(base Base) {
-	e.Base = base
-}
-
 // Resource returns the resource
 func (e *NetworkBandwidth) Resource() string {
 	return "networkchaos"
@@ -33,3 +26,36 @@ func (e *NetworkBandwidth) Resource() string {
 func (e *NetworkBandwidth) Filename() string {
 	return "network-bandwidth.yml"
 }
+
+// Kind returns Chaosmesh CRD kind
+func (e *NetworkBandwidth) Kind() string {
+	return "NetworkChaos"
+}
+
+// Validate checks experiment fields
+func (e *NetworkBandwidth) Validate() error {
+	v := newValidation(e.Kind())
+	v.selector("", e.Select(e.Mode, e.LabelKey, e.LabelValue))
+	v.check(bandwidthRate.MatchString(e.Rate), "rate must be a number with bps, kbps, mbps, gbps or tbps unit, got '%s'", e.Rate)
+	v.positive("limit", e.Limit)
+	v.positive("buffer", e.Buffer)
+	v.check(e.PeakRate >= 0 && e.MinBurst >= 0, "peak rate and min burst must not be negative")
+	v.duration("duration", e.Duration)
+	return v.err()
+}
+
+// Spec returns Chaosmesh NetworkChaos spec
+func (e *NetworkBandwidth) Spec() interface{} {
+	return &NetworkChaosSpec{
+		PodSelectorSpec: e.Select(e.Mode, e.LabelKey, e.LabelValue).PodSelector(),
+		Action:          "bandwidth",
+		Bandwidth: &BandwidthSpec{
+			Rate:     e.Rate,
+			Limit:    e.Limit,
+			Buffer:   e.Buffer,
+			PeakRate: e.PeakRate,
+			MinBurst: e.MinBurst,
+		},
+		Duration: formatDuration(e.Duration),
+	}
+}
@@ -1,6 +1,7 @@
 package experiments
 
 import (
+	"strconv"
 	"time"
 )
 
@@ -15,11 +16,6 @@ type NetworkCorrupt struct {
 	Duration    time.Duration
 }
 
-// SetBase sets the base
-func (e *NetworkCorrupt) SetBase(base Base) {
-	e.Base = base
-}
-
 // Resource returns the resource
 func (e *NetworkCorrupt) Resource() string {
 	return "networkchaos"
@@ -29,3 +25,31 @@ func (e *NetworkCorrupt) Resource() string {
 func (e *NetworkCorrupt) Filename() string {
 	return "network-corrupt.yml"
 }
+
+// Kind returns Chaosmesh CRD kind
+func (e *NetworkCorrupt) Kind() string {
+	return "NetworkChaos"
+}
+
+// Validate checks experiment fields
+func (e *NetworkCorrupt) Validate() error {
+	v := newValidation(e.Kind())
+	v.selector("", e.Select(e.Mode, e.LabelKey, e.LabelValue))
+	v.percent("corrupt", e.Corrupt)
+	v.percent("correlation", e.Correlation)
+	v.duration("duration", e.Duration)
+	return v.err()
+}
+
+// Spec returns Chaosmesh NetworkChaos spec
+func (e *NetworkCorrupt) Spec() interface{} {
+	return &NetworkChaosSpec{
+		PodSelectorSpec: e.Select(e.Mode, e.LabelKey, e.LabelValue).PodSelector(),
+		Action:          "corrupt",
+		Corrupt: &CorruptSpec{
+			Corrupt:     strconv.Itoa(e.Corrupt),
+			Correlation: strconv.Itoa(e.Correlation),
+		},
+		Duration: formatDuration(e.Duration),
+	}
+}
@@ -1,8 +1,6 @@
 package experiments
 
-import (
-	"time"
-)
+import "time"
 
 // NetworkDelay stuct containing definitions for a network delay
 type NetworkDelay struct {
@@ -12,11 +10,10 @@ type NetworkDelay struct {
 	LabelValue string
 	Latency    time.Duration
 	Duration   time.Duration
-}
-
-// SetBase sets the base
-func (e *NetworkDelay) SetBase(base Base) {
-	e.Base = base
+	// Direction DirectionTo, DirectionFrom or DirectionBoth, relative to Target
+	Direction string
+	// Target pods on the other side of affected connections, every connection is affected by default
+	Target *Selector
 }
 
 // Resource returns the resource
@@ -28,3 +25,30 @@ func (e *NetworkDelay) Resource() string {
 func (e *NetworkDelay) Filename() string {
 	return "network-delay.yml"
 }
+
+// Kind returns Chaosmesh CRD kind
+func (e *NetworkDelay) Kind() string {
+	return "NetworkChaos"
+}
+
+// Validate checks experiment fields
+func (e *NetworkDelay) Validate() error {
+	v := newValidation(e.Kind())
+	v.selector("", e.Select(e.Mode, e.LabelKey, e.LabelValue))
+	v.check(e.Latency > 0, "latency must be positive, got %s", e.Latency)
+	v.duration("duration", e.Duration)
+	v.target(e.Direction, e.Target, e.Namespace)
+	return v.err()
+}
+
+// Spec returns Chaosmesh NetworkChaos spec
+func (e *NetworkDelay) Spec() interface{} {
+	spec := &NetworkChaosSpec{
+		PodSelectorSpec: e.Select(e.Mode, e.LabelKey, e.LabelValue).PodSelector(),
+		Action:          "delay",
+		Delay:           &DelaySpec{Latency: e.Latency.String()},
+		Duration:        formatDuration(e.Duration),
+	}
+	networkTarget(spec, e.Direction, e.Target, e.Namespace)
+	return spec
+}
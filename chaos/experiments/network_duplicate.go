@@ -1,6 +1,9 @@
 package experiments
 
-import "time"
+import (
+	"strconv"
+	"time"
+)
 
 // NetworkDuplicate struct contains objects for NetworkConfig Duplication testing
 type NetworkDuplicate struct {
@@ -13,11 +16,6 @@ type NetworkDuplicate struct {
 	Duration    time.Duration
 }
 
-// SetBase sets the base
-func (e *NetworkDuplicate) SetBase(base Base) {
-	e.Base = base
-}
-
 // Resource returns the resource
 func (e *NetworkDuplicate) Resource() string {
 	return "networkchaos"
@@ -27,3 +25,31 @@ func (e *NetworkDuplicate) Resource() string {
 func (e *NetworkDuplicate) Filename() string {
 	return "network-duplicate.yml"
 }
+
+// Kind returns Chaosmesh CRD kind
+func (e *NetworkDuplicate) Kind() string {
+	return "NetworkChaos"
+}
+
+// Validate checks experiment fields
+func (e *NetworkDuplicate) Validate() error {
+	v := newValidation(e.Kind())
+	v.selector("", e.Select(e.Mode, e.LabelKey, e.LabelValue))
+	v.percent("duplicate", e.Duplicate)
+	v.percent("correlation", e.Correlation)
+	v.duration("duration", e.Duration)
+	return v.err()
+}
+
+// Spec returns Chaosmesh NetworkChaos spec
+func (e *NetworkDuplicate) Spec() interface{} {
+	return &NetworkChaosSpec{
+		PodSelectorSpec: e.Select(e.Mode, e.LabelKey, e.LabelValue).PodSelector(),
+		Action:          "duplicate",
+		Duplicate: &DuplicateSpec{
+			Duplicate:   strconv.Itoa(e.Duplicate),
+			Correlation: strconv.Itoa(e.Correlation),
+		},
+		Duration: formatDuration(e.Duration),
+	}
+}
@@ -1,6 +1,9 @@
 package experiments
 
-import "time"
+import (
+	"strconv"
+	"time"
+)
 
 // NetworkLoss struct with objects for NetworkConfig Loss testing
 type NetworkLoss struct {
@@ -11,11 +14,10 @@ type NetworkLoss struct {
 	Loss        int
 	Correlation int
 	Duration    time.Duration
-}
-
-// SetBase sets the base
-func (e *NetworkLoss) SetBase(base Base) {
-	e.Base = base
+	// Direction DirectionTo, DirectionFrom or DirectionBoth, relative to Target
+	Direction string
+	// Target pods on the other side of affected connections, every connection is affected by default
+	Target *Selector
 }
 
 // Resource returns the resource
@@ -27,3 +29,34 @@ func (e *NetworkLoss) Resource() string {
 func (e *NetworkLoss) Filename() string {
 	return "network-loss.yml"
 }
+
+// Kind returns Chaosmesh CRD kind
+func (e *NetworkLoss) Kind() string {
+	return "NetworkChaos"
+}
+
+// Validate checks experiment fields
+func (e *NetworkLoss) Validate() error {
+	v := newValidation(e.Kind())
+	v.selector("", e.Select(e.Mode, e.LabelKey, e.LabelValue))
+	v.percent("loss", e.Loss)
+	v.percent("correlation", e.Correlation)
+	v.duration("duration", e.Duration)
+	v.target(e.Direction, e.Target, e.Namespace)
+	return v.err()
+}
+
+// Spec returns Chaosmesh NetworkChaos spec
+func (e *NetworkLoss) Spec() interface{} {
+	spec := &NetworkChaosSpec{
+		PodSelectorSpec: e.Select(e.Mode, e.LabelKey, e.LabelValue).PodSelector(),
+		Action:          "loss",
+		Loss: &LossSpec{
+			Loss:        strconv.Itoa(e.Loss),
+			Correlation: strconv.Itoa(e.Correlation),
+		},
+		Duration: formatDuration(e.Duration),
+	}
+	networkTarget(spec, e.Direction, e.Target, e.Namespace)
+	return spec
+}
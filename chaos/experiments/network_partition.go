@@ -9,11 +9,8 @@ type NetworkPartition struct {
 	ToMode         string
 	ToLabelKey     string
 	ToLabelValue   string
-}
-
-// SetBase sets the base
-func (e *NetworkPartition) SetBase(base Base) {
-	e.Base = base
+	// To target pods selector, overrides ToMode, ToLabelKey and ToLabelValue
+	To *Selector
 }
 
 // Resource is a CRD resource that can be found in spec.names.singular
@@ -25,3 +22,27 @@ func (e *NetworkPartition) Resource() string {
 func (e *NetworkPartition) Filename() string {
 	return "network-partition.yml"
 }
+
+// Kind returns Chaosmesh CRD kind
+func (e *NetworkPartition) Kind() string {
+	return "NetworkChaos"
+}
+
+// Validate checks experiment fields
+func (e *NetworkPartition) Validate() error {
+	v := newValidation(e.Kind())
+	v.selector("", e.Select(e.FromMode, e.FromLabelKey, e.FromLabelValue))
+	v.selector("target ", e.To.Merge(e.ToMode, e.ToLabelKey, e.ToLabelValue, e.Namespace))
+	return v.err()
+}
+
+// Spec returns Chaosmesh NetworkChaos spec
+func (e *NetworkPartition) Spec() interface{} {
+	target := e.To.Merge(e.ToMode, e.ToLabelKey, e.ToLabelValue, e.Namespace).PodSelector()
+	return &NetworkChaosSpec{
+		PodSelectorSpec: e.Select(e.FromMode, e.FromLabelKey, e.FromLabelValue).PodSelector(),
+		Action:          "partition",
+		Direction:       "both",
+		Target:          &target,
+	}
+}
@@ -1,8 +1,6 @@
 package experiments
 
-import (
-	"time"
-)
+import "time"
 
 // PodFailure struct contains objects for Pod Failure testing
 type PodFailure struct {
@@ -13,11 +11,6 @@ type PodFailure struct {
 	Duration   time.Duration
 }
 
-// SetBase sets the base
-func (e *PodFailure) SetBase(base Base) {
-	e.Base = base
-}
-
 // Resource returns the resource
 func (e *PodFailure) Resource() string {
 	return "podchaos"
@@ -27,3 +20,25 @@ func (e *PodFailure) Resource() string {
 func (e *PodFailure) Filename() string {
 	return "pod-failure.yml"
 }
+
+// Kind returns Chaosmesh CRD kind
+func (e *PodFailure) Kind() string {
+	return "PodChaos"
+}
+
+// Validate checks experiment fields
+func (e *PodFailure) Validate() error {
+	v := newValidation(e.Kind())
+	v.selector("", e.Select(e.Mode, e.LabelKey, e.LabelValue))
+	v.duration("duration", e.Duration)
+	return v.err()
+}
+
+// Spec returns Chaosmesh PodChaos spec
+func (e *PodFailure) Spec() interface{} {
+	return &PodChaosSpec{
+		PodSelectorSpec: e.Select(e.Mode, e.LabelKey, e.LabelValue).PodSelector(),
+		Action:          "pod-failure",
+		Duration:        formatDuration(e.Duration),
+	}
+}
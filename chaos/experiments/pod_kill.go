@@ -8,11 +8,6 @@ type PodKill struct {
 	LabelValue string
 }
 
-// SetBase sets the base
-func (e *PodKill) SetBase(base Base) {
-	e.Base = base
-}
-
 // Resource returns the resource
 func (e *PodKill) Resource() string {
 	return "podchaos"
@@ -22,3 +17,23 @@ func (e *PodKill) Resource() string {
 func (e *PodKill) Filename() string {
 	return "pod-kill.yml"
 }
+
+// Kind returns Chaosmesh CRD kind
+func (e *PodKill) Kind() string {
+	return "PodChaos"
+}
+
+// Validate checks experiment fields
+func (e *PodKill) Validate() error {
+	v := newValidation(e.Kind())
+	v.selector("", e.Select(e.Mode, e.LabelKey, e.LabelValue))
+	return v.err()
+}
+
+// Spec returns Chaosmesh PodChaos spec
+func (e *PodKill) Spec() interface{} {
+	return &PodChaosSpec{
+		PodSelectorSpec: e.Select(e.Mode, e.LabelKey, e.LabelValue).PodSelector(),
+		Action:          "pod-kill",
+	}
+}
@@ -0,0 +1,51 @@
+package experiments
+
+const (
+	// ConcurrencyForbid new run is skipped while the previous one is still running
+	ConcurrencyForbid = "Forbid"
+	// ConcurrencyAllow runs may overlap
+	ConcurrencyAllow = "Allow"
+)
+
+// Schedule runs an experiment or a workflow on a cron schedule
+type Schedule struct {
+	Base
+	// Cron schedule in cron format, or @every <duration>
+	Cron string
+	// ConcurrencyPolicy ConcurrencyForbid or ConcurrencyAllow, Chaosmesh forbids overlapping runs by default
+	ConcurrencyPolicy string
+	// HistoryLimit amount of finished runs kept
+	HistoryLimit int
+	// StartingDeadlineSeconds deadline of a missed run start, zero means no deadline
+	StartingDeadlineSeconds int64
+	// Experiment scheduled experiment
+	Experiment Experiment
+}
+
+// Resource returns the resource
+func (e *Schedule) Resource() string {
+	return "schedules"
+}
+
+// Filename returns the file name for schedule
+func (e *Schedule) Filename() string {
+	return "schedule.yml"
+}
+
+// Validate checks schedule fields and the scheduled experiment
+func (e *Schedule) Validate() error {
+	v := newValidation("Schedule")
+	v.required("cron", e.Cron)
+	v.check(
+		e.ConcurrencyPolicy == "" || e.ConcurrencyPolicy == ConcurrencyForbid || e.ConcurrencyPolicy == ConcurrencyAllow,
+		"concurrency policy %s is unknown", e.ConcurrencyPolicy,
+	)
+	v.check(e.HistoryLimit >= 0, "history limit must not be negative")
+	v.check(e.StartingDeadlineSeconds >= 0, "starting deadline must not be negative")
+	if e.Experiment == nil {
+		v.check(false, "experiment is required")
+	} else if ev, ok := e.Experiment.(Validator); ok {
+		v.nested("experiment", ev.Validate())
+	}
+	return v.err()
+}
@@ -0,0 +1,118 @@
+package experiments
+
+import "encoding/json"
+
+const (
+	// ModeOne selects a random pod
+	ModeOne = "one"
+	// ModeAll selects every pod
+	ModeAll = "all"
+	// ModeFixed selects Value random pods
+	ModeFixed = "fixed"
+	// ModeFixedPercent selects Value percent of pods
+	ModeFixedPercent = "fixed-percent"
+	// ModeRandomMaxPercent selects up to Value percent of pods
+	ModeRandomMaxPercent = "random-max-percent"
+
+	// OpIn label value is one of values
+	OpIn = "In"
+	// OpNotIn label value is none of values
+	OpNotIn = "NotIn"
+	// OpExists label is set
+	OpExists = "Exists"
+	// OpDoesNotExist label is not set
+	OpDoesNotExist = "DoesNotExist"
+)
+
+// Selector selects experiment target pods, every set criteria must match
+type Selector struct {
+	// Mode ModeOne, ModeAll, ModeFixed, ModeFixedPercent or ModeRandomMaxPercent
+	Mode string
+	// Value amount of pods for ModeFixed, or percent of pods for ModeFixedPercent and ModeRandomMaxPercent
+	Value string
+	// Namespaces defaults to the experiment namespace
+	Namespaces  []string
+	Labels      map[string]string
+	Expressions []Expression
+	Annotations map[string]string
+	Fields      map[string]string
+	// Pods pod names by namespace, other criteria are ignored by Chaosmesh if pods are set
+	Pods      map[string][]string
+	PodPhases []string
+	Nodes     []string
+}
+
+// Expression label selector requirement, for example instance In 1, 3
+type Expression struct {
+	Key      string   `json:"key"`
+	Operator string   `json:"operator"`
+	Values   []string `json:"values,omitempty"`
+}
+
+// SelectorSpec Chaosmesh selector spec
+type SelectorSpec struct {
+	Namespaces          []string            `json:"namespaces,omitempty"`
+	LabelSelectors      map[string]string   `json:"labelSelectors,omitempty"`
+	ExpressionSelectors []Expression        `json:"expressionSelectors,omitempty"`
+	AnnotationSelectors map[string]string   `json:"annotationSelectors,omitempty"`
+	FieldSelectors      map[string]string   `json:"fieldSelectors,omitempty"`
+	Pods                map[string][]string `json:"pods,omitempty"`
+	PodPhaseSelectors   []string            `json:"podPhaseSelectors,omitempty"`
+	Nodes               []string            `json:"nodes,omitempty"`
+}
+
+// Merge returns a copy of selector with mode, label and namespace used as defaults, nil selector is empty
+func (s *Selector) Merge(mode, labelKey, labelValue, namespace string) Selector {
+	var res Selector
+	if s != nil {
+		res = *s
+	}
+	if len(res.Mode) == 0 {
+		res.Mode = mode
+	}
+	if len(labelKey) > 0 {
+		labels := map[string]string{labelKey: labelValue}
+		for k, v := range res.Labels {
+			labels[k] = v
+		}
+		res.Labels = labels
+	}
+	if len(res.Namespaces) == 0 && len(namespace) > 0 {
+		res.Namespaces = []string{namespace}
+	}
+	return res
+}
+
+// PodSelectorSpec mode, value and selector of experiment targets
+type PodSelectorSpec struct {
+	Mode     string       `json:"mode"`
+	Value    string       `json:"value,omitempty"`
+	Selector SelectorSpec `json:"selector"`
+}
+
+// Spec returns Chaosmesh selector spec as inline JSON, used by templates
+func (s Selector) Spec() (string, error) {
+	b, err := json.Marshal(s.PodSelector().Selector)
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+// PodSelector returns Chaosmesh mode, value and selector spec
+func (s Selector) PodSelector() PodSelectorSpec {
+	return PodSelectorSpec{
+		Mode:  s.Mode,
+		Value: s.Value,
+		Selector: SelectorSpec{
+			Namespaces:          s.Namespaces,
+			LabelSelectors:      s.Labels,
+			ExpressionSelectors: s.Expressions,
+			AnnotationSelectors: s.Annotations,
+			FieldSelectors:      s.Fields,
+			Pods:                s.Pods,
+			PodPhaseSelectors:   s.PodPhases,
+			Nodes:               s.Nodes,
+		},
+	}
+}
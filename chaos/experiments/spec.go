@@ -0,0 +1,181 @@
+package experiments
+
+import "time"
+
+// PodChaosSpec Chaosmesh PodChaos spec
+type PodChaosSpec struct {
+	PodSelectorSpec
+	Action         string   `json:"action"`
+	ContainerNames []string `json:"containerNames,omitempty"`
+	Duration       string   `json:"duration,omitempty"`
+}
+
+// StressChaosSpec Chaosmesh StressChaos spec
+type StressChaosSpec struct {
+	PodSelectorSpec
+	Stressors Stressors `json:"stressors"`
+	Duration  string    `json:"duration,omitempty"`
+}
+
+// Stressors StressChaos stressors
+type Stressors struct {
+	CPU    *CPUStressor    `json:"cpu,omitempty"`
+	Memory *MemoryStressor `json:"memory,omitempty"`
+}
+
+// CPUStressor CPU stressor, Load is a percent of CPU occupied by every worker
+type CPUStressor struct {
+	Workers int      `json:"workers"`
+	Load    int      `json:"load,omitempty"`
+	Options []string `json:"options,omitempty"`
+}
+
+// DNSChaosSpec Chaosmesh DNSChaos spec
+type DNSChaosSpec struct {
+	PodSelectorSpec
+	Action   string   `json:"action"`
+	Patterns []string `json:"patterns,omitempty"`
+	Duration string   `json:"duration,omitempty"`
+}
+
+// IOChaosSpec Chaosmesh IOChaos spec
+type IOChaosSpec struct {
+	PodSelectorSpec
+	Action     string `json:"action"`
+	VolumePath string `json:"volumePath"`
+	Path       string `json:"path,omitempty"`
+	Delay      string `json:"delay,omitempty"`
+	Errno      int    `json:"errno,omitempty"`
+	Percent    int    `json:"percent,omitempty"`
+	Duration   string `json:"duration,omitempty"`
+}
+
+// NetworkChaosSpec Chaosmesh NetworkChaos spec
+type NetworkChaosSpec struct {
+	PodSelectorSpec
+	Action    string           `json:"action"`
+	Direction string           `json:"direction,omitempty"`
+	Target    *PodSelectorSpec `json:"target,omitempty"`
+	Delay     *DelaySpec       `json:"delay,omitempty"`
+	Loss      *LossSpec        `json:"loss,omitempty"`
+	Duplicate *DuplicateSpec   `json:"duplicate,omitempty"`
+	Corrupt   *CorruptSpec     `json:"corrupt,omitempty"`
+	Bandwidth *BandwidthSpec   `json:"bandwidth,omitempty"`
+	Duration  string           `json:"duration,omitempty"`
+}
+
+// DelaySpec network delay
+type DelaySpec struct {
+	Latency     string `json:"latency"`
+	Correlation string `json:"correlation,omitempty"`
+	Jitter      string `json:"jitter,omitempty"`
+}
+
+// LossSpec network packet loss, percents are strings
+type LossSpec struct {
+	Loss        string `json:"loss"`
+	Correlation string `json:"correlation"`
+}
+
+// DuplicateSpec network packet duplication
+type DuplicateSpec struct {
+	Duplicate   string `json:"duplicate"`
+	Correlation string `json:"correlation"`
+}
+
+// CorruptSpec network packet corruption
+type CorruptSpec struct {
+	Corrupt     string `json:"corrupt"`
+	Correlation string `json:"correlation"`
+}
+
+// BandwidthSpec network bandwidth limit
+type BandwidthSpec struct {
+	Rate     string `json:"rate"`
+	Limit    int    `json:"limit"`
+	Buffer   int    `json:"buffer"`
+	PeakRate int    `json:"peakrate,omitempty"`
+	MinBurst int    `json:"minburst,omitempty"`
+}
+
+// TimeChaosSpec Chaosmesh TimeChaos spec
+type TimeChaosSpec struct {
+	PodSelectorSpec
+	TimeOffset string `json:"timeOffset"`
+	Duration   string `json:"duration,omitempty"`
+}
+
+// formatDuration formats experiment duration, zero duration is omitted so the experiment lasts until it's deleted
+func formatDuration(d time.Duration) string {
+	if d == 0 {
+		return ""
+	}
+	return d.String()
+}
+
+// MemoryStressor memory stressor, Size is an amount like 256MB or a percent of total memory like 50%
+type MemoryStressor struct {
+	Workers int      `json:"workers"`
+	Size    string   `json:"size,omitempty"`
+	Options []string `json:"options,omitempty"`
+}
+
+// HTTPChaosSpec Chaosmesh HTTPChaos spec
+type HTTPChaosSpec struct {
+	PodSelectorSpec
+	Target   string           `json:"target"`
+	Port     int              `json:"port"`
+	Method   string           `json:"method,omitempty"`
+	Path     string           `json:"path,omitempty"`
+	Abort    bool             `json:"abort,omitempty"`
+	Delay    string           `json:"delay,omitempty"`
+	Replace  *HTTPReplaceSpec `json:"replace,omitempty"`
+	Duration string           `json:"duration,omitempty"`
+}
+
+// HTTPReplaceSpec replaced parts of HTTP requests or responses, body is base64 encoded in JSON
+type HTTPReplaceSpec struct {
+	Path    string            `json:"path,omitempty"`
+	Method  string            `json:"method,omitempty"`
+	Code    int               `json:"code,omitempty"`
+	Body    []byte            `json:"body,omitempty"`
+	Headers map[string]string `json:"headers,omitempty"`
+	Queries map[string]string `json:"queries,omitempty"`
+}
+
+// KernelChaosSpec Chaosmesh KernelChaos spec
+type KernelChaosSpec struct {
+	PodSelectorSpec
+	FailKernRequest FailKernRequest `json:"failKernRequest"`
+	Duration        string          `json:"duration,omitempty"`
+}
+
+// FailKernRequest kernel fault injection request
+type FailKernRequest struct {
+	Callchain   []Frame  `json:"callchain,omitempty"`
+	FailType    int      `json:"failtype"`
+	Headers     []string `json:"headers,omitempty"`
+	Probability int      `json:"probability,omitempty"`
+	Times       int      `json:"times,omitempty"`
+}
+
+// Frame kernel call chain frame
+type Frame struct {
+	Funcname string `json:"funcname"`
+}
+
+// JVMChaosSpec Chaosmesh JVMChaos spec, Value is the value returned by JVMActionReturn,
+// or the selector value otherwise, Chaosmesh uses the same field for both
+type JVMChaosSpec struct {
+	PodSelectorSpec
+	Action     string `json:"action"`
+	Class      string `json:"class,omitempty"`
+	Method     string `json:"method,omitempty"`
+	Port       int    `json:"port,omitempty"`
+	Latency    int64  `json:"latency,omitempty"`
+	Exception  string `json:"exception,omitempty"`
+	Value      string `json:"value,omitempty"`
+	CPUCount   int    `json:"cpuCount,omitempty"`
+	MemoryType string `json:"memType,omitempty"`
+	Duration   string `json:"duration,omitempty"`
+}
@@ -12,11 +12,6 @@ type TimeShift struct {
 	Duration   time.Duration
 }
 
-// SetBase sets the base
-func (e *TimeShift) SetBase(base Base) {
-	e.Base = base
-}
-
 // Resource returns the resource
 func (e *TimeShift) Resource() string {
 	return "timechaos"
@@ -26,3 +21,26 @@ func (e *TimeShift) Resource() string {
 func (e *TimeShift) Filename() string {
 	return "time-shift.yml"
 }
+
+// Kind returns Chaosmesh CRD kind
+func (e *TimeShift) Kind() string {
+	return "TimeChaos"
+}
+
+// Validate checks experiment fields
+func (e *TimeShift) Validate() error {
+	v := newValidation(e.Kind())
+	v.selector("", e.Select(e.Mode, e.LabelKey, e.LabelValue))
+	v.check(e.TimeOffset != 0, "time offset is required")
+	v.duration("duration", e.Duration)
+	return v.err()
+}
+
+// Spec returns Chaosmesh TimeChaos spec
+func (e *TimeShift) Spec() interface{} {
+	return &TimeChaosSpec{
+		PodSelectorSpec: e.Select(e.Mode, e.LabelKey, e.LabelValue).PodSelector(),
+		TimeOffset:      e.TimeOffset.String(),
+		Duration:        formatDuration(e.Duration),
+	}
+}
@@ -0,0 +1,100 @@
+package experiments
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// bandwidthRate tc rate, for example 1mbps
+var bandwidthRate = regexp.MustCompile(`^\d+(\.\d+)?(bps|kbps|mbps|gbps|tbps)$`)
+
+// ValidationError invalid experiment fields
+type ValidationError struct {
+	Kind   string
+	Errors []string
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("invalid %s experiment: %s", e.Kind, strings.Join(e.Errors, "; "))
+}
+
+// validation collects errors of experiment fields
+type validation struct {
+	kind string
+	errs []string
+}
+
+func newValidation(kind string) *validation {
+	return &validation{kind: kind}
+}
+
+func (v *validation) check(ok bool, format string, args ...interface{}) {
+	if !ok {
+		v.errs = append(v.errs, fmt.Sprintf(format, args...))
+	}
+}
+
+func (v *validation) required(field, value string) {
+	v.check(len(value) > 0, "%s is required", field)
+}
+
+func (v *validation) percent(field string, value int) {
+	v.check(value >= 0 && value <= 100, "%s must be a percent in 0-100 range, got %d", field, value)
+}
+
+func (v *validation) duration(field string, d time.Duration) {
+	v.check(d >= 0, "%s must not be negative, got %s", field, d)
+}
+
+func (v *validation) positive(field string, value int) {
+	v.check(value > 0, "%s must be positive, got %d", field, value)
+}
+
+// selector checks mode, value and selector criteria, prefix names the selector in errors
+func (v *validation) selector(prefix string, s Selector) {
+	switch s.Mode {
+	case ModeOne, ModeAll:
+		v.check(len(s.Value) == 0, "%svalue must be empty for mode %s", prefix, s.Mode)
+	case ModeFixed:
+		n, err := strconv.Atoi(s.Value)
+		v.check(err == nil && n > 0, "%svalue must be a positive number of pods for mode %s, got '%s'", prefix, s.Mode, s.Value)
+	case ModeFixedPercent, ModeRandomMaxPercent:
+		n, err := strconv.Atoi(s.Value)
+		v.check(err == nil && n > 0 && n <= 100, "%svalue must be a percent in 1-100 range for mode %s, got '%s'", prefix, s.Mode, s.Value)
+	case "":
+		v.check(false, "%smode is required", prefix)
+	default:
+		v.check(false, "%smode %s is unknown", prefix, s.Mode)
+	}
+	for k := range s.Labels {
+		v.check(len(k) > 0, "%slabel key must not be empty", prefix)
+	}
+	for _, e := range s.Expressions {
+		v.check(len(e.Key) > 0, "%sexpression key must not be empty", prefix)
+		switch e.Operator {
+		case OpIn, OpNotIn:
+			v.check(len(e.Values) > 0, "%sexpression %s %s requires values", prefix, e.Key, e.Operator)
+		case OpExists, OpDoesNotExist:
+			v.check(len(e.Values) == 0, "%sexpression %s %s must have no values", prefix, e.Key, e.Operator)
+		default:
+			v.check(false, "%sexpression %s operator %s is unknown", prefix, e.Key, e.Operator)
+		}
+	}
+}
+
+// nested adds errors of a nested experiment
+func (v *validation) nested(field string, err error) {
+	if err != nil {
+		v.errs = append(v.errs, fmt.Sprintf("%s: %s", field, err))
+	}
+}
+
+func (v *validation) err() error {
+	if len(v.errs) == 0 {
+		return nil
+	}
+	return &ValidationError{Kind: v.kind, Errors: v.errs}
+}
@@ -0,0 +1,97 @@
+package experiments
+
+import "time"
+
+const (
+	// StepSerial runs children one after another
+	StepSerial = "Serial"
+	// StepParallel runs children at the same time
+	StepParallel = "Parallel"
+	// StepSuspend waits for the step deadline
+	StepSuspend = "Suspend"
+)
+
+// WorkflowStep workflow template, either a chaos experiment or a serial, parallel or suspend step
+type WorkflowStep struct {
+	Name string
+	// Type StepSerial, StepParallel or StepSuspend, empty for experiment steps
+	Type string
+	// Deadline how long the step lasts, experiment is recovered once the deadline is reached
+	Deadline time.Duration
+	// Children names of the steps run by serial and parallel steps
+	Children []string
+	// Experiment experiment of a chaos step
+	Experiment Experiment
+}
+
+// Workflow chains experiments with serial, parallel and suspend steps
+type Workflow struct {
+	Base
+	// Entry name of the first step, defaults to the first of Steps
+	Entry string
+	Steps []WorkflowStep
+}
+
+// Resource returns the resource
+func (e *Workflow) Resource() string {
+	return "workflows"
+}
+
+// Filename returns the file name for workflow
+func (e *Workflow) Filename() string {
+	return "workflow.yml"
+}
+
+// Serial creates a step running children one after another
+func Serial(name string, children ...string) WorkflowStep {
+	return WorkflowStep{Name: name, Type: StepSerial, Children: children}
+}
+
+// Parallel creates a step running children at the same time
+func Parallel(name string, children ...string) WorkflowStep {
+	return WorkflowStep{Name: name, Type: StepParallel, Children: children}
+}
+
+// Suspend creates a step that waits for d
+func Suspend(name string, d time.Duration) WorkflowStep {
+	return WorkflowStep{Name: name, Type: StepSuspend, Deadline: d}
+}
+
+// Chaos creates a step running the experiment for d
+func Chaos(name string, d time.Duration, exp Experiment) WorkflowStep {
+	return WorkflowStep{Name: name, Deadline: d, Experiment: exp}
+}
+
+// Validate checks that steps are unique, children exist and every step is valid
+func (e *Workflow) Validate() error {
+	v := newValidation("Workflow")
+	v.check(len(e.Steps) > 0, "steps are required")
+	names := map[string]bool{}
+	for _, step := range e.Steps {
+		v.check(len(step.Name) > 0, "step name is required")
+		v.check(!names[step.Name], "step %s is duplicated", step.Name)
+		names[step.Name] = true
+	}
+	v.check(len(e.Entry) == 0 || names[e.Entry], "entry step %s not found", e.Entry)
+	for _, step := range e.Steps {
+		v.check(step.Deadline >= 0, "step %s deadline must not be negative", step.Name)
+		switch step.Type {
+		case StepSerial, StepParallel:
+			v.check(len(step.Children) > 0, "step %s has no children", step.Name)
+			for _, child := range step.Children {
+				v.check(names[child], "child %s of step %s not found", child, step.Name)
+			}
+		case StepSuspend:
+			v.check(step.Deadline > 0, "suspend step %s requires deadline", step.Name)
+		case "":
+			if step.Experiment == nil {
+				v.check(false, "step %s has neither type nor experiment", step.Name)
+			} else if ev, ok := step.Experiment.(Validator); ok {
+				v.nested("step "+step.Name, ev.Validate())
+			}
+		default:
+			v.check(false, "step %s type %s is unknown", step.Name, step.Type)
+		}
+	}
+	return v.err()
+}
@@ -0,0 +1,53 @@
+package chaos
+
+import (
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// ManagedByLabelKey label of every experiment created by helmenv
+	ManagedByLabelKey = "app.kubernetes.io/managed-by"
+	// ManagedByLabelValue value of ManagedByLabelKey label
+	ManagedByLabelValue = "helmenv"
+	// RunIDLabelKey label with the run ID of the controller which created the experiment
+	RunIDLabelKey = "helmenv/run-id"
+)
+
+// Resources every Chaosmesh resource experiments are discovered in
+var Resources = []string{
+	"podchaos",
+	"networkchaos",
+	"iochaos",
+	"stresschaos",
+	"timechaos",
+	"dnschaos",
+	"httpchaos",
+	"kernelchaos",
+	"jvmchaos",
+	"schedules",
+	"workflows",
+}
+
+// crdList Chaosmesh CRD list as it's returned from API server
+type crdList struct {
+	Items []struct {
+		Metadata struct {
+			Name              string            `json:"name"`
+			Labels            map[string]string `json:"labels"`
+			CreationTimestamp metaV1.Time       `json:"creationTimestamp"`
+		} `json:"metadata"`
+	} `json:"items"`
+}
+
+// labels returns labels of experiments created by the controller
+func (c *Controller) labels() map[string]string {
+	return map[string]string{
+		ManagedByLabelKey: ManagedByLabelValue,
+		RunIDLabelKey:     c.Cfg.RunID,
+	}
+}
+
+// List discovers experiments created by helmenv in the namespace by any process
+func (c *Controller) List() ([]*ExperimentInfo, error) {
+	return c.Backend.List()
+}
@@ -0,0 +1,178 @@
+package chaos
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/goplugin/helmenv/chaos/experiments"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/rest/fake"
+)
+
+// fakeCluster keeps created experiments by resource, CRDs of resources missing in installed aren't installed
+type fakeCluster struct {
+	mu        sync.Mutex
+	installed map[string]bool
+	items     map[string]map[string]json.RawMessage
+	deleted   []string
+	// onCreate is called with every created experiment name, if set
+	onCreate func(name string)
+}
+
+func newFakeCluster(resources ...string) *fakeCluster {
+	f := &fakeCluster{installed: map[string]bool{}, items: map[string]map[string]json.RawMessage{}}
+	for _, r := range resources {
+		f.installed[r] = true
+		f.items[r] = map[string]json.RawMessage{}
+	}
+	return f
+}
+
+func (f *fakeCluster) roundTrip(req *http.Request) (*http.Response, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	// /apis/chaos-mesh.org/v1alpha1/namespaces/<namespace>/<resource>[/<name>]
+	parts := strings.Split(strings.TrimPrefix(req.URL.Path, APIBasePath+"/namespaces/"), "/")
+	resource := parts[1]
+	respond := func(code int, body string) (*http.Response, error) {
+		return &http.Response{
+			StatusCode: code,
+			Header:     http.Header{"Content-Type": []string{"application/json"}},
+			Body:       io.NopCloser(strings.NewReader(body)),
+		}, nil
+	}
+	if !f.installed[resource] {
+		return respond(404, statusBody(404, "NotFound", "the server could not find the requested resource"))
+	}
+	switch req.Method {
+	case http.MethodPost:
+		body, _ := io.ReadAll(req.Body)
+		var crd struct {
+			Metadata struct {
+				Name string `json:"name"`
+			} `json:"metadata"`
+		}
+		if err := json.Unmarshal(body, &crd); err != nil {
+			return nil, err
+		}
+		f.items[resource][crd.Metadata.Name] = body
+		if f.onCreate != nil {
+			f.onCreate(crd.Metadata.Name)
+		}
+		return respond(201, string(body))
+	case http.MethodDelete:
+		name := parts[2]
+		if _, ok := f.items[resource][name]; !ok {
+			return respond(404, statusBody(404, "NotFound", name+" not found"))
+		}
+		delete(f.items[resource], name)
+		f.deleted = append(f.deleted, name)
+		return respond(200, `{}`)
+	case http.MethodGet:
+		if len(parts) > 2 {
+			item, ok := f.items[resource][parts[2]]
+			if !ok {
+				return respond(404, statusBody(404, "NotFound", parts[2]+" not found"))
+			}
+			return respond(200, string(item))
+		}
+		if req.URL.Query().Get("labelSelector") != ManagedByLabelKey+"="+ManagedByLabelValue {
+			return respond(400, statusBody(400, "BadRequest", "unexpected selector"))
+		}
+		items := make([]json.RawMessage, 0)
+		for _, item := range f.items[resource] {
+			items = append(items, item)
+		}
+		list, err := json.Marshal(map[string]interface{}{"items": items})
+		if err != nil {
+			return nil, err
+		}
+		return respond(200, string(list))
+	default:
+		return respond(405, statusBody(405, "MethodNotAllowed", req.Method+" is not allowed"))
+	}
+}
+
+func (f *fakeCluster) controller(t *testing.T, runID string) *Controller {
+	c, err := NewController(&Config{
+		NamespaceName: "test",
+		RunID:         runID,
+		RESTClient: &fake.RESTClient{
+			NegotiatedSerializer: scheme.Codecs.WithoutConversion(),
+			GroupVersion:         schema.GroupVersion{Version: "v1"},
+			Client:               fake.CreateHTTPClient(f.roundTrip),
+		},
+		Backoff: wait.Backoff{Steps: 1, Duration: time.Millisecond},
+	})
+	require.NoError(t, err)
+	return c
+}
+
+func TestExperimentLabels(t *testing.T) {
+	t.Parallel()
+
+	c, err := NewController(&Config{NamespaceName: "test"})
+	require.NoError(t, err)
+	require.NotEmpty(t, c.Cfg.RunID, "run ID must be generated")
+	payload, err := c.payloadFromStruct(&experiments.PodKill{Mode: "one"})
+	require.NoError(t, err)
+	var crd struct {
+		Metadata struct {
+			Name   string            `json:"name"`
+			Labels map[string]string `json:"labels"`
+		} `json:"metadata"`
+	}
+	require.NoError(t, json.Unmarshal(payload.Data, &crd))
+	require.Equal(t, payload.Name, crd.Metadata.Name)
+	require.Equal(t, map[string]string{
+		ManagedByLabelKey: ManagedByLabelValue,
+		RunIDLabelKey:     c.Cfg.RunID,
+	}, crd.Metadata.Labels)
+
+	tmpl := t.TempDir() + "/chaos.yml"
+	require.NoError(t, os.WriteFile(tmpl, []byte("resource: podchaos\nkind: PodChaos\nspec:\n  action: pod-kill\n"), 0644))
+	payload, err = c.payloadFromTemplate(tmpl, nil)
+	require.NoError(t, err)
+	require.NoError(t, json.Unmarshal(payload.Data, &crd))
+	require.Equal(t, c.Cfg.RunID, crd.Metadata.Labels[RunIDLabelKey])
+}
+
+func TestListAndStopAllAcrossProcesses(t *testing.T) {
+	t.Parallel()
+
+	cluster := newFakeCluster("podchaos", "networkchaos", "schedules")
+	first := cluster.controller(t, "run-1")
+	killName, err := first.Run(&experiments.PodKill{Mode: "one"})
+	require.NoError(t, err)
+	delayName, err := first.Run(&experiments.NetworkDelay{Mode: "all", Latency: time.Second})
+	require.NoError(t, err)
+
+	// controller of a restarted process knows nothing about experiments it hasn't started
+	second := cluster.controller(t, "run-2")
+	scheduleName, err := second.Run(&experiments.Schedule{Cron: "@every 1m", Experiment: &experiments.PodKill{Mode: "one"}})
+	require.NoError(t, err)
+
+	infos, err := cluster.controller(t, "run-3").List()
+	require.NoError(t, err)
+	require.ElementsMatch(t, []*ExperimentInfo{
+		{Name: killName, Resource: "podchaos", RunID: "run-1"},
+		{Name: delayName, Resource: "networkchaos", RunID: "run-1"},
+		{Name: scheduleName, Resource: "schedules", RunID: "run-2"},
+	}, infos)
+
+	require.NoError(t, second.StopAll())
+	require.ElementsMatch(t, []string{killName, delayName, scheduleName}, cluster.deleted)
+	require.Empty(t, second.Started())
+	infos, err = first.List()
+	require.NoError(t, err)
+	require.Empty(t, infos)
+}
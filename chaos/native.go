@@ -0,0 +1,666 @@
+package chaos
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/goplugin/helmenv/chaos/experiments"
+	"github.com/rs/zerolog/log"
+	v1 "k8s.io/api/core/v1"
+	networkingV1 "k8s.io/api/networking/v1"
+	k8sErrors "k8s.io/apimachinery/pkg/api/errors"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/remotecommand"
+)
+
+const (
+	// BackendLabelKey label of experiment state config maps, with the name of the backend
+	BackendLabelKey = "helmenv/chaos-backend"
+	// ResourceLabelKey label of experiment state config maps, with the Chaosmesh resource of the experiment
+	ResourceLabelKey = "helmenv/chaos-resource"
+	// NativeBackendName name of the native backend
+	NativeBackendName = "native"
+
+	// PodFailureImageSwap pod failure replaces container images with the pause image
+	PodFailureImageSwap = "image-swap"
+	// PodFailureScaleToZero pod failure scales deployments and stateful sets of the pods to zero
+	PodFailureScaleToZero = "scale-to-zero"
+	// DefaultPauseImage image containers are swapped to on pod failure
+	DefaultPauseImage = "k8s.gcr.io/pause:3.7"
+
+	// RecordEventSucceeded record event type for a succeeded apply or recover operation
+	RecordEventSucceeded = "Succeeded"
+	// RecordOperationApply record event operation of chaos injection
+	RecordOperationApply = "Apply"
+	// RecordPhaseInjected record phase of a target chaos is injected into
+	RecordPhaseInjected = "Injected"
+
+	nativePayloadKey = "payload"
+	nativeStateKey   = "state"
+)
+
+// DefaultContainerKillCommand command executed in containers to kill them
+var DefaultContainerKillCommand = []string{"/bin/sh", "-c", "kill 1"}
+
+// ExecFunc executes command in a pod container
+type ExecFunc func(ctx context.Context, namespace, pod, container string, command []string) error
+
+// NativeBackend backend for clusters without Chaosmesh, experiments are applied with plain Kubernetes APIs:
+// pod kill, pod failure, container kill and label based network partition, other experiments are not supported.
+// Experiment state needed to recover targets is kept in a config map named after the experiment,
+// durations are not enforced, chaos lasts until the experiment is stopped
+type NativeBackend struct {
+	Client    kubernetes.Interface
+	Namespace string
+	// RESTConfig config to exec into containers with, required for container kill if Exec is not set
+	RESTConfig *rest.Config
+	// Exec executes container kill command, defaults to exec through the API server
+	Exec ExecFunc
+	// ContainerKillCommand defaults to DefaultContainerKillCommand
+	ContainerKillCommand []string
+	// PodFailure PodFailureImageSwap or PodFailureScaleToZero, defaults to PodFailureImageSwap
+	PodFailure string
+	// PauseImage defaults to DefaultPauseImage
+	PauseImage string
+}
+
+// nativeExperiment rendered Chaosmesh experiment the native backend reads
+type nativeExperiment struct {
+	Kind     string `json:"kind"`
+	Metadata struct {
+		Name   string            `json:"name"`
+		Labels map[string]string `json:"labels"`
+	} `json:"metadata"`
+	Spec struct {
+		experiments.PodSelectorSpec
+		Action         string                       `json:"action"`
+		Duration       string                       `json:"duration"`
+		ContainerNames []string                     `json:"containerNames"`
+		Direction      string                       `json:"direction"`
+		Target         *experiments.PodSelectorSpec `json:"target"`
+	} `json:"spec"`
+}
+
+// nativeState what the native backend changed, so targets can be recovered
+type nativeState struct {
+	Records         []Record          `json:"records"`
+	Images          []nativePodImages `json:"images,omitempty"`
+	Replicas        []nativeReplicas  `json:"replicas,omitempty"`
+	NetworkPolicies []string          `json:"networkPolicies,omitempty"`
+}
+
+// nativePodImages original container images of a pod
+type nativePodImages struct {
+	Namespace string            `json:"namespace"`
+	Pod       string            `json:"pod"`
+	Images    map[string]string `json:"images"`
+}
+
+// nativeReplicas original replicas of a deployment or stateful set
+type nativeReplicas struct {
+	Namespace string `json:"namespace"`
+	Kind      string `json:"kind"`
+	Name      string `json:"name"`
+	Replicas  int32  `json:"replicas"`
+}
+
+// Create selects target pods, injects chaos and saves the state
+func (b *NativeBackend) Create(payload *CRDPayload) error {
+	var exp nativeExperiment
+	if err := json.Unmarshal(payload.Data, &exp); err != nil {
+		return err
+	}
+	if len(exp.Spec.Duration) > 0 {
+		log.Warn().
+			Str("Name", payload.Name).
+			Str("Duration", exp.Spec.Duration).
+			Msg("Native chaos backend doesn't enforce experiment duration, stop the experiment to recover")
+	}
+	ctx := context.Background()
+	state := &nativeState{}
+	err := b.inject(ctx, payload.Name, &exp, state)
+	if err == nil {
+		err = b.saveState(ctx, payload, exp.Metadata.Labels, state)
+	}
+	if err != nil {
+		if rerr := b.recover(ctx, state); rerr != nil {
+			log.Error().Err(rerr).Str("Name", payload.Name).Msg("Failed to recover partially injected chaos")
+		}
+		return err
+	}
+	return nil
+}
+
+// Delete recovers targets and removes experiment state, state is kept if recovery failed so it can be retried
+func (b *NativeBackend) Delete(info *ExperimentInfo) error {
+	ctx := context.Background()
+	cm, state, err := b.state(ctx, info.Name)
+	if err != nil {
+		return err
+	}
+	if err := b.recover(ctx, state); err != nil {
+		return fmt.Errorf("experiment %s: %w", info.Name, err)
+	}
+	return b.Client.CoreV1().ConfigMaps(b.Namespace).Delete(ctx, cm.Name, metaV1.DeleteOptions{})
+}
+
+// Status returns injection records of experiment, chaos is injected when experiment is created
+func (b *NativeBackend) Status(info *ExperimentInfo) (*ExperimentStatus, error) {
+	_, state, err := b.state(context.Background(), info.Name)
+	if err != nil {
+		return nil, err
+	}
+	return &ExperimentStatus{
+		Name:     info.Name,
+		Resource: info.Resource,
+		Conditions: []Condition{
+			{Type: ConditionSelected, Status: "True"},
+			{Type: ConditionAllInjected, Status: "True"},
+			{Type: ConditionAllRecovered, Status: "False"},
+			{Type: ConditionPaused, Status: "False"},
+		},
+		DesiredPhase: "Run",
+		Records:      state.Records,
+	}, nil
+}
+
+// List lists experiment state config maps created by helmenv
+func (b *NativeBackend) List() ([]*ExperimentInfo, error) {
+	selector := labels.SelectorFromSet(labels.Set{
+		ManagedByLabelKey: ManagedByLabelValue,
+		BackendLabelKey:   NativeBackendName,
+	}).String()
+	cms, err := b.Client.CoreV1().ConfigMaps(b.Namespace).List(context.Background(), metaV1.ListOptions{LabelSelector: selector})
+	if err != nil {
+		return nil, err
+	}
+	infos := make([]*ExperimentInfo, 0, len(cms.Items))
+	for _, cm := range cms.Items {
+		infos = append(infos, &ExperimentInfo{
+			Name:     cm.Name,
+			Resource: cm.Labels[ResourceLabelKey],
+			RunID:    cm.Labels[RunIDLabelKey],
+			Created:  created(cm.CreationTimestamp),
+		})
+	}
+	return infos, nil
+}
+
+// SetPaused is not supported by the native backend
+func (b *NativeBackend) SetPaused(info *ExperimentInfo, paused bool) error {
+	return fmt.Errorf("experiment %s: pause: %w", info.Name, ErrNotSupported)
+}
+
+func (b *NativeBackend) inject(ctx context.Context, name string, exp *nativeExperiment, state *nativeState) error {
+	action := fmt.Sprintf("%s %s", exp.Kind, exp.Spec.Action)
+	switch action {
+	case "PodChaos pod-kill", "PodChaos pod-failure", "PodChaos container-kill", "NetworkChaos partition":
+	default:
+		return fmt.Errorf("%s: %w", action, ErrNotSupported)
+	}
+	pods, err := b.selectPods(ctx, exp.Spec.PodSelectorSpec)
+	if err != nil {
+		return err
+	}
+	switch exp.Spec.Action {
+	case "pod-kill":
+		return b.killPods(ctx, pods, state)
+	case "pod-failure":
+		if b.PodFailure == PodFailureScaleToZero {
+			return b.scaleToZero(ctx, pods, state)
+		}
+		return b.swapImages(ctx, pods, state)
+	case "container-kill":
+		return b.killContainers(ctx, pods, exp.Spec.ContainerNames, state)
+	default:
+		return b.partition(ctx, name, exp, pods, state)
+	}
+}
+
+// selectPods lists pods matching selector and picks them according to the mode
+func (b *NativeBackend) selectPods(ctx context.Context, s experiments.PodSelectorSpec) ([]v1.Pod, error) {
+	var pods []v1.Pod
+	if len(s.Selector.Pods) > 0 {
+		for ns, names := range s.Selector.Pods {
+			for _, name := range names {
+				pod, err := b.Client.CoreV1().Pods(ns).Get(ctx, name, metaV1.GetOptions{})
+				if k8sErrors.IsNotFound(err) {
+					continue
+				}
+				if err != nil {
+					return nil, err
+				}
+				pods = append(pods, *pod)
+			}
+		}
+	} else {
+		selector, err := labelSelector(s.Selector.LabelSelectors, s.Selector.ExpressionSelectors)
+		if err != nil {
+			return nil, err
+		}
+		namespaces := s.Selector.Namespaces
+		if len(namespaces) == 0 {
+			namespaces = []string{b.Namespace}
+		}
+		for _, ns := range namespaces {
+			list, err := b.Client.CoreV1().Pods(ns).List(ctx, metaV1.ListOptions{
+				LabelSelector: selector.String(),
+				FieldSelector: labels.SelectorFromSet(s.Selector.FieldSelectors).String(),
+			})
+			if err != nil {
+				return nil, err
+			}
+			for _, pod := range list.Items {
+				if podMatches(pod, s.Selector) {
+					pods = append(pods, pod)
+				}
+			}
+		}
+	}
+	if len(pods) == 0 {
+		return nil, fmt.Errorf("no pods match selector")
+	}
+	return pickPods(pods, s.Mode, s.Value)
+}
+
+// podMatches checks criteria which can't be passed to the API server
+func podMatches(pod v1.Pod, s experiments.SelectorSpec) bool {
+	for k, v := range s.AnnotationSelectors {
+		if pod.Annotations[k] != v {
+			return false
+		}
+	}
+	if len(s.PodPhaseSelectors) > 0 && !contains(s.PodPhaseSelectors, string(pod.Status.Phase)) {
+		return false
+	}
+	if len(s.Nodes) > 0 && !contains(s.Nodes, pod.Spec.NodeName) {
+		return false
+	}
+	return true
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func labelSelector(matchLabels map[string]string, expressions []experiments.Expression) (labels.Selector, error) {
+	ls := &metaV1.LabelSelector{MatchLabels: matchLabels}
+	for _, e := range expressions {
+		ls.MatchExpressions = append(ls.MatchExpressions, metaV1.LabelSelectorRequirement{
+			Key:      e.Key,
+			Operator: metaV1.LabelSelectorOperator(e.Operator),
+			Values:   e.Values,
+		})
+	}
+	return metaV1.LabelSelectorAsSelector(ls)
+}
+
+// pickPods picks random pods as Chaosmesh does for the mode
+func pickPods(pods []v1.Pod, mode, value string) ([]v1.Pod, error) {
+	rand.Shuffle(len(pods), func(i, j int) { pods[i], pods[j] = pods[j], pods[i] })
+	n := len(pods)
+	switch mode {
+	case experiments.ModeOne:
+		n = 1
+	case experiments.ModeAll, "":
+	case experiments.ModeFixed, experiments.ModeFixedPercent, experiments.ModeRandomMaxPercent:
+		v, err := strconv.Atoi(value)
+		if err != nil {
+			return nil, fmt.Errorf("mode %s: invalid value %q", mode, value)
+		}
+		switch mode {
+		case experiments.ModeFixed:
+			n = v
+		case experiments.ModeFixedPercent:
+			n = int(math.Floor(float64(len(pods)) * float64(v) / 100))
+		default:
+			n = int(math.Floor(float64(len(pods)) * float64(rand.Intn(v+1)) / 100))
+		}
+	default:
+		return nil, fmt.Errorf("mode %s: %w", mode, ErrNotSupported)
+	}
+	if n > len(pods) {
+		n = len(pods)
+	}
+	if n <= 0 {
+		return nil, fmt.Errorf("mode %s selected no pods of %d", mode, len(pods))
+	}
+	return pods[:n], nil
+}
+
+func injectedRecord(id string) Record {
+	return Record{
+		ID:            id,
+		Phase:         RecordPhaseInjected,
+		InjectedCount: 1,
+		Events: []RecordEvent{{
+			Type:      RecordEventSucceeded,
+			Operation: RecordOperationApply,
+			Timestamp: time.Now(),
+		}},
+	}
+}
+
+func (b *NativeBackend) killPods(ctx context.Context, pods []v1.Pod, state *nativeState) error {
+	for _, pod := range pods {
+		if err := b.Client.CoreV1().Pods(pod.Namespace).Delete(ctx, pod.Name, metaV1.DeleteOptions{}); err != nil {
+			return err
+		}
+		state.Records = append(state.Records, injectedRecord(pod.Namespace+"/"+pod.Name))
+	}
+	return nil
+}
+
+func (b *NativeBackend) swapImages(ctx context.Context, pods []v1.Pod, state *nativeState) error {
+	image := b.PauseImage
+	if len(image) == 0 {
+		image = DefaultPauseImage
+	}
+	for _, pod := range pods {
+		pod := pod
+		orig := nativePodImages{Namespace: pod.Namespace, Pod: pod.Name, Images: map[string]string{}}
+		for i, c := range pod.Spec.Containers {
+			orig.Images[c.Name] = c.Image
+			pod.Spec.Containers[i].Image = image
+		}
+		if _, err := b.Client.CoreV1().Pods(pod.Namespace).Update(ctx, &pod, metaV1.UpdateOptions{}); err != nil {
+			return err
+		}
+		state.Images = append(state.Images, orig)
+		state.Records = append(state.Records, injectedRecord(pod.Namespace+"/"+pod.Name))
+	}
+	return nil
+}
+
+func (b *NativeBackend) scaleToZero(ctx context.Context, pods []v1.Pod, state *nativeState) error {
+	scaled := map[string]bool{}
+	for _, pod := range pods {
+		kind, name, err := b.podWorkload(ctx, pod)
+		if err != nil {
+			return err
+		}
+		key := pod.Namespace + "/" + kind + "/" + name
+		if !scaled[key] {
+			scaled[key] = true
+			replicas, err := b.scale(ctx, pod.Namespace, kind, name, 0)
+			if err != nil {
+				return err
+			}
+			state.Replicas = append(state.Replicas, nativeReplicas{
+				Namespace: pod.Namespace,
+				Kind:      kind,
+				Name:      name,
+				Replicas:  replicas,
+			})
+		}
+		state.Records = append(state.Records, injectedRecord(pod.Namespace+"/"+pod.Name))
+	}
+	return nil
+}
+
+// podWorkload returns deployment or stateful set which owns the pod
+func (b *NativeBackend) podWorkload(ctx context.Context, pod v1.Pod) (string, string, error) {
+	owner := metaV1.GetControllerOf(&pod)
+	if owner == nil {
+		return "", "", fmt.Errorf("pod %s/%s has no owner to scale", pod.Namespace, pod.Name)
+	}
+	switch owner.Kind {
+	case "StatefulSet", "Deployment":
+		return owner.Kind, owner.Name, nil
+	case "ReplicaSet":
+		rs, err := b.Client.AppsV1().ReplicaSets(pod.Namespace).Get(ctx, owner.Name, metaV1.GetOptions{})
+		if err != nil {
+			return "", "", err
+		}
+		if rsOwner := metaV1.GetControllerOf(rs); rsOwner != nil && rsOwner.Kind == "Deployment" {
+			return rsOwner.Kind, rsOwner.Name, nil
+		}
+	}
+	return "", "", fmt.Errorf("pod %s/%s is owned by %s %s which can't be scaled", pod.Namespace, pod.Name, owner.Kind, owner.Name)
+}
+
+// scale sets replicas of deployment or stateful set, returns previous replicas
+func (b *NativeBackend) scale(ctx context.Context, namespace, kind, name string, replicas int32) (int32, error) {
+	var prev int32 = 1
+	if kind == "Deployment" {
+		d, err := b.Client.AppsV1().Deployments(namespace).Get(ctx, name, metaV1.GetOptions{})
+		if err != nil {
+			return 0, err
+		}
+		if d.Spec.Replicas != nil {
+			prev = *d.Spec.Replicas
+		}
+		d.Spec.Replicas = &replicas
+		_, err = b.Client.AppsV1().Deployments(namespace).Update(ctx, d, metaV1.UpdateOptions{})
+		return prev, err
+	}
+	s, err := b.Client.AppsV1().StatefulSets(namespace).Get(ctx, name, metaV1.GetOptions{})
+	if err != nil {
+		return 0, err
+	}
+	if s.Spec.Replicas != nil {
+		prev = *s.Spec.Replicas
+	}
+	s.Spec.Replicas = &replicas
+	_, err = b.Client.AppsV1().StatefulSets(namespace).Update(ctx, s, metaV1.UpdateOptions{})
+	return prev, err
+}
+
+func (b *NativeBackend) killContainers(ctx context.Context, pods []v1.Pod, containers []string, state *nativeState) error {
+	command := b.ContainerKillCommand
+	if len(command) == 0 {
+		command = DefaultContainerKillCommand
+	}
+	for _, pod := range pods {
+		for _, container := range containers {
+			if err := b.exec(ctx, pod.Namespace, pod.Name, container, command); err != nil {
+				return fmt.Errorf("failed to kill container %s of pod %s/%s: %w", container, pod.Namespace, pod.Name, err)
+			}
+			state.Records = append(state.Records, injectedRecord(pod.Namespace+"/"+pod.Name+"/"+container))
+		}
+	}
+	return nil
+}
+
+func (b *NativeBackend) exec(ctx context.Context, namespace, pod, container string, command []string) error {
+	if b.Exec != nil {
+		return b.Exec(ctx, namespace, pod, container, command)
+	}
+	if b.RESTConfig == nil {
+		return fmt.Errorf("exec requires RESTConfig")
+	}
+	req := b.Client.CoreV1().RESTClient().Post().
+		Resource("pods").
+		Name(pod).
+		Namespace(namespace).
+		SubResource("exec").
+		VersionedParams(&v1.PodExecOptions{
+			Container: container,
+			Command:   command,
+			Stdout:    true,
+			Stderr:    true,
+		}, scheme.ParameterCodec)
+	exec, err := remotecommand.NewSPDYExecutor(b.RESTConfig, "POST", req.URL())
+	if err != nil {
+		return err
+	}
+	var stdout, stderr bytes.Buffer
+	if err := exec.Stream(remotecommand.StreamOptions{Stdout: &stdout, Stderr: &stderr}); err != nil {
+		// the container may be killed before the command exits
+		if stderr.Len() > 0 {
+			return fmt.Errorf("%w: %s", err, strings.TrimSpace(stderr.String()))
+		}
+		log.Debug().Err(err).Str("Pod", pod).Str("Container", container).Msg("Container kill command exited")
+	}
+	return nil
+}
+
+// partition creates network policy which denies traffic between selected pods and the target,
+// pods must be selected by labels in the backend namespace with mode all, the target by labels only
+func (b *NativeBackend) partition(ctx context.Context, name string, exp *nativeExperiment, pods []v1.Pod, state *nativeState) error {
+	source, target := exp.Spec.PodSelectorSpec, exp.Spec.Target
+	if !modeAll(source.Mode) || !labelsOnly(source.Selector, b.Namespace, true) {
+		return fmt.Errorf("partition source must select pods by labels in namespace %s with mode all: %w", b.Namespace, ErrNotSupported)
+	}
+	if target == nil || !modeAll(target.Mode) || len(target.Selector.LabelSelectors) == 0 ||
+		!labelsOnly(target.Selector, b.Namespace, false) {
+		return fmt.Errorf("partition target must select pods by labels in namespace %s with mode all: %w", b.Namespace, ErrNotSupported)
+	}
+	// network policies allow traffic, so every pod but the target is allowed,
+	// pod isn't the target if any of the target labels doesn't match
+	keys := make([]string, 0, len(target.Selector.LabelSelectors))
+	for k := range target.Selector.LabelSelectors {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	peers := []networkingV1.NetworkPolicyPeer{{
+		NamespaceSelector: &metaV1.LabelSelector{MatchExpressions: []metaV1.LabelSelectorRequirement{{
+			Key:      "kubernetes.io/metadata.name",
+			Operator: metaV1.LabelSelectorOpNotIn,
+			Values:   []string{b.Namespace},
+		}}},
+	}}
+	for _, k := range keys {
+		peers = append(peers, networkingV1.NetworkPolicyPeer{
+			PodSelector: &metaV1.LabelSelector{MatchExpressions: []metaV1.LabelSelectorRequirement{{
+				Key:      k,
+				Operator: metaV1.LabelSelectorOpNotIn,
+				Values:   []string{target.Selector.LabelSelectors[k]},
+			}}},
+		})
+	}
+	podSelector := metaV1.LabelSelector{MatchLabels: source.Selector.LabelSelectors}
+	for _, e := range source.Selector.ExpressionSelectors {
+		podSelector.MatchExpressions = append(podSelector.MatchExpressions, metaV1.LabelSelectorRequirement{
+			Key:      e.Key,
+			Operator: metaV1.LabelSelectorOperator(e.Operator),
+			Values:   e.Values,
+		})
+	}
+	policy := &networkingV1.NetworkPolicy{
+		ObjectMeta: metaV1.ObjectMeta{Name: name, Namespace: b.Namespace, Labels: exp.Metadata.Labels},
+		Spec:       networkingV1.NetworkPolicySpec{PodSelector: podSelector},
+	}
+	direction := exp.Spec.Direction
+	if direction == experiments.DirectionTo || direction == experiments.DirectionBoth || len(direction) == 0 {
+		policy.Spec.PolicyTypes = append(policy.Spec.PolicyTypes, networkingV1.PolicyTypeEgress)
+		policy.Spec.Egress = []networkingV1.NetworkPolicyEgressRule{{To: peers}}
+	}
+	if direction == experiments.DirectionFrom || direction == experiments.DirectionBoth {
+		policy.Spec.PolicyTypes = append(policy.Spec.PolicyTypes, networkingV1.PolicyTypeIngress)
+		policy.Spec.Ingress = []networkingV1.NetworkPolicyIngressRule{{From: peers}}
+	}
+	if len(policy.Spec.PolicyTypes) == 0 {
+		return fmt.Errorf("partition direction %s: %w", direction, ErrNotSupported)
+	}
+	if _, err := b.Client.NetworkingV1().NetworkPolicies(b.Namespace).Create(ctx, policy, metaV1.CreateOptions{}); err != nil {
+		return err
+	}
+	state.NetworkPolicies = append(state.NetworkPolicies, name)
+	for _, pod := range pods {
+		state.Records = append(state.Records, injectedRecord(pod.Namespace+"/"+pod.Name))
+	}
+	return nil
+}
+
+func modeAll(mode string) bool {
+	return mode == experiments.ModeAll || len(mode) == 0
+}
+
+// labelsOnly checks that selector has nothing but labels, and expressions if allowed, in the namespace
+func labelsOnly(s experiments.SelectorSpec, namespace string, expressions bool) bool {
+	if len(s.Namespaces) > 1 || (len(s.Namespaces) == 1 && s.Namespaces[0] != namespace) {
+		return false
+	}
+	if !expressions && len(s.ExpressionSelectors) > 0 {
+		return false
+	}
+	return len(s.AnnotationSelectors) == 0 && len(s.FieldSelectors) == 0 && len(s.Pods) == 0 &&
+		len(s.PodPhaseSelectors) == 0 && len(s.Nodes) == 0
+}
+
+// recover reverts changes recorded in the state, killed pods and containers are restarted by Kubernetes
+func (b *NativeBackend) recover(ctx context.Context, state *nativeState) error {
+	for _, p := range state.Images {
+		pod, err := b.Client.CoreV1().Pods(p.Namespace).Get(ctx, p.Pod, metaV1.GetOptions{})
+		if k8sErrors.IsNotFound(err) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		for i, c := range pod.Spec.Containers {
+			if image, ok := p.Images[c.Name]; ok {
+				pod.Spec.Containers[i].Image = image
+			}
+		}
+		if _, err := b.Client.CoreV1().Pods(p.Namespace).Update(ctx, pod, metaV1.UpdateOptions{}); err != nil {
+			return err
+		}
+	}
+	for _, r := range state.Replicas {
+		if _, err := b.scale(ctx, r.Namespace, r.Kind, r.Name, r.Replicas); err != nil && !k8sErrors.IsNotFound(err) {
+			return err
+		}
+	}
+	for _, name := range state.NetworkPolicies {
+		err := b.Client.NetworkingV1().NetworkPolicies(b.Namespace).Delete(ctx, name, metaV1.DeleteOptions{})
+		if err != nil && !k8sErrors.IsNotFound(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+func (b *NativeBackend) saveState(ctx context.Context, payload *CRDPayload, expLabels map[string]string, state *nativeState) error {
+	data, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+	cmLabels := map[string]string{}
+	for k, v := range expLabels {
+		cmLabels[k] = v
+	}
+	cmLabels[BackendLabelKey] = NativeBackendName
+	cmLabels[ResourceLabelKey] = payload.Resource
+	_, err = b.Client.CoreV1().ConfigMaps(b.Namespace).Create(ctx, &v1.ConfigMap{
+		ObjectMeta: metaV1.ObjectMeta{Name: payload.Name, Namespace: b.Namespace, Labels: cmLabels},
+		Data: map[string]string{
+			nativePayloadKey: string(payload.Data),
+			nativeStateKey:   string(data),
+		},
+	}, metaV1.CreateOptions{})
+	return err
+}
+
+func (b *NativeBackend) state(ctx context.Context, name string) (*v1.ConfigMap, *nativeState, error) {
+	cm, err := b.Client.CoreV1().ConfigMaps(b.Namespace).Get(ctx, name, metaV1.GetOptions{})
+	if err != nil {
+		return nil, nil, err
+	}
+	if cm.Labels[BackendLabelKey] != NativeBackendName {
+		return nil, nil, fmt.Errorf("config map %s is not a native chaos experiment", name)
+	}
+	var state nativeState
+	if err := json.Unmarshal([]byte(cm.Data[nativeStateKey]), &state); err != nil {
+		return nil, nil, err
+	}
+	return cm, &state, nil
+}
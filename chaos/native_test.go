@@ -0,0 +1,222 @@
+package chaos
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/goplugin/helmenv/chaos/experiments"
+	"github.com/stretchr/testify/require"
+	appsV1 "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+	networkingV1 "k8s.io/api/networking/v1"
+	k8sErrors "k8s.io/apimachinery/pkg/api/errors"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func nativePod(name, app string, owner *metaV1.OwnerReference) *v1.Pod {
+	pod := &v1.Pod{
+		ObjectMeta: metaV1.ObjectMeta{Name: name, Namespace: "env", Labels: map[string]string{"app": app}},
+		Spec: v1.PodSpec{Containers: []v1.Container{
+			{Name: "node", Image: "plugin:1.0"},
+			{Name: "sidecar", Image: "proxy:1.0"},
+		}},
+		Status: v1.PodStatus{Phase: v1.PodRunning},
+	}
+	if owner != nil {
+		pod.OwnerReferences = []metaV1.OwnerReference{*owner}
+	}
+	return pod
+}
+
+func nativeController(t *testing.T, backend *NativeBackend, objects ...runtime.Object) (*Controller, *fake.Clientset) {
+	client := fake.NewSimpleClientset(objects...)
+	backend.Client = client
+	backend.Namespace = "env"
+	c, err := NewController(&Config{NamespaceName: "env", Backend: backend, RunID: "run-1"})
+	require.NoError(t, err)
+	return c, client
+}
+
+func TestNativePodKill(t *testing.T) {
+	t.Parallel()
+
+	c, client := nativeController(t, &NativeBackend{},
+		nativePod("node-0", "plugin-node", nil),
+		nativePod("node-1", "plugin-node", nil),
+		nativePod("geth-0", "geth", nil),
+	)
+	name, err := c.Run(&experiments.PodKill{Mode: experiments.ModeAll, LabelKey: "app", LabelValue: "plugin-node"})
+	require.NoError(t, err)
+	pods, err := client.CoreV1().Pods("env").List(context.Background(), metaV1.ListOptions{})
+	require.NoError(t, err)
+	require.Len(t, pods.Items, 1)
+	require.Equal(t, "geth-0", pods.Items[0].Name)
+
+	status, err := c.Status(name)
+	require.NoError(t, err)
+	require.True(t, status.Selected())
+	require.True(t, status.AllInjected())
+	require.ElementsMatch(t, []string{"env/node-0", "env/node-1"}, status.SelectedPods())
+
+	infos, err := c.List()
+	require.NoError(t, err)
+	require.Equal(t, []*ExperimentInfo{{Name: name, Resource: "podchaos", RunID: "run-1"}}, infos)
+
+	require.NoError(t, c.Stop(name))
+	_, err = c.StatusOf(&ExperimentInfo{Name: name, Resource: "podchaos"})
+	require.True(t, k8sErrors.IsNotFound(err))
+
+	_, err = c.Run(&experiments.PodKill{Mode: experiments.ModeAll, LabelKey: "app", LabelValue: "missing"})
+	require.EqualError(t, err, "no pods match selector")
+}
+
+func TestNativePodFailureImageSwap(t *testing.T) {
+	t.Parallel()
+
+	c, client := nativeController(t, &NativeBackend{PauseImage: "pause:1"},
+		nativePod("node-0", "plugin-node", nil),
+		nativePod("geth-0", "geth", nil),
+	)
+	name, err := c.Run(&experiments.PodFailure{Mode: experiments.ModeOne, LabelKey: "app", LabelValue: "plugin-node", Duration: time.Minute})
+	require.NoError(t, err)
+	images := func(pod string) []string {
+		p, err := client.CoreV1().Pods("env").Get(context.Background(), pod, metaV1.GetOptions{})
+		require.NoError(t, err)
+		return []string{p.Spec.Containers[0].Image, p.Spec.Containers[1].Image}
+	}
+	require.Equal(t, []string{"pause:1", "pause:1"}, images("node-0"))
+	require.Equal(t, []string{"plugin:1.0", "proxy:1.0"}, images("geth-0"))
+
+	require.NoError(t, c.Stop(name))
+	require.Equal(t, []string{"plugin:1.0", "proxy:1.0"}, images("node-0"))
+}
+
+func TestNativePodFailureScaleToZero(t *testing.T) {
+	t.Parallel()
+
+	replicas := int32(3)
+	controller := true
+	c, client := nativeController(t, &NativeBackend{PodFailure: PodFailureScaleToZero},
+		&appsV1.Deployment{
+			ObjectMeta: metaV1.ObjectMeta{Name: "node", Namespace: "env"},
+			Spec:       appsV1.DeploymentSpec{Replicas: &replicas},
+		},
+		&appsV1.ReplicaSet{ObjectMeta: metaV1.ObjectMeta{
+			Name:            "node-5d8f",
+			Namespace:       "env",
+			OwnerReferences: []metaV1.OwnerReference{{Kind: "Deployment", Name: "node", Controller: &controller}},
+		}},
+		nativePod("node-5d8f-a", "plugin-node", &metaV1.OwnerReference{Kind: "ReplicaSet", Name: "node-5d8f", Controller: &controller}),
+		nativePod("node-5d8f-b", "plugin-node", &metaV1.OwnerReference{Kind: "ReplicaSet", Name: "node-5d8f", Controller: &controller}),
+		nativePod("geth-0", "geth", nil),
+	)
+	deploymentReplicas := func() int32 {
+		d, err := client.AppsV1().Deployments("env").Get(context.Background(), "node", metaV1.GetOptions{})
+		require.NoError(t, err)
+		return *d.Spec.Replicas
+	}
+	name, err := c.Run(&experiments.PodFailure{Mode: experiments.ModeAll, LabelKey: "app", LabelValue: "plugin-node", Duration: time.Minute})
+	require.NoError(t, err)
+	require.Zero(t, deploymentReplicas())
+	status, err := c.Status(name)
+	require.NoError(t, err)
+	require.Len(t, status.SelectedPods(), 2)
+	require.NoError(t, c.Stop(name))
+	require.Equal(t, replicas, deploymentReplicas())
+
+	_, err = c.Run(&experiments.PodFailure{Mode: experiments.ModeAll, LabelKey: "app", LabelValue: "geth", Duration: time.Minute})
+	require.EqualError(t, err, "pod env/geth-0 has no owner to scale")
+	infos, err := c.List()
+	require.NoError(t, err)
+	require.Empty(t, infos)
+}
+
+func TestNativeContainerKill(t *testing.T) {
+	t.Parallel()
+
+	var killed []string
+	c, _ := nativeController(t, &NativeBackend{
+		Exec: func(ctx context.Context, namespace, pod, container string, command []string) error {
+			require.Equal(t, DefaultContainerKillCommand, command)
+			if pod == "node-1" {
+				return errors.New("container not found")
+			}
+			killed = append(killed, fmt.Sprintf("%s/%s/%s", namespace, pod, container))
+			return nil
+		},
+	},
+		nativePod("node-0", "plugin-node", nil),
+		nativePod("node-1", "plugin-node-1", nil),
+	)
+	name, err := c.Run(&experiments.ContainerKill{Mode: experiments.ModeOne, LabelKey: "app", LabelValue: "plugin-node", Container: "node"})
+	require.NoError(t, err)
+	require.Equal(t, []string{"env/node-0/node"}, killed)
+	status, err := c.Status(name)
+	require.NoError(t, err)
+	require.Equal(t, "env/node-0/node", status.Records[0].ID)
+
+	_, err = c.Run(&experiments.ContainerKill{Mode: experiments.ModeOne, LabelKey: "app", LabelValue: "plugin-node-1", Container: "node"})
+	require.EqualError(t, err, "failed to kill container node of pod env/node-1: container not found")
+}
+
+func TestNativePartition(t *testing.T) {
+	t.Parallel()
+
+	c, client := nativeController(t, &NativeBackend{},
+		nativePod("node-0", "plugin-node", nil),
+		nativePod("geth-0", "geth", nil),
+	)
+	name, err := c.Run(&experiments.NetworkPartition{
+		FromMode:       experiments.ModeAll,
+		FromLabelKey:   "app",
+		FromLabelValue: "plugin-node",
+		ToMode:         experiments.ModeAll,
+		ToLabelKey:     "app",
+		ToLabelValue:   "geth",
+	})
+	require.NoError(t, err)
+	policy, err := client.NetworkingV1().NetworkPolicies("env").Get(context.Background(), name, metaV1.GetOptions{})
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{"app": "plugin-node"}, policy.Spec.PodSelector.MatchLabels)
+	require.Equal(t, []networkingV1.PolicyType{networkingV1.PolicyTypeEgress, networkingV1.PolicyTypeIngress}, policy.Spec.PolicyTypes)
+	peers := policy.Spec.Egress[0].To
+	require.Equal(t, peers, policy.Spec.Ingress[0].From)
+	require.Len(t, peers, 2)
+	require.Equal(t, []string{"env"}, peers[0].NamespaceSelector.MatchExpressions[0].Values)
+	require.Equal(t, metaV1.LabelSelectorRequirement{Key: "app", Operator: metaV1.LabelSelectorOpNotIn, Values: []string{"geth"}},
+		peers[1].PodSelector.MatchExpressions[0])
+	require.Equal(t, ManagedByLabelValue, policy.Labels[ManagedByLabelKey])
+
+	require.NoError(t, c.Stop(name))
+	_, err = client.NetworkingV1().NetworkPolicies("env").Get(context.Background(), name, metaV1.GetOptions{})
+	require.True(t, k8sErrors.IsNotFound(err))
+
+	_, err = c.Run(&experiments.NetworkPartition{
+		FromMode:       experiments.ModeOne,
+		FromLabelKey:   "app",
+		FromLabelValue: "plugin-node",
+		ToMode:         experiments.ModeAll,
+		ToLabelKey:     "app",
+		ToLabelValue:   "geth",
+	})
+	require.True(t, errors.Is(err, ErrNotSupported))
+}
+
+func TestNativeUnsupported(t *testing.T) {
+	t.Parallel()
+
+	c, _ := nativeController(t, &NativeBackend{}, nativePod("node-0", "plugin-node", nil))
+	_, err := c.Run(&experiments.NetworkDelay{Mode: experiments.ModeAll, LabelKey: "app", LabelValue: "plugin-node", Latency: time.Second})
+	require.EqualError(t, err, "NetworkChaos delay: not supported by chaos backend")
+
+	name, err := c.Run(&experiments.PodKill{Mode: experiments.ModeAll, LabelKey: "app", LabelValue: "plugin-node"})
+	require.NoError(t, err)
+	require.True(t, errors.Is(c.Pause(name), ErrNotSupported))
+	require.NoError(t, c.StopAll())
+	require.Empty(t, c.Started())
+}
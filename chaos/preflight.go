@@ -0,0 +1,108 @@
+package chaos
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+	k8sErrors "k8s.io/apimachinery/pkg/api/errors"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	// GroupVersion Chaosmesh API group version
+	GroupVersion = "chaos-mesh.org/v1alpha1"
+	// ControllerManagerSelector selects Chaosmesh controller manager deployment in any namespace
+	ControllerManagerSelector = "app.kubernetes.io/name=chaos-mesh,app.kubernetes.io/component=controller-manager"
+	// VersionLabelKey label with the Chaosmesh version of the controller manager deployment
+	VersionLabelKey = "app.kubernetes.io/version"
+)
+
+// Support chaos kinds and Chaosmesh version installed in the cluster
+type Support struct {
+	// Kinds chaos kinds by resource, for example PodChaos by podchaos
+	Kinds map[string]string `json:"kinds"`
+	// Version Chaosmesh version, empty if controller manager is not found or can't be listed
+	Version string `json:"version,omitempty"`
+	// Namespace controller manager namespace
+	Namespace string `json:"namespace,omitempty"`
+}
+
+// Preflight discovers chaos kinds the cluster supports and Chaosmesh version, returns ErrCRDNotInstalled if
+// Chaosmesh API is missing
+func Preflight(client kubernetes.Interface) (*Support, error) {
+	resources, err := client.Discovery().ServerResourcesForGroupVersion(GroupVersion)
+	if k8sErrors.IsNotFound(err) {
+		return nil, fmt.Errorf("%w: %s API is missing in the cluster, install chaosmesh or use the native backend",
+			ErrCRDNotInstalled, GroupVersion)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to discover %s: %w", GroupVersion, err)
+	}
+	s := &Support{Kinds: map[string]string{}}
+	for _, r := range resources.APIResources {
+		// skip subresources, for example podchaos/status
+		if strings.Contains(r.Name, "/") {
+			continue
+		}
+		s.Kinds[r.Name] = r.Kind
+	}
+	deployments, err := client.AppsV1().Deployments("").List(context.Background(), metaV1.ListOptions{
+		LabelSelector: ControllerManagerSelector,
+	})
+	if err != nil {
+		log.Debug().Err(err).Msg("Failed to list Chaosmesh controller manager, version is unknown")
+		return s, nil
+	}
+	if len(deployments.Items) > 0 {
+		d := deployments.Items[0]
+		s.Namespace = d.Namespace
+		s.Version = d.Labels[VersionLabelKey]
+		if len(s.Version) == 0 && len(d.Spec.Template.Spec.Containers) > 0 {
+			image := d.Spec.Template.Spec.Containers[0].Image
+			if i := strings.LastIndex(image, ":"); i > strings.LastIndex(image, "/") {
+				s.Version = image[i+1:]
+			}
+		}
+	}
+	return s, nil
+}
+
+// KindNames returns sorted names of supported chaos kinds
+func (s *Support) KindNames() []string {
+	kinds := make([]string, 0, len(s.Kinds))
+	for _, k := range s.Kinds {
+		kinds = append(kinds, k)
+	}
+	sort.Strings(kinds)
+	return kinds
+}
+
+// Check returns ErrCRDNotInstalled if experiment resource is not supported
+func (s *Support) Check(resource string) error {
+	if _, ok := s.Kinds[resource]; ok {
+		return nil
+	}
+	version := s.Version
+	if len(version) == 0 {
+		version = "of unknown version"
+	}
+	return fmt.Errorf("%w: %s is not supported by chaosmesh %s installed in the cluster, supported kinds: %s",
+		ErrCRDNotInstalled, resource, version, strings.Join(s.KindNames(), ", "))
+}
+
+// Preflight runs preflight with the controller client, experiments of unsupported kinds are rejected afterwards
+func (c *Controller) Preflight() (*Support, error) {
+	if c.Client == nil {
+		return nil, fmt.Errorf("preflight requires Client")
+	}
+	s, err := Preflight(c.Client)
+	if err != nil {
+		return nil, err
+	}
+	c.Support = s
+	return s, nil
+}
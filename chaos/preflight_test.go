@@ -0,0 +1,72 @@
+package chaos
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/goplugin/helmenv/chaos/experiments"
+	"github.com/stretchr/testify/require"
+	appsV1 "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func chaosMeshDiscovery(client *fake.Clientset) {
+	client.Resources = []*metaV1.APIResourceList{{
+		GroupVersion: GroupVersion,
+		APIResources: []metaV1.APIResource{
+			{Name: "podchaos", Kind: "PodChaos"},
+			{Name: "podchaos/status", Kind: "PodChaos"},
+			{Name: "networkchaos", Kind: "NetworkChaos"},
+		},
+	}}
+}
+
+func TestPreflight(t *testing.T) {
+	t.Parallel()
+
+	client := fake.NewSimpleClientset()
+	_, err := Preflight(client)
+	require.True(t, errors.Is(err, ErrCRDNotInstalled))
+	require.EqualError(t, err, "chaos mesh CRD is not installed: chaos-mesh.org/v1alpha1 API is missing in the cluster, "+
+		"install chaosmesh or use the native backend")
+
+	client = fake.NewSimpleClientset(&appsV1.Deployment{
+		ObjectMeta: metaV1.ObjectMeta{
+			Name:      "chaos-controller-manager",
+			Namespace: "chaos-mesh",
+			Labels: map[string]string{
+				"app.kubernetes.io/name":      "chaos-mesh",
+				"app.kubernetes.io/component": "controller-manager",
+			},
+		},
+		Spec: appsV1.DeploymentSpec{Template: v1.PodTemplateSpec{Spec: v1.PodSpec{Containers: []v1.Container{
+			{Name: "chaos-mesh", Image: "localhost:5000/chaos-mesh/chaos-mesh:v2.1.3"},
+		}}}},
+	})
+	chaosMeshDiscovery(client)
+	s, err := Preflight(client)
+	require.NoError(t, err)
+	require.Equal(t, &Support{
+		Kinds:     map[string]string{"podchaos": "PodChaos", "networkchaos": "NetworkChaos"},
+		Version:   "v2.1.3",
+		Namespace: "chaos-mesh",
+	}, s)
+	require.NoError(t, s.Check("podchaos"))
+	require.EqualError(t, s.Check("httpchaos"), "chaos mesh CRD is not installed: httpchaos is not supported by "+
+		"chaosmesh v2.1.3 installed in the cluster, supported kinds: NetworkChaos, PodChaos")
+}
+
+func TestControllerSupport(t *testing.T) {
+	t.Parallel()
+
+	cluster := newFakeCluster("podchaos")
+	c := cluster.controller(t, "run-1")
+	c.Support = &Support{Kinds: map[string]string{"podchaos": "PodChaos"}}
+	_, err := c.Run(&experiments.PodKill{Mode: experiments.ModeOne})
+	require.NoError(t, err)
+	_, err = c.Run(&experiments.NetworkPartition{FromMode: experiments.ModeOne, ToMode: experiments.ModeAll})
+	require.True(t, errors.Is(err, ErrCRDNotInstalled))
+	require.Len(t, cluster.items["podchaos"], 1)
+}
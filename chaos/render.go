@@ -0,0 +1,25 @@
+package chaos
+
+import (
+	"github.com/goplugin/helmenv/chaos/experiments"
+)
+
+// Render returns experiment resource as JSON exactly as it's applied, without contacting the cluster,
+// the experiment gets a new name every time
+func (c *Controller) Render(exp Experimentable) ([]byte, error) {
+	payload, err := c.payloadFromStruct(exp)
+	if err != nil {
+		return nil, err
+	}
+	return payload.Data, nil
+}
+
+// RenderTemplate returns experiment resource of yaml template as JSON exactly as it's applied by RunTemplateOn,
+// without contacting the cluster
+func (c *Controller) RenderTemplate(tmplPath string, target *experiments.Selector) ([]byte, error) {
+	payload, err := c.payloadFromTemplate(tmplPath, target)
+	if err != nil {
+		return nil, err
+	}
+	return payload.Data, nil
+}
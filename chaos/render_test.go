@@ -0,0 +1,164 @@
+package chaos
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/goplugin/helmenv/chaos/experiments"
+	"github.com/stretchr/testify/require"
+)
+
+var updateGolden = flag.Bool("update", false, "update golden files in testdata")
+
+// specRequired required spec fields of Chaosmesh CRD 2.x schemas
+var specRequired = map[string][]string{
+	"PodChaos":     {"action", "mode", "selector"},
+	"NetworkChaos": {"mode", "selector"},
+	"StressChaos":  {"mode", "selector"},
+	"IOChaos":      {"action", "mode", "selector", "volumePath"},
+	"TimeChaos":    {"mode", "selector", "timeOffset"},
+	"DNSChaos":     {"action", "mode", "selector"},
+	"HTTPChaos":    {"mode", "port", "selector", "target"},
+	"KernelChaos":  {"failKernRequest", "mode", "selector"},
+	"JVMChaos":     {"action", "mode", "selector"},
+	"Schedule":     {"schedule", "type"},
+	"Workflow":     {"entry", "templates"},
+}
+
+func renderController(t *testing.T) *Controller {
+	c, err := NewController(&Config{NamespaceName: "env", RunID: "run-1"})
+	require.NoError(t, err)
+	c.newName = func(resource string) string {
+		return resource + "-golden"
+	}
+	return c
+}
+
+// requireGolden compares rendered experiment with testdata/render/<name>.json, golden files are written with -update
+func requireGolden(t *testing.T, name string, data []byte) {
+	var out bytes.Buffer
+	require.NoError(t, json.Indent(&out, data, "", "  "))
+	out.WriteByte('\n')
+	path := filepath.Join("testdata", "render", name+".json")
+	if *updateGolden {
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+		require.NoError(t, os.WriteFile(path, out.Bytes(), 0644))
+	}
+	golden, err := os.ReadFile(path)
+	require.NoError(t, err)
+	require.Equal(t, string(golden), out.String())
+}
+
+// requireSchema checks the rendered resource has fields required by Chaosmesh
+func requireSchema(t *testing.T, resource string, data []byte) {
+	var crd struct {
+		APIVersion string `json:"apiVersion"`
+		Kind       string `json:"kind"`
+		Metadata   struct {
+			Name      string            `json:"name"`
+			Namespace string            `json:"namespace"`
+			Labels    map[string]string `json:"labels"`
+		} `json:"metadata"`
+		Spec map[string]interface{} `json:"spec"`
+	}
+	require.NoError(t, json.Unmarshal(data, &crd))
+	require.Equal(t, GroupVersion, crd.APIVersion)
+	require.Equal(t, resource+"-golden", crd.Metadata.Name)
+	require.Equal(t, "env", crd.Metadata.Namespace)
+	require.Equal(t, ManagedByLabelValue, crd.Metadata.Labels[ManagedByLabelKey])
+	required, ok := specRequired[crd.Kind]
+	require.True(t, ok, "unknown kind %s", crd.Kind)
+	for _, field := range required {
+		require.Contains(t, crd.Spec, field, "%s spec", crd.Kind)
+	}
+}
+
+func TestRenderGolden(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		exp  Experimentable
+	}{
+		{"pod-kill", &experiments.PodKill{Mode: "one", LabelKey: "app", LabelValue: "plugin-node"}},
+		{"pod-failure", &experiments.PodFailure{Mode: "all", LabelKey: "app", LabelValue: "plugin-node", Duration: time.Minute}},
+		{"container-kill", &experiments.ContainerKill{Mode: "one", LabelKey: "app", LabelValue: "plugin-node", Container: "node"}},
+		{"cpu-hog", &experiments.CPUHog{Mode: "one", LabelKey: "app", LabelValue: "geth", Workers: 2, Load: 80, OptsCPU: 1, Duration: time.Minute}},
+		{"memory-stress", &experiments.MemoryStress{Mode: "one", LabelKey: "app", LabelValue: "geth", Workers: 1, Size: "256MB", Duration: time.Minute}},
+		{"dns-chaos", &experiments.DNSChaos{Patterns: []string{"google.com"}, Duration: time.Minute}},
+		{"io-delay", &experiments.IODelay{Mode: "one", VolumePath: "/data", Path: "/data/**/*", Delay: 100 * time.Millisecond, Percent: 50}},
+		{"io-fault", &experiments.IOFault{Mode: "one", VolumePath: "/data", Errno: 5, Percent: 100}},
+		{"network-bandwidth", &experiments.NetworkBandwidth{Mode: "all", Rate: "1mbps", Limit: 100, Buffer: 10000}},
+		{"network-corrupt", &experiments.NetworkCorrupt{Mode: "all", Corrupt: 40, Correlation: 25}},
+		{"network-delay", &experiments.NetworkDelay{Mode: "all", Latency: 300 * time.Millisecond, Duration: time.Minute}},
+		{"network-duplicate", &experiments.NetworkDuplicate{Mode: "all", Duplicate: 40, Correlation: 25}},
+		{"network-loss", &experiments.NetworkLoss{
+			Mode:   "all",
+			Loss:   40,
+			Target: &experiments.Selector{Labels: map[string]string{"app": "geth"}},
+		}},
+		{"network-partition", &experiments.NetworkPartition{
+			FromMode:       "all",
+			FromLabelKey:   "app",
+			FromLabelValue: "plugin-node",
+			ToMode:         "all",
+			ToLabelKey:     "app",
+			ToLabelValue:   "geth",
+		}},
+		{"time-shift", &experiments.TimeShift{Mode: "one", TimeOffset: -time.Hour}},
+		{"http-abort", &experiments.HTTPAbort{HTTPRule: experiments.HTTPRule{Mode: "all", LabelKey: "app", LabelValue: "mockserver", Port: 1080, Path: "/api/*"}}},
+		{"http-delay", &experiments.HTTPDelay{
+			HTTPRule: experiments.HTTPRule{Mode: "all", LabelKey: "app", LabelValue: "mockserver", Port: 1080},
+			Delay:    time.Second,
+		}},
+		{"http-replace", &experiments.HTTPReplace{
+			HTTPRule: experiments.HTTPRule{Mode: "all", LabelKey: "app", LabelValue: "mockserver", Port: 1080, Target: experiments.HTTPTargetResponse},
+			Code:     503,
+			Body:     []byte("unavailable"),
+		}},
+		{"kernel-fault", &experiments.KernelFault{Mode: "one", Callchain: []string{"__x64_sys_mount"}, FailType: experiments.KernelFailSlab, Probability: 50}},
+		{"jvm-chaos", &experiments.JVMChaos{Mode: "one", Action: experiments.JVMActionLatency, Class: "Main", Method: "handle", Port: 9277, Latency: time.Second}},
+		{"schedule", &experiments.Schedule{
+			Cron:       "@every 5m",
+			Experiment: &experiments.PodKill{Mode: "one", LabelKey: "app", LabelValue: "plugin-node"},
+		}},
+		{"workflow", &experiments.Workflow{Steps: []experiments.WorkflowStep{
+			experiments.Serial("entry", "kill", "pause", "delay"),
+			experiments.Chaos("kill", 30*time.Second, &experiments.PodKill{Mode: "one", LabelKey: "app", LabelValue: "geth"}),
+			experiments.Suspend("pause", time.Minute),
+			experiments.Chaos("delay", time.Minute, &experiments.NetworkDelay{Mode: "all", Latency: time.Second}),
+		}}},
+	}
+	c := renderController(t)
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			data, err := c.Render(test.exp)
+			require.NoError(t, err)
+			requireSchema(t, test.exp.Resource(), data)
+			requireGolden(t, test.name, data)
+		})
+	}
+}
+
+func TestRenderTemplate(t *testing.T) {
+	t.Parallel()
+
+	c := renderController(t)
+	data, err := c.RenderTemplate("../examples/chaos/schedule-pod-kill.yml", &experiments.Selector{
+		Mode:   experiments.ModeAll,
+		Labels: map[string]string{"release": "plugin", "app": "plugin-node"},
+	})
+	require.NoError(t, err)
+	requireSchema(t, "schedules", data)
+	requireGolden(t, "template-schedule-pod-kill", data)
+
+	_, err = c.Render(&experiments.PodKill{})
+	require.Error(t, err, "experiments are validated before rendering")
+	require.Empty(t, c.Timeline(), "rendering doesn't apply experiments")
+}
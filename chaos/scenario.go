@@ -0,0 +1,450 @@
+package chaos
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ghodss/yaml"
+	"github.com/goplugin/helmenv/chaos/experiments"
+	"github.com/rs/zerolog/log"
+	"golang.org/x/sync/errgroup"
+)
+
+const (
+	// EventApplied experiment was applied
+	EventApplied = "applied"
+	// EventInjected chaos was injected into every target
+	EventInjected = "injected"
+	// EventStopped experiment was stopped
+	EventStopped = "stopped"
+	// EventCheckPassed steady state check passed
+	EventCheckPassed = "check_passed"
+	// EventCheckFailed steady state check failed
+	EventCheckFailed = "check_failed"
+	// EventFailed experiment couldn't be applied or stopped
+	EventFailed = "failed"
+)
+
+// Duration duration unmarshalled from strings like 1m30s or from nanoseconds
+type Duration time.Duration
+
+// MarshalJSON marshals duration as a string
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+// UnmarshalJSON unmarshals duration from a string or nanoseconds
+func (d *Duration) UnmarshalJSON(b []byte) error {
+	var v interface{}
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err
+	}
+	switch value := v.(type) {
+	case float64:
+		*d = Duration(time.Duration(value))
+		return nil
+	case string:
+		tmp, err := time.ParseDuration(value)
+		if err != nil {
+			return err
+		}
+		*d = Duration(tmp)
+		return nil
+	default:
+		return errors.New("invalid duration")
+	}
+}
+
+// Check steady state check, returns error if the system is not healthy
+type Check func(ctx context.Context) error
+
+// Scenario experiments applied and stopped on a timeline, with steady state checks around them
+type Scenario struct {
+	Name        string                `json:"name"`
+	Experiments []*ScenarioExperiment `json:"experiments"`
+	Checks      []*ScenarioCheck      `json:"checks,omitempty"`
+}
+
+// ScenarioExperiment experiment of a scenario, applied Repeat times every Interval starting at Start offset,
+// every run lasts Duration, or until the scenario ends if Duration is zero
+type ScenarioExperiment struct {
+	Name string `json:"name"`
+	// Template path of a chaos template, relative to the scenario file
+	Template string `json:"template,omitempty"`
+	// Experiment experiment applied instead of a template
+	Experiment Experimentable `json:"-"`
+	Start      Duration       `json:"start,omitempty"`
+	Duration   Duration       `json:"duration,omitempty"`
+	Repeat     int            `json:"repeat,omitempty"`
+	Interval   Duration       `json:"interval,omitempty"`
+	// InjectTimeout waits for chaos to be injected after every apply when set
+	InjectTimeout Duration `json:"inject_timeout,omitempty"`
+	// Checks names of the checks run before every apply and after every stop
+	Checks []string `json:"checks,omitempty"`
+}
+
+// ScenarioCheck named steady state check, either Func or one of the declarative probes, which are run
+// by the environment, see environment.RunChaosScenario
+type ScenarioCheck struct {
+	Name        string            `json:"name"`
+	HTTP        *HTTPCheck        `json:"http,omitempty"`
+	BlockHeight *BlockHeightCheck `json:"block_height,omitempty"`
+	PodsReady   *PodsReadyCheck   `json:"pods_ready,omitempty"`
+	// Timeout of a declarative probe, defaults to the environment probe timeout
+	Timeout Duration `json:"timeout,omitempty"`
+	Func    Check    `json:"-"`
+}
+
+// CheckEndpoint port of a chart connection probed by a check
+type CheckEndpoint struct {
+	Chart string `json:"chart"`
+	// Connection chart connection key, for example plugin-node_0_node
+	Connection string `json:"connection"`
+	// Port named port, defaults to access
+	Port string `json:"port,omitempty"`
+}
+
+// HTTPCheck checks response status and body of an endpoint
+type HTTPCheck struct {
+	CheckEndpoint
+	Method    string `json:"method,omitempty"`
+	Path      string `json:"path,omitempty"`
+	Body      string `json:"body,omitempty"`
+	Status    int    `json:"status,omitempty"`
+	BodyMatch string `json:"body_match,omitempty"`
+}
+
+// BlockHeightCheck checks that a JSON-RPC node produces MinBlocks blocks in Window
+type BlockHeightCheck struct {
+	CheckEndpoint
+	MinBlocks    int      `json:"min_blocks,omitempty"`
+	Window       Duration `json:"window,omitempty"`
+	PollInterval Duration `json:"poll_interval,omitempty"`
+}
+
+// PodsReadyCheck checks that at least Min pods of a chart app are ready, every pod must be ready if Min is zero
+type PodsReadyCheck struct {
+	Chart string `json:"chart"`
+	App   string `json:"app,omitempty"`
+	Min   int    `json:"min,omitempty"`
+}
+
+// probes returns amount of declarative probes of the check
+func (c *ScenarioCheck) probes() int {
+	n := 0
+	for _, set := range []bool{c.HTTP != nil, c.BlockHeight != nil, c.PodsReady != nil} {
+		if set {
+			n++
+		}
+	}
+	return n
+}
+
+// ScenarioEvent timeline event of a scenario
+type ScenarioEvent struct {
+	Time time.Time `json:"time"`
+	// Offset time since the scenario start
+	Offset     time.Duration `json:"offset"`
+	Type       string        `json:"type"`
+	Experiment string        `json:"experiment,omitempty"`
+	// Run zero based number of the experiment run
+	Run   int    `json:"run"`
+	Name  string `json:"name,omitempty"`
+	Check string `json:"check,omitempty"`
+	Error string `json:"error,omitempty"`
+}
+
+// ScenarioResult timeline of a scenario run
+type ScenarioResult struct {
+	Name     string           `json:"name"`
+	Start    time.Time        `json:"start"`
+	End      time.Time        `json:"end"`
+	Timeline []*ScenarioEvent `json:"timeline"`
+}
+
+// LoadScenario reads scenario from a yaml file, template paths are resolved relative to the file
+func LoadScenario(path string) (*Scenario, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var s Scenario
+	if err := yaml.Unmarshal(data, &s); err != nil {
+		return nil, fmt.Errorf("scenario %s: %w", path, err)
+	}
+	for _, e := range s.Experiments {
+		if len(e.Template) > 0 && !filepath.IsAbs(e.Template) {
+			e.Template = filepath.Join(filepath.Dir(path), e.Template)
+		}
+	}
+	return &s, nil
+}
+
+// Validate checks scenario experiments and checks
+func (s *Scenario) Validate() error {
+	var errs []string
+	fail := func(format string, args ...interface{}) {
+		errs = append(errs, fmt.Sprintf(format, args...))
+	}
+	if len(s.Experiments) == 0 {
+		fail("experiments are required")
+	}
+	checks := map[string]bool{}
+	for _, c := range s.Checks {
+		if checks[c.Name] {
+			fail("check %s is duplicated", c.Name)
+		}
+		checks[c.Name] = true
+		switch probes := c.probes(); {
+		case c.Func == nil && probes == 0:
+			fail("check %s has nothing to run", c.Name)
+		case probes > 1 || (c.Func != nil && probes > 0):
+			fail("check %s must have only one of func, http, block_height and pods_ready", c.Name)
+		}
+		var endpoints []CheckEndpoint
+		if c.HTTP != nil {
+			endpoints = append(endpoints, c.HTTP.CheckEndpoint)
+		}
+		if c.BlockHeight != nil {
+			endpoints = append(endpoints, c.BlockHeight.CheckEndpoint)
+		}
+		for _, e := range endpoints {
+			if len(e.Chart) == 0 || len(e.Connection) == 0 {
+				fail("check %s requires chart and connection", c.Name)
+			}
+		}
+		if c.PodsReady != nil && len(c.PodsReady.Chart) == 0 {
+			fail("check %s requires chart", c.Name)
+		}
+		if c.Timeout < 0 {
+			fail("check %s timeout must not be negative", c.Name)
+		}
+	}
+	names := map[string]bool{}
+	for _, e := range s.Experiments {
+		if names[e.Name] {
+			fail("experiment %s is duplicated", e.Name)
+		}
+		names[e.Name] = true
+		if len(e.Name) == 0 {
+			fail("experiment name is required")
+		}
+		if (len(e.Template) == 0) == (e.Experiment == nil) {
+			fail("experiment %s requires either template or experiment", e.Name)
+		}
+		if e.Start < 0 || e.Duration < 0 || e.Interval < 0 || e.Repeat < 0 || e.InjectTimeout < 0 {
+			fail("experiment %s timings must not be negative", e.Name)
+		}
+		if e.Repeat > 1 && (e.Duration == 0 || e.Interval < e.Duration) {
+			fail("experiment %s repeats require duration and an interval not shorter than it", e.Name)
+		}
+		if v, ok := e.Experiment.(experiments.Validator); ok {
+			if err := v.Validate(); err != nil {
+				fail("experiment %s: %s", e.Name, err)
+			}
+		}
+		for _, c := range e.Checks {
+			if !checks[c] {
+				fail("check %s of experiment %s not found", c, e.Name)
+			}
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("invalid scenario %s: %s", s.Name, strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// scenarioRun state of a running scenario
+type scenarioRun struct {
+	c      *Controller
+	s      *Scenario
+	checks map[string]Check
+	start  time.Time
+
+	mu      sync.Mutex
+	result  *ScenarioResult
+	applied map[string]*ScenarioEvent
+}
+
+// RunScenario runs scenario until every experiment run is stopped, the first failure or ctx cancellation
+// stops the scenario, experiments applied by the scenario are always stopped
+func (c *Controller) RunScenario(ctx context.Context, s *Scenario) (*ScenarioResult, error) {
+	if err := s.Validate(); err != nil {
+		return nil, err
+	}
+	r := &scenarioRun{
+		c:       c,
+		s:       s,
+		checks:  map[string]Check{},
+		start:   time.Now(),
+		applied: map[string]*ScenarioEvent{},
+	}
+	for _, check := range s.Checks {
+		if check.Func == nil {
+			return nil, fmt.Errorf("scenario %s: check %s probe must be run by an environment", s.Name, check.Name)
+		}
+		r.checks[check.Name] = check.Func
+	}
+	r.result = &ScenarioResult{Name: s.Name, Start: r.start, Timeline: make([]*ScenarioEvent, 0)}
+	log.Info().Str("Name", s.Name).Int("Experiments", len(s.Experiments)).Msg("Running chaos scenario")
+
+	g, gctx := errgroup.WithContext(ctx)
+	for _, e := range s.Experiments {
+		e := e
+		g.Go(func() error {
+			return r.runExperiment(gctx, e)
+		})
+	}
+	err := g.Wait()
+	if cleanupErr := r.cleanup(); err == nil {
+		err = cleanupErr
+	}
+	r.result.End = time.Now()
+	if err != nil {
+		return r.result, fmt.Errorf("scenario %s: %w", s.Name, err)
+	}
+	return r.result, nil
+}
+
+func (r *scenarioRun) runExperiment(ctx context.Context, e *ScenarioExperiment) error {
+	runs := e.Repeat
+	if runs == 0 {
+		runs = 1
+	}
+	for run := 0; run < runs; run++ {
+		applyAt := time.Duration(e.Start) + time.Duration(run)*time.Duration(e.Interval)
+		if err := r.sleepUntil(ctx, applyAt); err != nil {
+			return err
+		}
+		if err := r.check(ctx, e, run); err != nil {
+			return err
+		}
+		name, err := r.apply(ctx, e, run)
+		if err != nil {
+			return err
+		}
+		if e.Duration == 0 {
+			// stopped on cleanup
+			return nil
+		}
+		if err := r.sleepUntil(ctx, applyAt+time.Duration(e.Duration)); err != nil {
+			return err
+		}
+		if err := r.stop(name); err != nil {
+			return err
+		}
+		if err := r.check(ctx, e, run); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *scenarioRun) sleepUntil(ctx context.Context, offset time.Duration) error {
+	t := time.NewTimer(time.Until(r.start.Add(offset)))
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
+
+func (r *scenarioRun) apply(ctx context.Context, e *ScenarioExperiment, run int) (string, error) {
+	var info *ExperimentInfo
+	var err error
+	if e.Experiment != nil {
+		info, err = r.c.run(e.Experiment)
+	} else {
+		info, err = r.c.RunTemplate(e.Template)
+	}
+	if err != nil {
+		r.record(&ScenarioEvent{Type: EventFailed, Experiment: e.Name, Run: run, Error: err.Error()})
+		return "", fmt.Errorf("experiment %s: %w", e.Name, err)
+	}
+	name := info.Name
+	event := r.record(&ScenarioEvent{Type: EventApplied, Experiment: e.Name, Run: run, Name: name})
+	r.mu.Lock()
+	r.applied[name] = event
+	r.mu.Unlock()
+	if e.InjectTimeout > 0 {
+		if _, err := r.c.waitInjected(info, time.Duration(e.InjectTimeout)); err != nil {
+			r.record(&ScenarioEvent{Type: EventFailed, Experiment: e.Name, Run: run, Name: name, Error: err.Error()})
+			return "", fmt.Errorf("experiment %s: %w", e.Name, err)
+		}
+		r.record(&ScenarioEvent{Type: EventInjected, Experiment: e.Name, Run: run, Name: name})
+	}
+	return name, ctx.Err()
+}
+
+func (r *scenarioRun) stop(name string) error {
+	r.mu.Lock()
+	applied := r.applied[name]
+	delete(r.applied, name)
+	r.mu.Unlock()
+	if err := r.c.Stop(name); err != nil {
+		r.record(&ScenarioEvent{Type: EventFailed, Experiment: applied.Experiment, Run: applied.Run, Name: name, Error: err.Error()})
+		return fmt.Errorf("experiment %s: %w", applied.Experiment, err)
+	}
+	r.record(&ScenarioEvent{Type: EventStopped, Experiment: applied.Experiment, Run: applied.Run, Name: name})
+	return nil
+}
+
+// check runs steady state checks of the experiment
+func (r *scenarioRun) check(ctx context.Context, e *ScenarioExperiment, run int) error {
+	for _, name := range e.Checks {
+		if err := r.checks[name](ctx); err != nil {
+			r.record(&ScenarioEvent{Type: EventCheckFailed, Experiment: e.Name, Run: run, Check: name, Error: err.Error()})
+			return fmt.Errorf("check %s of experiment %s: %w", name, e.Name, err)
+		}
+		r.record(&ScenarioEvent{Type: EventCheckPassed, Experiment: e.Name, Run: run, Check: name})
+	}
+	return nil
+}
+
+// cleanup stops every experiment still applied, in the order they were applied
+func (r *scenarioRun) cleanup() error {
+	r.mu.Lock()
+	names := make([]string, 0, len(r.applied))
+	for name := range r.applied {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		return r.applied[names[i]].Time.Before(r.applied[names[j]].Time)
+	})
+	r.mu.Unlock()
+	var firstErr error
+	for _, name := range names {
+		if err := r.stop(name); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+func (r *scenarioRun) record(e *ScenarioEvent) *ScenarioEvent {
+	e.Time = time.Now()
+	e.Offset = e.Time.Sub(r.start)
+	log.Info().
+		Str("Scenario", r.s.Name).
+		Str("Experiment", e.Experiment).
+		Int("Run", e.Run).
+		Str("Name", e.Name).
+		Str("Check", e.Check).
+		Str("Error", e.Error).
+		Msgf("Chaos scenario event %s", e.Type)
+	r.mu.Lock()
+	r.result.Timeline = append(r.result.Timeline, e)
+	r.mu.Unlock()
+	return e
+}
@@ -0,0 +1,197 @@
+package chaos
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/goplugin/helmenv/chaos/experiments"
+	"github.com/stretchr/testify/require"
+)
+
+// eventTypes returns experiment, run and type of every timeline event of the experiment
+func eventTypes(res *ScenarioResult, experiment string) []string {
+	types := make([]string, 0)
+	for _, e := range res.Timeline {
+		if e.Experiment == experiment {
+			types = append(types, e.Type)
+		}
+	}
+	return types
+}
+
+func TestRunScenario(t *testing.T) {
+	t.Parallel()
+
+	cluster := newFakeCluster("podchaos", "networkchaos")
+	c := cluster.controller(t, "run-1")
+	tmpl := filepath.Join(t.TempDir(), "kill.yml")
+	require.NoError(t, os.WriteFile(tmpl, []byte("resource: podchaos\nkind: PodChaos\nspec:\n  action: pod-kill\n"), 0644))
+	var checks int32
+	res, err := c.RunScenario(context.Background(), &Scenario{
+		Name: "delays",
+		Experiments: []*ScenarioExperiment{
+			{
+				Name:       "delay",
+				Experiment: &experiments.NetworkDelay{Mode: "all", Latency: time.Second},
+				Duration:   Duration(20 * time.Millisecond),
+				Repeat:     2,
+				Interval:   Duration(40 * time.Millisecond),
+				Checks:     []string{"healthy"},
+			},
+			{Name: "kill", Template: tmpl, Start: Duration(10 * time.Millisecond)},
+		},
+		Checks: []*ScenarioCheck{{Name: "healthy", Func: func(ctx context.Context) error {
+			atomic.AddInt32(&checks, 1)
+			return nil
+		}}},
+	})
+	require.NoError(t, err)
+	require.Equal(t, []string{
+		EventCheckPassed, EventApplied, EventStopped, EventCheckPassed,
+		EventCheckPassed, EventApplied, EventStopped, EventCheckPassed,
+	}, eventTypes(res, "delay"))
+	require.Equal(t, []string{EventApplied, EventStopped}, eventTypes(res, "kill"))
+	require.Equal(t, int32(4), checks)
+	require.Len(t, cluster.deleted, 3)
+	require.Empty(t, cluster.items["podchaos"])
+	require.Empty(t, cluster.items["networkchaos"])
+	require.Empty(t, c.Started())
+
+	for _, e := range res.Timeline {
+		if e.Experiment == "delay" && e.Type == EventApplied {
+			require.GreaterOrEqual(t, e.Offset, time.Duration(e.Run)*40*time.Millisecond)
+		}
+	}
+	require.False(t, res.End.Before(res.Start))
+}
+
+func TestRunScenarioCleansUpOnCancel(t *testing.T) {
+	t.Parallel()
+
+	cluster := newFakeCluster("podchaos")
+	c := cluster.controller(t, "run-1")
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Millisecond)
+	defer cancel()
+	res, err := c.RunScenario(ctx, &Scenario{
+		Name: "long",
+		Experiments: []*ScenarioExperiment{
+			{Name: "kill", Experiment: &experiments.PodKill{Mode: "one"}, Duration: Duration(time.Hour)},
+			{Name: "late", Experiment: &experiments.PodKill{Mode: "all"}, Start: Duration(time.Hour)},
+		},
+	})
+	require.True(t, errors.Is(err, context.DeadlineExceeded), err)
+	require.Equal(t, []string{EventApplied, EventStopped}, eventTypes(res, "kill"))
+	require.Empty(t, eventTypes(res, "late"))
+	require.Empty(t, cluster.items["podchaos"])
+}
+
+func TestRunScenarioFailedCheck(t *testing.T) {
+	t.Parallel()
+
+	cluster := newFakeCluster("podchaos")
+	// forever is the only experiment created before kill is applied
+	created := make(chan struct{})
+	var once sync.Once
+	cluster.onCreate = func(string) { once.Do(func() { close(created) }) }
+	c := cluster.controller(t, "run-1")
+	res, err := c.RunScenario(context.Background(), &Scenario{
+		Name: "unhealthy",
+		Experiments: []*ScenarioExperiment{
+			{Name: "kill", Experiment: &experiments.PodKill{Mode: "one"}, Duration: Duration(time.Millisecond), Checks: []string{"nodes"}},
+			{Name: "forever", Experiment: &experiments.PodKill{Mode: "all"}},
+		},
+		Checks: []*ScenarioCheck{{Name: "nodes", Func: func(ctx context.Context) error {
+			// kill is applied after forever, so the failed check cancels the scenario with both applied
+			select {
+			case <-created:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+			cluster.mu.Lock()
+			defer cluster.mu.Unlock()
+			if len(cluster.deleted) == 0 {
+				return nil
+			}
+			return errors.New("node 2 is down")
+		}}},
+	})
+	require.EqualError(t, err, "scenario unhealthy: check nodes of experiment kill: node 2 is down")
+	require.Equal(t, []string{EventCheckPassed, EventApplied, EventStopped, EventCheckFailed}, eventTypes(res, "kill"))
+	require.Equal(t, []string{EventApplied, EventStopped}, eventTypes(res, "forever"))
+	require.Empty(t, cluster.items["podchaos"])
+}
+
+func TestScenarioValidation(t *testing.T) {
+	t.Parallel()
+
+	s := &Scenario{
+		Name: "invalid",
+		Experiments: []*ScenarioExperiment{
+			{Name: "kill", Template: "kill.yml", Experiment: &experiments.PodKill{Mode: "one"}},
+			{Name: "kill", Experiment: &experiments.PodKill{}, Repeat: 2, Checks: []string{"missing"}},
+		},
+		Checks: []*ScenarioCheck{{Name: "empty"}},
+	}
+	require.EqualError(t, s.Validate(), "invalid scenario invalid: check empty has nothing to run; "+
+		"experiment kill requires either template or experiment; "+
+		"experiment kill is duplicated; "+
+		"experiment kill repeats require duration and an interval not shorter than it; "+
+		"experiment kill: invalid PodChaos experiment: mode is required; "+
+		"check missing of experiment kill not found")
+}
+
+func TestLoadScenario(t *testing.T) {
+	t.Parallel()
+
+	s, err := LoadScenario("../examples/chaos/scenario.yml")
+	require.NoError(t, err)
+	require.Equal(t, "nodes-under-chaos", s.Name)
+	require.Len(t, s.Experiments, 2)
+	kill := s.Experiments[0]
+	require.Equal(t, filepath.Join("..", "examples", "chaos", "schedule-pod-kill.yml"), kill.Template)
+	require.Equal(t, Duration(time.Minute), kill.Duration)
+	require.Equal(t, 3, kill.Repeat)
+	require.Equal(t, Duration(5*time.Minute), kill.Interval)
+	require.Equal(t, []string{"blocks", "nodes"}, kill.Checks)
+	require.NoError(t, s.Validate())
+
+	require.Len(t, s.Checks, 3)
+	blocks := s.Checks[0]
+	require.Equal(t, &BlockHeightCheck{
+		CheckEndpoint: CheckEndpoint{Chart: "geth", Connection: "geth_0_geth-network", Port: "http-rpc"},
+		MinBlocks:     3,
+		Window:        Duration(30 * time.Second),
+	}, blocks.BlockHeight)
+	require.Equal(t, Duration(45*time.Second), blocks.Timeout)
+	require.Equal(t, &PodsReadyCheck{Chart: "plugin", App: "plugin-node", Min: 1}, s.Checks[1].PodsReady)
+	require.Equal(t, "/health", s.Checks[2].HTTP.Path)
+
+	// probes of declarative checks are run by an environment
+	_, err = newFakeCluster("podchaos").controller(t, "run-1").RunScenario(context.Background(), s)
+	require.EqualError(t, err, "scenario nodes-under-chaos: check blocks probe must be run by an environment")
+}
+
+func TestScenarioCheckValidation(t *testing.T) {
+	t.Parallel()
+
+	s := &Scenario{
+		Name:        "invalid",
+		Experiments: []*ScenarioExperiment{{Name: "kill", Experiment: &experiments.PodKill{Mode: "one"}}},
+		Checks: []*ScenarioCheck{
+			{Name: "both", PodsReady: &PodsReadyCheck{Chart: "plugin"}, Func: func(ctx context.Context) error { return nil }},
+			{Name: "http", HTTP: &HTTPCheck{CheckEndpoint: CheckEndpoint{Chart: "plugin"}}},
+			{Name: "pods", PodsReady: &PodsReadyCheck{}, Timeout: Duration(-time.Second)},
+		},
+	}
+	require.EqualError(t, s.Validate(), "invalid scenario invalid: "+
+		"check both must have only one of func, http, block_height and pods_ready; "+
+		"check http requires chart and connection; "+
+		"check pods requires chart; "+
+		"check pods timeout must not be negative")
+}
@@ -0,0 +1,238 @@
+package chaos
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	k8sErrors "k8s.io/apimachinery/pkg/api/errors"
+)
+
+const (
+	// ConditionSelected experiment selected target pods
+	ConditionSelected = "Selected"
+	// ConditionAllInjected chaos is injected into every selected target
+	ConditionAllInjected = "AllInjected"
+	// ConditionAllRecovered chaos is recovered in every selected target
+	ConditionAllRecovered = "AllRecovered"
+	// ConditionPaused experiment is paused
+	ConditionPaused = "Paused"
+
+	// RecordEventFailed record event type for a failed apply or recover operation
+	RecordEventFailed = "Failed"
+
+	// StatusPollInterval interval between experiment status checks while waiting
+	StatusPollInterval = 1 * time.Second
+)
+
+// ExperimentStatus status of a Chaosmesh experiment CRD instance
+type ExperimentStatus struct {
+	Name         string      `json:"name"`
+	Resource     string      `json:"resource"`
+	Conditions   []Condition `json:"conditions,omitempty"`
+	DesiredPhase string      `json:"desiredPhase,omitempty"`
+	Records      []Record    `json:"containerRecords,omitempty"`
+}
+
+// Condition experiment status condition, Status is "True", "False" or "Unknown"
+type Condition struct {
+	Type   string `json:"type"`
+	Status string `json:"status"`
+	Reason string `json:"reason,omitempty"`
+}
+
+// Record injection record for a single target, ID is namespace/pod or namespace/pod/container
+type Record struct {
+	ID             string        `json:"id"`
+	SelectorKey    string        `json:"selectorKey,omitempty"`
+	Phase          string        `json:"phase"`
+	InjectedCount  int           `json:"injectedCount"`
+	RecoveredCount int           `json:"recoveredCount"`
+	Events         []RecordEvent `json:"events,omitempty"`
+}
+
+// RecordEvent apply or recover operation of a record
+type RecordEvent struct {
+	Type      string    `json:"type"`
+	Operation string    `json:"operation"`
+	Message   string    `json:"message,omitempty"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// crdStatus Chaosmesh CRD status as it's returned from API server
+type crdStatus struct {
+	Status struct {
+		Conditions []Condition `json:"conditions"`
+		Experiment struct {
+			DesiredPhase string   `json:"desiredPhase"`
+			Records      []Record `json:"containerRecords"`
+		} `json:"experiment"`
+	} `json:"status"`
+}
+
+// Condition returns true if condition of the type has "True" status
+func (s *ExperimentStatus) Condition(conditionType string) bool {
+	for _, c := range s.Conditions {
+		if c.Type == conditionType {
+			return c.Status == "True"
+		}
+	}
+	return false
+}
+
+// Selected returns true if experiment selected its targets
+func (s *ExperimentStatus) Selected() bool {
+	return s.Condition(ConditionSelected)
+}
+
+// AllInjected returns true if chaos is injected into every target
+func (s *ExperimentStatus) AllInjected() bool {
+	return s.Condition(ConditionAllInjected)
+}
+
+// AllRecovered returns true if every target is recovered from chaos
+func (s *ExperimentStatus) AllRecovered() bool {
+	return s.Condition(ConditionAllRecovered)
+}
+
+// Paused returns true if experiment is paused
+func (s *ExperimentStatus) Paused() bool {
+	return s.Condition(ConditionPaused)
+}
+
+// SelectedPods returns namespace/pod names of every selected target
+func (s *ExperimentStatus) SelectedPods() []string {
+	pods := make([]string, 0)
+	seen := map[string]bool{}
+	for _, r := range s.Records {
+		parts := strings.SplitN(r.ID, "/", 3)
+		pod := r.ID
+		if len(parts) >= 2 {
+			pod = parts[0] + "/" + parts[1]
+		}
+		if !seen[pod] {
+			seen[pod] = true
+			pods = append(pods, pod)
+		}
+	}
+	return pods
+}
+
+// FailedRecords returns records which last apply or recover operation failed
+func (s *ExperimentStatus) FailedRecords() []Record {
+	failed := make([]Record, 0)
+	for _, r := range s.Records {
+		if len(r.Events) > 0 && r.Events[len(r.Events)-1].Type == RecordEventFailed {
+			failed = append(failed, r)
+		}
+	}
+	return failed
+}
+
+// failures describes failed records for errors
+func (s *ExperimentStatus) failures() string {
+	var msgs []string
+	for _, r := range s.FailedRecords() {
+		e := r.Events[len(r.Events)-1]
+		msgs = append(msgs, fmt.Sprintf("%s: %s %s", r.ID, e.Operation, e.Message))
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// Status reads experiment status from the cluster
+func (c *Controller) Status(name string) (*ExperimentStatus, error) {
+	info, ok := c.Experiment(name)
+	if !ok {
+		return nil, fmt.Errorf("experiment %s not found", name)
+	}
+	return c.StatusOf(info)
+}
+
+// StatusOf reads status of any experiment from the cluster, including ones started by another process
+func (c *Controller) StatusOf(info *ExperimentInfo) (*ExperimentStatus, error) {
+	s, err := c.Backend.Status(info)
+	if err != nil {
+		return nil, err
+	}
+	c.observe(s)
+	return s, nil
+}
+
+func parseStatus(info *ExperimentInfo, raw []byte) (*ExperimentStatus, error) {
+	var crd crdStatus
+	if err := json.Unmarshal(raw, &crd); err != nil {
+		return nil, err
+	}
+	return &ExperimentStatus{
+		Name:         info.Name,
+		Resource:     info.Resource,
+		Conditions:   crd.Status.Conditions,
+		DesiredPhase: crd.Status.Experiment.DesiredPhase,
+		Records:      crd.Status.Experiment.Records,
+	}, nil
+}
+
+// WaitInjected waits until chaos is injected into every selected target
+func (c *Controller) WaitInjected(name string, timeout time.Duration) (*ExperimentStatus, error) {
+	info, ok := c.Experiment(name)
+	if !ok {
+		return nil, fmt.Errorf("experiment %s not found", name)
+	}
+	return c.waitInjected(info, timeout)
+}
+
+func (c *Controller) waitInjected(info *ExperimentInfo, timeout time.Duration) (*ExperimentStatus, error) {
+	return c.waitCondition(info, "injected", timeout, func(s *ExperimentStatus) bool {
+		return s.Selected() && s.AllInjected()
+	})
+}
+
+// WaitRecovered waits until every target is recovered, deleted experiment is considered recovered
+func (c *Controller) WaitRecovered(name string, timeout time.Duration) (*ExperimentStatus, error) {
+	info, ok := c.Experiment(name)
+	if !ok {
+		return nil, fmt.Errorf("experiment %s not found", name)
+	}
+	return c.waitCondition(info, "recovered", timeout, func(s *ExperimentStatus) bool {
+		return s.AllRecovered()
+	})
+}
+
+func (c *Controller) waitCondition(
+	info *ExperimentInfo,
+	state string,
+	timeout time.Duration,
+	done func(s *ExperimentStatus) bool,
+) (*ExperimentStatus, error) {
+	name := info.Name
+	log.Info().
+		Str("Name", name).
+		Str("Timeout", timeout.String()).
+		Msgf("Waiting for chaos experiment to be %s", state)
+	deadline := time.Now().Add(timeout)
+	var last *ExperimentStatus
+	for {
+		s, err := c.StatusOf(info)
+		switch {
+		case k8sErrors.IsNotFound(err) && state == "recovered":
+			c.observeDeleted(info)
+			return &ExperimentStatus{Name: info.Name, Resource: info.Resource}, nil
+		case err != nil:
+			return nil, err
+		case done(s):
+			return s, nil
+		}
+		last = s
+		if time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(StatusPollInterval)
+	}
+	err := fmt.Errorf("experiment %s wasn't %s in %s", name, state, timeout)
+	if failures := last.failures(); len(failures) > 0 {
+		err = fmt.Errorf("%w, failed records: %s", err, failures)
+	}
+	return last, err
+}
@@ -0,0 +1,54 @@
+package chaos
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+const podChaosStatus = `{
+  "kind": "PodChaos",
+  "status": {
+    "conditions": [
+      {"type": "Selected", "status": "True"},
+      {"type": "AllInjected", "status": "False"},
+      {"type": "AllRecovered", "status": "False"},
+      {"type": "Paused", "status": "False"}
+    ],
+    "experiment": {
+      "desiredPhase": "Run",
+      "containerRecords": [
+        {
+          "id": "env/plugin-node-0/node",
+          "phase": "Injected",
+          "selectorKey": ".",
+          "injectedCount": 1,
+          "events": [{"type": "Succeeded", "operation": "Apply", "timestamp": "2022-06-01T10:00:00Z"}]
+        },
+        {
+          "id": "env/plugin-node-1/node",
+          "phase": "Not Injected",
+          "selectorKey": ".",
+          "events": [{"type": "Failed", "operation": "Apply", "message": "container not found", "timestamp": "2022-06-01T10:00:00Z"}]
+        }
+      ]
+    }
+  }
+}`
+
+func TestParseStatus(t *testing.T) {
+	t.Parallel()
+
+	s, err := parseStatus(&ExperimentInfo{Name: "podchaos-1", Resource: "podchaos"}, []byte(podChaosStatus))
+	require.NoError(t, err)
+	require.True(t, s.Selected())
+	require.False(t, s.AllInjected())
+	require.False(t, s.AllRecovered())
+	require.False(t, s.Paused())
+	require.Equal(t, "Run", s.DesiredPhase)
+	require.Equal(t, []string{"env/plugin-node-0", "env/plugin-node-1"}, s.SelectedPods())
+	failed := s.FailedRecords()
+	require.Len(t, failed, 1)
+	require.Equal(t, "env/plugin-node-1/node", failed[0].ID)
+	require.Equal(t, "env/plugin-node-1/node: Apply container not found", s.failures())
+}
@@ -0,0 +1,168 @@
+package chaos
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// EventRecovered every target was recovered from chaos
+	EventRecovered = "recovered"
+	// EventPaused experiment was paused
+	EventPaused = "paused"
+	// EventResumed experiment was resumed
+	EventResumed = "resumed"
+
+	// TimelineTimeFormat time format of the timeline report
+	TimelineTimeFormat = "2006-01-02T15:04:05.000Z07:00"
+)
+
+// TimelineEvent experiment event recorded by the controller, injection and recovery are recorded
+// the first time they're seen in experiment status, which is read after apply, before delete and while waiting
+type TimelineEvent struct {
+	Time       time.Time `json:"time"`
+	Type       string    `json:"type"`
+	Experiment string    `json:"experiment"`
+	Resource   string    `json:"resource,omitempty"`
+	// Pods namespace/pod names of affected targets
+	Pods  []string `json:"pods,omitempty"`
+	Error string   `json:"error,omitempty"`
+}
+
+// Timeline returns events recorded by the controller in order
+func (c *Controller) Timeline() []TimelineEvent {
+	c.tmu.Lock()
+	defer c.tmu.Unlock()
+	return append([]TimelineEvent(nil), c.timeline...)
+}
+
+// record appends event to the timeline
+func (c *Controller) record(eventType, name, resource string, pods []string, err error) {
+	c.tmu.Lock()
+	defer c.tmu.Unlock()
+	c.appendEvent(eventType, name, resource, pods, err)
+}
+
+// appendEvent appends event with tmu held, pods chaos was injected into are used if pods are not known
+func (c *Controller) appendEvent(eventType, name, resource string, pods []string, err error) {
+	if pods == nil {
+		pods = c.injected[name]
+	}
+	e := TimelineEvent{
+		Time:       time.Now(),
+		Type:       eventType,
+		Experiment: name,
+		Resource:   resource,
+		Pods:       pods,
+	}
+	if err != nil {
+		e.Type = EventFailed
+		e.Error = fmt.Sprintf("%s: %s", eventType, err)
+	}
+	c.timeline = append(c.timeline, e)
+}
+
+// statusForTimeline reads experiment status for the timeline, nil if it can't be read
+func (c *Controller) statusForTimeline(info *ExperimentInfo) *ExperimentStatus {
+	s, err := c.Backend.Status(info)
+	if err != nil {
+		log.Debug().Err(err).Str("Name", info.Name).Msg("Failed to read chaos experiment status for the timeline")
+		return nil
+	}
+	return s
+}
+
+// statusPods returns pods selected in status, nil if none are known
+func statusPods(s *ExperimentStatus) []string {
+	if s == nil || len(s.Records) == 0 {
+		return nil
+	}
+	return s.SelectedPods()
+}
+
+// observe records injection and recovery seen in experiment status for the first time, nil status is skipped
+func (c *Controller) observe(s *ExperimentStatus) {
+	if s == nil {
+		return
+	}
+	c.tmu.Lock()
+	defer c.tmu.Unlock()
+	c.initObserved()
+	_, injected := c.injected[s.Name]
+	switch {
+	case !injected && s.Selected() && s.AllInjected():
+		c.injected[s.Name] = s.SelectedPods()
+		c.appendEvent(EventInjected, s.Name, s.Resource, nil, nil)
+	case injected && !c.recovered[s.Name] && s.AllRecovered():
+		c.recovered[s.Name] = true
+		c.appendEvent(EventRecovered, s.Name, s.Resource, nil, nil)
+	}
+}
+
+// observeDeleted records recovery of injected experiment which was deleted, targets are recovered on deletion
+func (c *Controller) observeDeleted(info *ExperimentInfo) {
+	c.tmu.Lock()
+	defer c.tmu.Unlock()
+	c.initObserved()
+	if _, injected := c.injected[info.Name]; injected && !c.recovered[info.Name] {
+		c.recovered[info.Name] = true
+		c.appendEvent(EventRecovered, info.Name, info.Resource, nil, nil)
+	}
+}
+
+// initObserved initializes observed experiments with tmu held
+func (c *Controller) initObserved() {
+	if c.injected == nil {
+		c.injected = map[string][]string{}
+		c.recovered = map[string]bool{}
+	}
+}
+
+// WriteTimelineReport writes human-readable timeline with offsets from the first event,
+// followed by the time every experiment was active
+func WriteTimelineReport(w io.Writer, events []TimelineEvent) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "TIME\tOFFSET\tEVENT\tEXPERIMENT\tRESOURCE\tPODS\tERROR")
+	if len(events) == 0 {
+		return tw.Flush()
+	}
+	start := events[0].Time
+	type window struct {
+		name       string
+		start, end time.Time
+	}
+	var windows []*window
+	active := map[string]*window{}
+	for _, e := range events {
+		fmt.Fprintf(tw, "%s\t+%s\t%s\t%s\t%s\t%s\t%s\n",
+			e.Time.UTC().Format(TimelineTimeFormat), e.Time.Sub(start).Round(time.Millisecond),
+			e.Type, e.Experiment, e.Resource, strings.Join(e.Pods, ","), e.Error)
+		switch e.Type {
+		case EventApplied:
+			win := &window{name: e.Experiment, start: e.Time}
+			windows = append(windows, win)
+			active[e.Experiment] = win
+		case EventStopped:
+			if win, ok := active[e.Experiment]; ok {
+				win.end = e.Time
+				delete(active, e.Experiment)
+			}
+		}
+	}
+	fmt.Fprintln(tw)
+	fmt.Fprintln(tw, "EXPERIMENT\tAPPLIED\tSTOPPED\tDURATION")
+	for _, win := range windows {
+		stopped, duration := "-", "-"
+		if !win.end.IsZero() {
+			stopped = win.end.UTC().Format(TimelineTimeFormat)
+			duration = win.end.Sub(win.start).Round(time.Millisecond).String()
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", win.name, win.start.UTC().Format(TimelineTimeFormat), stopped, duration)
+	}
+	return tw.Flush()
+}
@@ -0,0 +1,124 @@
+package chaos
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/goplugin/helmenv/chaos/experiments"
+	"github.com/stretchr/testify/require"
+)
+
+func timelineTypes(events []TimelineEvent) []string {
+	types := make([]string, 0, len(events))
+	for _, e := range events {
+		types = append(types, e.Type)
+	}
+	return types
+}
+
+func TestTimeline(t *testing.T) {
+	t.Parallel()
+
+	c, _ := nativeController(t, &NativeBackend{},
+		nativePod("node-0", "plugin-node", nil),
+		nativePod("geth-0", "geth", nil),
+	)
+	name, err := c.Run(&experiments.PodKill{Mode: experiments.ModeAll, LabelKey: "app", LabelValue: "plugin-node"})
+	require.NoError(t, err)
+	_, err = c.WaitInjected(name, time.Second)
+	require.NoError(t, err)
+	// injection is recorded once
+	_, err = c.Status(name)
+	require.NoError(t, err)
+	require.True(t, errors.Is(c.Pause(name), ErrNotSupported))
+	require.NoError(t, c.Stop(name))
+
+	events := c.Timeline()
+	require.Equal(t, []string{EventApplied, EventInjected, EventFailed, EventStopped}, timelineTypes(events))
+	for _, e := range events {
+		require.Equal(t, name, e.Experiment)
+		require.Equal(t, "podchaos", e.Resource)
+	}
+	require.Equal(t, []string{"env/node-0"}, events[0].Pods)
+	require.Equal(t, []string{"env/node-0"}, events[1].Pods)
+	require.Equal(t, "paused: experiment "+name+": pause: not supported by chaos backend", events[2].Error)
+	require.Equal(t, []string{"env/node-0"}, events[3].Pods)
+	for i := 1; i < len(events); i++ {
+		require.False(t, events[i].Time.Before(events[i-1].Time))
+	}
+}
+
+// setStatus sets Chaos Mesh status of created experiment
+func (f *fakeCluster) setStatus(t *testing.T, resource, name, status string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var crd map[string]interface{}
+	require.NoError(t, json.Unmarshal(f.items[resource][name], &crd))
+	var st interface{}
+	require.NoError(t, json.Unmarshal([]byte(status), &st))
+	crd["status"] = st
+	data, err := json.Marshal(crd)
+	require.NoError(t, err)
+	f.items[resource][name] = data
+}
+
+func TestTimelineObserved(t *testing.T) {
+	t.Parallel()
+
+	cluster := newFakeCluster("podchaos")
+	c := cluster.controller(t, "run-1")
+	// injection and pods are recorded on stop, without reading status
+	stopped, err := c.Run(&experiments.PodKill{Mode: "one"})
+	require.NoError(t, err)
+	cluster.setStatus(t, "podchaos", stopped, `{"conditions":[
+		{"type":"Selected","status":"True"},{"type":"AllInjected","status":"True"}
+	],"experiment":{"containerRecords":[{"id":"test/node-0/node"},{"id":"test/node-1/node"}]}}`)
+	require.NoError(t, c.Stop(stopped))
+	// recovery of experiment deleted by someone else is recorded while waiting
+	deleted, err := c.Run(&experiments.PodKill{Mode: "one"})
+	require.NoError(t, err)
+	cluster.setStatus(t, "podchaos", deleted, `{"conditions":[
+		{"type":"Selected","status":"True"},{"type":"AllInjected","status":"True"}
+	],"experiment":{"containerRecords":[{"id":"test/node-1"}]}}`)
+	_, err = c.WaitInjected(deleted, time.Second)
+	require.NoError(t, err)
+	cluster.mu.Lock()
+	delete(cluster.items["podchaos"], deleted)
+	cluster.mu.Unlock()
+	_, err = c.WaitRecovered(deleted, time.Second)
+	require.NoError(t, err)
+
+	events := c.Timeline()
+	require.Equal(t, []string{
+		EventApplied, EventInjected, EventStopped,
+		EventApplied, EventInjected, EventRecovered,
+	}, timelineTypes(events))
+	require.Nil(t, events[0].Pods)
+	require.Equal(t, []string{"test/node-0", "test/node-1"}, events[1].Pods)
+	require.Equal(t, []string{"test/node-0", "test/node-1"}, events[2].Pods)
+	require.Equal(t, deleted, events[5].Experiment)
+	require.Equal(t, []string{"test/node-1"}, events[5].Pods)
+}
+
+func TestWriteTimelineReport(t *testing.T) {
+	t.Parallel()
+
+	start := time.Date(2022, 6, 1, 10, 0, 0, 0, time.UTC)
+	var buf bytes.Buffer
+	require.NoError(t, WriteTimelineReport(&buf, []TimelineEvent{
+		{Time: start, Type: EventApplied, Experiment: "podchaos-1", Resource: "podchaos"},
+		{Time: start.Add(1500 * time.Millisecond), Type: EventInjected, Experiment: "podchaos-1", Resource: "podchaos", Pods: []string{"env/node-0", "env/node-1"}},
+		{Time: start.Add(2 * time.Second), Type: EventApplied, Experiment: "networkchaos-1", Resource: "networkchaos"},
+		{Time: start.Add(time.Minute), Type: EventStopped, Experiment: "podchaos-1", Resource: "podchaos"},
+	}))
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	require.Len(t, lines, 9)
+	require.Equal(t, []string{"2022-06-01T10:00:01.500Z", "+1.5s", "injected", "podchaos-1", "podchaos", "env/node-0,env/node-1"},
+		strings.Fields(lines[2]))
+	require.Equal(t, []string{"podchaos-1", "2022-06-01T10:00:00.000Z", "2022-06-01T10:01:00.000Z", "1m0s"}, strings.Fields(lines[7]))
+	require.Equal(t, []string{"networkchaos-1", "2022-06-01T10:00:02.000Z", "-", "-"}, strings.Fields(lines[8]))
+}
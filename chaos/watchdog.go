@@ -0,0 +1,134 @@
+package chaos
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	k8sErrors "k8s.io/apimachinery/pkg/api/errors"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// DefaultWatchdogInterval interval expired experiments are looked up with by Watchdog
+const DefaultWatchdogInterval = 30 * time.Second
+
+// ErrMaxDuration experiment duration exceeds Config.MaxDuration
+var ErrMaxDuration = errors.New("experiment duration exceeds max duration")
+
+// created returns creation time of a listed experiment, nil if it's unknown
+func created(t metaV1.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	tt := t.Time
+	return &tt
+}
+
+// checkDuration rejects experiments running longer than MaxDuration, experiments without a duration
+// run until they're stopped and are left to the watchdog, durations of scheduled experiments and deadlines
+// of workflow steps are checked too
+func (c *Controller) checkDuration(payload *CRDPayload) error {
+	if c.Cfg.MaxDuration <= 0 {
+		return nil
+	}
+	var crd struct {
+		Spec map[string]interface{} `json:"spec"`
+	}
+	if err := json.Unmarshal(payload.Data, &crd); err != nil {
+		return err
+	}
+	for _, s := range specDurations(crd.Spec) {
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return fmt.Errorf("experiment %s: invalid duration %q: %w", payload.Name, s, err)
+		}
+		if d > c.Cfg.MaxDuration {
+			return fmt.Errorf("experiment %s: %w: %s > %s", payload.Name, ErrMaxDuration, d, c.Cfg.MaxDuration)
+		}
+	}
+	return nil
+}
+
+// specDurations returns durations and deadlines of the spec, including nested specs of scheduled experiments
+// and workflow templates, sorted
+func specDurations(spec interface{}) []string {
+	var durations []string
+	switch v := spec.(type) {
+	case map[string]interface{}:
+		for key, value := range v {
+			if s, ok := value.(string); ok && (key == "duration" || key == "deadline") {
+				if len(s) > 0 {
+					durations = append(durations, s)
+				}
+				continue
+			}
+			durations = append(durations, specDurations(value)...)
+		}
+	case []interface{}:
+		for _, item := range v {
+			durations = append(durations, specDurations(item)...)
+		}
+	}
+	sort.Strings(durations)
+	return durations
+}
+
+// StopExpired removes experiments created by helmenv in the namespace which are older than MaxDuration,
+// including ones started by another process, and returns removed experiments. Schedules are kept, they run
+// experiments on a cron and outlive any single run, which is limited by checkDuration
+func (c *Controller) StopExpired() ([]*ExperimentInfo, error) {
+	if c.Cfg.MaxDuration <= 0 {
+		return nil, nil
+	}
+	infos, err := c.List()
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	var stopped []*ExperimentInfo
+	for _, info := range infos {
+		if info.Resource == "schedules" || info.Created == nil || now.Sub(*info.Created) <= c.Cfg.MaxDuration {
+			continue
+		}
+		log.Warn().
+			Str("ID", info.Name).
+			Str("RunID", info.RunID).
+			Time("Created", *info.Created).
+			Dur("MaxDuration", c.Cfg.MaxDuration).
+			Msg("Chaos experiment exceeded max duration")
+		if err := c.delete(info); err != nil && !k8sErrors.IsNotFound(err) {
+			return stopped, err
+		}
+		stopped = append(stopped, info)
+	}
+	return stopped, nil
+}
+
+// Watchdog removes expired experiments every interval until ctx is done, see StopExpired
+func (c *Controller) Watchdog(ctx context.Context, interval time.Duration) error {
+	if c.Cfg.MaxDuration <= 0 {
+		return errors.New("chaos watchdog requires max duration")
+	}
+	if interval <= 0 {
+		interval = DefaultWatchdogInterval
+	}
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			if _, err := c.StopExpired(); err != nil {
+				log.Error().Err(err).Msg("Failed to stop expired chaos experiments")
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+	return nil
+}
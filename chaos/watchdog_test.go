@@ -0,0 +1,124 @@
+package chaos
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/goplugin/helmenv/chaos/experiments"
+	"github.com/stretchr/testify/require"
+)
+
+// age sets creation time of the experiment in the cluster
+func (f *fakeCluster) age(t *testing.T, resource, name string, age time.Duration) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var crd map[string]interface{}
+	require.NoError(t, json.Unmarshal(f.items[resource][name], &crd))
+	crd["metadata"].(map[string]interface{})["creationTimestamp"] = time.Now().Add(-age).UTC().Format(time.RFC3339)
+	data, err := json.Marshal(crd)
+	require.NoError(t, err)
+	f.items[resource][name] = data
+}
+
+func TestMaxDuration(t *testing.T) {
+	t.Parallel()
+
+	c, _ := nativeController(t, &NativeBackend{}, nativePod("node-0", "plugin-node", nil))
+	c.Cfg.MaxDuration = time.Minute
+	_, err := c.Run(&experiments.PodFailure{Mode: experiments.ModeAll, LabelKey: "app", LabelValue: "plugin-node", Duration: time.Hour})
+	require.True(t, errors.Is(err, ErrMaxDuration), "got %v", err)
+	require.Empty(t, c.Started())
+	require.Equal(t, []string{EventFailed}, timelineTypes(c.Timeline()))
+
+	name, err := c.Run(&experiments.PodFailure{Mode: experiments.ModeAll, LabelKey: "app", LabelValue: "plugin-node", Duration: 30 * time.Second})
+	require.NoError(t, err)
+	require.NoError(t, c.Stop(name))
+}
+
+func TestMaxDurationWorkflow(t *testing.T) {
+	t.Parallel()
+
+	c := newFakeCluster("workflows").controller(t, "run-1")
+	c.Cfg.MaxDuration = time.Minute
+	_, err := c.Run(&experiments.Workflow{Steps: []experiments.WorkflowStep{
+		experiments.Serial("entry", "kill", "pause"),
+		experiments.Chaos("kill", 30*time.Second, &experiments.PodKill{Mode: "one"}),
+		experiments.Suspend("pause", time.Hour),
+	}})
+	require.True(t, errors.Is(err, ErrMaxDuration), "got %v", err)
+	require.Contains(t, err.Error(), "1h0m0s > 1m0s")
+
+	_, err = c.Run(&experiments.Workflow{Steps: []experiments.WorkflowStep{
+		experiments.Serial("entry", "kill"),
+		experiments.Chaos("kill", 30*time.Second, &experiments.PodFailure{Mode: experiments.ModeOne, Duration: time.Hour}),
+	}})
+	require.True(t, errors.Is(err, ErrMaxDuration), "nested chaos duration must be checked, got %v", err)
+
+	_, err = c.Run(&experiments.Workflow{Steps: []experiments.WorkflowStep{
+		experiments.Serial("entry", "kill"),
+		experiments.Chaos("kill", 30*time.Second, &experiments.PodKill{Mode: "one"}),
+	}})
+	require.NoError(t, err)
+}
+
+func TestStopExpired(t *testing.T) {
+	t.Parallel()
+
+	cluster := newFakeCluster("podchaos", "networkchaos", "schedules", "workflows")
+	other := cluster.controller(t, "run-1")
+	old, err := other.Run(&experiments.PodKill{Mode: "one"})
+	require.NoError(t, err)
+	fresh, err := other.Run(&experiments.NetworkPartition{FromMode: "one", ToMode: "all", ToLabelKey: "app", ToLabelValue: "geth"})
+	require.NoError(t, err)
+	cluster.age(t, "podchaos", old, time.Hour)
+	cluster.age(t, "networkchaos", fresh, time.Second)
+	schedule, err := other.Run(&experiments.Schedule{Cron: "@every 1m", Experiment: &experiments.PodKill{Mode: "one"}})
+	require.NoError(t, err)
+	cluster.age(t, "schedules", schedule, time.Hour)
+	workflow, err := other.Run(&experiments.Workflow{Steps: []experiments.WorkflowStep{
+		experiments.Chaos("kill", 30*time.Second, &experiments.PodKill{Mode: "one"}),
+	}})
+	require.NoError(t, err)
+	cluster.age(t, "workflows", workflow, time.Hour)
+
+	watchdog := cluster.controller(t, "run-2")
+	stopped, err := watchdog.StopExpired()
+	require.NoError(t, err)
+	require.Empty(t, stopped, "nothing expires without max duration")
+
+	watchdog.Cfg.MaxDuration = time.Minute
+	stopped, err = watchdog.StopExpired()
+	require.NoError(t, err)
+	require.Len(t, stopped, 2)
+	require.Equal(t, "run-1", stopped[0].RunID)
+	require.ElementsMatch(t, []string{old, workflow}, cluster.deleted, "schedules outlive max duration of their runs")
+
+	// the owner stops experiments deleted by the watchdog without errors
+	require.NoError(t, other.StopStarted())
+	require.Empty(t, other.Started())
+	require.ElementsMatch(t, []string{old, workflow, fresh, schedule}, cluster.deleted)
+}
+
+func TestWatchdog(t *testing.T) {
+	t.Parallel()
+
+	cluster := newFakeCluster("podchaos")
+	c := cluster.controller(t, "run-1")
+	require.Error(t, c.Watchdog(context.Background(), time.Millisecond))
+
+	name, err := c.Run(&experiments.PodKill{Mode: "one"})
+	require.NoError(t, err)
+	cluster.age(t, "podchaos", name, time.Hour)
+	c.Cfg.MaxDuration = time.Minute
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	require.NoError(t, c.Watchdog(ctx, time.Millisecond))
+	require.Eventually(t, func() bool {
+		cluster.mu.Lock()
+		defer cluster.mu.Unlock()
+		return len(cluster.deleted) == 1
+	}, time.Second, time.Millisecond)
+}
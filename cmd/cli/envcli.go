@@ -1,18 +1,83 @@
 package main
 
 import (
+	"bytes"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"os"
 	"os/signal"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
 
+	"github.com/ghodss/yaml"
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
+	"github.com/goplugin/helmenv/chaos"
+	"github.com/goplugin/helmenv/chaos/experiments"
 	"github.com/goplugin/helmenv/environment"
 	"github.com/urfave/cli/v2"
+	"k8s.io/client-go/util/exec"
 )
 
 func init() {
-	log.Logger = log.Output(zerolog.ConsoleWriter{Out: os.Stderr}).Level(zerolog.InfoLevel)
+	// commands redact logs with settings of their files, see redactLogs
+	environment.RedactGlobalLogs = false
+	redactor, err := environment.NewRedactor(nil, nil, redactionDisabled())
+	if err != nil {
+		panic(err)
+	}
+	setLogRedactor(redactor)
+}
+
+func redactionDisabled() bool {
+	disabled, _ := strconv.ParseBool(os.Getenv("DISABLE_REDACTION"))
+	return disabled
+}
+
+// setLogRedactor redacts formatted log lines, so redacted fields can't break events
+func setLogRedactor(redactor *environment.Redactor) {
+	log.Logger = log.Output(zerolog.ConsoleWriter{Out: redactor.Writer(os.Stderr)}).Level(zerolog.InfoLevel)
+}
+
+// redactLogs redacts logs with redact settings of the environment or preset file of the command,
+// unreadable files are reported by the command itself
+func redactLogs(c *cli.Context) error {
+	path := c.String("environment")
+	if len(path) == 0 {
+		path = c.String("preset")
+	}
+	if len(path) == 0 {
+		return nil
+	}
+	// the command logs reading the file itself
+	logger := log.Logger
+	log.Logger = zerolog.Nop()
+	config, err := environment.ReadConfigFile(path)
+	log.Logger = logger
+	if err != nil {
+		return nil
+	}
+	config.DisableRedaction = config.DisableRedaction || redactionDisabled()
+	redactor, err := config.Redactor()
+	if err != nil {
+		return err
+	}
+	setLogRedactor(redactor)
+	return nil
+}
+
+// redactCommandLogs sets redactLogs as Before of every command
+func redactCommandLogs(commands []*cli.Command) {
+	for _, cmd := range commands {
+		if cmd.Before == nil {
+			cmd.Before = redactLogs
+		}
+		redactCommandLogs(cmd.Subcommands)
+	}
 }
 
 var presetFlag = &cli.StringFlag{
@@ -95,6 +160,243 @@ func main() {
 					return nil
 				},
 			},
+			{
+				Name:    "status",
+				Aliases: []string{"st"},
+				Usage:   "shows Helm releases, pods and connections of the environment",
+				Flags: []cli.Flag{
+					environmentFlag,
+					&cli.StringFlag{
+						Name:    "output",
+						Aliases: []string{"o"},
+						Usage:   "output format, table, json or yaml",
+						Value:   "table",
+					},
+					&cli.BoolFlag{
+						Name:    "watch",
+						Aliases: []string{"w"},
+						Usage:   "refreshes status until interrupted",
+					},
+					&cli.DurationFlag{
+						Name:  "interval",
+						Usage: "refresh interval of --watch",
+						Value: 2 * time.Second,
+					},
+				},
+				Action: func(c *cli.Context) error {
+					e, err := environment.LoadEnvironmentFromConfigFile(c.String("environment"))
+					if err != nil {
+						return err
+					}
+					output := c.String("output")
+					if !c.Bool("watch") {
+						s, err := e.Status()
+						if err != nil {
+							return err
+						}
+						return printStatus(s, output, false)
+					}
+					ctx, stop := signal.NotifyContext(c.Context, os.Interrupt)
+					defer stop()
+					ticker := time.NewTicker(c.Duration("interval"))
+					defer ticker.Stop()
+					for {
+						s, err := e.Status()
+						if err != nil {
+							return err
+						}
+						if err := printStatus(s, output, true); err != nil {
+							return err
+						}
+						select {
+						case <-ctx.Done():
+							return nil
+						case <-ticker.C:
+						}
+					}
+				},
+			},
+			{
+				Name:  "upgrade",
+				Usage: "upgrades chart with values merged into the chart values of the environment",
+				Flags: []cli.Flag{
+					environmentFlag,
+					&cli.StringFlag{
+						Name:     "chart",
+						Usage:    "chart to upgrade",
+						Required: true,
+					},
+					&cli.StringSliceFlag{
+						Name:  "set",
+						Usage: "values as key=value, same as helm --set",
+					},
+					&cli.StringSliceFlag{
+						Name:    "values",
+						Aliases: []string{"f"},
+						Usage:   "values file, same as helm -f",
+					},
+					&cli.BoolFlag{
+						Name:  "dry_run",
+						Usage: "only shows the values diff",
+					},
+				},
+				Action: func(c *cli.Context) error {
+					overrides, err := environment.ReadValueOverrides(c.StringSlice("values"), c.StringSlice("set"))
+					if err != nil {
+						return err
+					}
+					e, err := environment.LoadEnvironmentFromConfigFile(c.String("environment"))
+					if err != nil {
+						return err
+					}
+					chart := c.String("chart")
+					changes, err := e.ChartValuesDiff(chart, overrides)
+					if err != nil {
+						return err
+					}
+					if len(changes) == 0 {
+						log.Info().Str("Chart", chart).Msg("Values are unchanged, nothing to upgrade")
+						return nil
+					}
+					printValuesDiff(changes)
+					if c.Bool("dry_run") {
+						return nil
+					}
+					if err := e.UpgradeValues(chart, overrides); err != nil {
+						return err
+					}
+					log.Info().Str("Chart", chart).Str("Environment", e.Path).Msg("Chart upgraded")
+					return nil
+				},
+			},
+			{
+				Name:      "exec",
+				Usage:     "runs command in a pod addressed as chart/app/instance",
+				ArgsUsage: "chart/app/instance -- command [args...]",
+				Flags: []cli.Flag{
+					environmentFlag,
+					&cli.StringFlag{
+						Name:    "container",
+						Aliases: []string{"c"},
+						Usage:   "container name, the default container of the pod by default",
+					},
+					&cli.BoolFlag{
+						Name:    "stdin",
+						Aliases: []string{"i"},
+						Usage:   "passes stdin to the command",
+					},
+				},
+				Action: func(c *cli.Context) error {
+					args, err := interspersedArgs(c)
+					if err != nil {
+						return err
+					}
+					if len(args) < 2 {
+						return errors.New("target and command are required")
+					}
+					target, err := environment.ParsePodTarget(args[0])
+					if err != nil {
+						return err
+					}
+					e, err := environment.LoadEnvironmentFromConfigFile(c.String("environment"))
+					if err != nil {
+						return err
+					}
+					var stdin io.Reader
+					if c.Bool("stdin") {
+						stdin = os.Stdin
+					}
+					err = e.ExecTarget(target, c.String("container"), args[1:], stdin, os.Stdout, os.Stderr)
+					var exitErr exec.ExitError
+					if errors.As(err, &exitErr) {
+						return cli.Exit("", exitErr.ExitStatus())
+					}
+					return err
+				},
+			},
+			{
+				Name:      "logs",
+				Usage:     "prints logs of pods addressed as chart/app/instance, app and instance are optional",
+				ArgsUsage: "chart[/app[/instance]]",
+				Flags: []cli.Flag{
+					environmentFlag,
+					&cli.StringFlag{
+						Name:    "container",
+						Aliases: []string{"c"},
+						Usage:   "container name, every container by default",
+					},
+					&cli.BoolFlag{
+						Name:    "follow",
+						Aliases: []string{"f"},
+						Usage:   "follows logs until interrupted",
+					},
+					&cli.DurationFlag{
+						Name:  "since",
+						Usage: "only prints logs newer than that, for example 10m",
+					},
+					&cli.Int64Flag{
+						Name:  "tail",
+						Usage: "amount of the last lines to print",
+					},
+					&cli.BoolFlag{
+						Name:    "previous",
+						Aliases: []string{"p"},
+						Usage:   "prints logs of the previous container run",
+					},
+				},
+				Action: func(c *cli.Context) error {
+					args, err := interspersedArgs(c)
+					if err != nil {
+						return err
+					}
+					if len(args) != 1 {
+						return errors.New("target is required")
+					}
+					target, err := environment.ParsePodTarget(args[0])
+					if err != nil {
+						return err
+					}
+					e, err := environment.LoadEnvironmentFromConfigFile(c.String("environment"))
+					if err != nil {
+						return err
+					}
+					ctx, stop := signal.NotifyContext(c.Context, os.Interrupt)
+					defer stop()
+					return e.TargetLogs(ctx, target, c.String("container"), environment.LogOptions{
+						Follow:   c.Bool("follow"),
+						Since:    c.Duration("since"),
+						Tail:     c.Int64("tail"),
+						Previous: c.Bool("previous"),
+					}, os.Stdout)
+				},
+			},
+			{
+				Name:      "cp",
+				Usage:     "copies files to or from a pod addressed as chart/app/instance",
+				ArgsUsage: "src dst, either of them is chart/app/instance:path",
+				Flags: []cli.Flag{
+					environmentFlag,
+					&cli.StringFlag{
+						Name:    "container",
+						Aliases: []string{"c"},
+						Usage:   "container name, the default container of the pod by default",
+					},
+				},
+				Action: func(c *cli.Context) error {
+					args, err := interspersedArgs(c)
+					if err != nil {
+						return err
+					}
+					if len(args) != 2 {
+						return errors.New("source and destination are required")
+					}
+					e, err := environment.LoadEnvironmentFromConfigFile(c.String("environment"))
+					if err != nil {
+						return err
+					}
+					return e.CopyTarget(args[0], args[1], c.String("container"))
+				},
+			},
 			{
 				Name:    "remove",
 				Aliases: []string{"rm"},
@@ -126,7 +428,7 @@ func main() {
 					&cli.StringFlag{
 						Name:     "artifacts",
 						Aliases:  []string{"a"},
-						Usage:    "artifacts dir to store logs",
+						Usage:    "artifacts dir to store logs, or a .tar.gz/.zip bundle file",
 						Required: true,
 					},
 					&cli.StringFlag{
@@ -135,6 +437,22 @@ func main() {
 						Usage:    "database name to dump",
 						Required: true,
 					},
+					&cli.StringSliceFlag{
+						Name:     "sink",
+						Aliases:  []string{"s"},
+						Usage:    "artifacts upload URL, file:///dir, s3://bucket?endpoint=URL&region=REGION or http(s)://URL",
+						Required: false,
+					},
+					&cli.StringFlag{
+						Name:     "sink_prefix",
+						Usage:    "key prefix for uploaded artifacts, defaults to the namespace",
+						Required: false,
+					},
+					&cli.StringSliceFlag{
+						Name:     "profile_chart",
+						Usage:    "chart to collect metrics and CPU, heap and goroutine profiles from",
+						Required: false,
+					},
 				},
 				Usage: "dump all the logs from the environment",
 				Action: func(c *cli.Context) error {
@@ -145,12 +463,72 @@ func main() {
 					if err != nil {
 						return err
 					}
+					for _, sinkURL := range c.StringSlice("sink") {
+						sink, err := environment.NewArtifactSink(sinkURL)
+						if err != nil {
+							return err
+						}
+						e.Artifacts.Sinks = append(e.Artifacts.Sinks, sink)
+					}
+					if prefix := c.String("sink_prefix"); len(prefix) > 0 {
+						e.Artifacts.SinkKeyPrefix = prefix
+					}
+					for _, chart := range c.StringSlice("profile_chart") {
+						e.Artifacts.Collectors = append(e.Artifacts.Collectors, environment.NewProfileCollector(chart))
+					}
 					if err := e.Artifacts.DumpTestResult(artifactsDir, dbName); err != nil {
 						return err
 					}
 					return nil
 				},
 			},
+			{
+				Name:    "artifacts",
+				Aliases: []string{"a"},
+				Usage:   "works with dumped artifacts bundles",
+				Subcommands: []*cli.Command{
+					{
+						Name:    "inspect",
+						Aliases: []string{"i"},
+						Usage:   "lists bundle manifest and files, optionally extracts them",
+						Flags: []cli.Flag{
+							&cli.StringFlag{
+								Name:     "bundle",
+								Aliases:  []string{"b"},
+								Usage:    "artifacts bundle file, .tar.gz or .zip",
+								Required: true,
+							},
+							&cli.StringFlag{
+								Name:     "extract",
+								Aliases:  []string{"x"},
+								Usage:    "dir to extract bundle files to, checksums are verified",
+								Required: false,
+							},
+						},
+						Action: func(c *cli.Context) error {
+							bundlePath := c.String("bundle")
+							extractDir := c.String("extract")
+							var (
+								manifest *environment.ArtifactsManifest
+								err      error
+							)
+							if len(extractDir) > 0 {
+								manifest, err = environment.ExtractArtifactsBundle(bundlePath, extractDir)
+							} else {
+								manifest, err = environment.ReadArtifactsManifest(bundlePath)
+							}
+							if err != nil {
+								return err
+							}
+							printManifest(manifest)
+							if len(extractDir) > 0 {
+								log.Info().Str("Dir", extractDir).Msg("Bundle extracted")
+							}
+							return nil
+						},
+					},
+				},
+			},
 			{
 				Name:    "chaos",
 				Aliases: []string{"ch"},
@@ -167,6 +545,18 @@ func main() {
 								Usage:    "chaos template to be applied",
 								Required: true,
 							},
+							&cli.StringFlag{
+								Name:  "chart",
+								Usage: "chart to target, fills in the template selector",
+							},
+							&cli.StringFlag{
+								Name:  "app",
+								Usage: "app of the chart to target, every app by default",
+							},
+							&cli.IntSliceFlag{
+								Name:  "instance",
+								Usage: "app instances to target, every instance by default",
+							},
 						},
 						Action: func(c *cli.Context) error {
 							environmentPath := c.String("environment")
@@ -175,12 +565,100 @@ func main() {
 							if err != nil {
 								return err
 							}
-							if err = e.ApplyChaosExperimentFromTemplate(chaosTemplate); err != nil {
+							var target *experiments.Selector
+							if chart := c.String("chart"); len(chart) > 0 {
+								if target, err = e.ChaosTarget(chart, c.String("app"), c.IntSlice("instance")...); err != nil {
+									return err
+								}
+							} else if c.IsSet("app") || c.IsSet("instance") {
+								return errors.New("--app and --instance require --chart")
+							}
+							if err = e.ApplyChaosExperimentFromTemplateOn(chaosTemplate, target); err != nil {
 								return err
 							}
 							return nil
 						},
 					},
+					{
+						Name:  "render",
+						Usage: "prints chaos template as it would be applied, without contacting the cluster",
+						Flags: []cli.Flag{
+							environmentFlag,
+							&cli.StringFlag{
+								Name:     "template",
+								Aliases:  []string{"t"},
+								Usage:    "chaos template to be rendered",
+								Required: true,
+							},
+							&cli.StringFlag{
+								Name:  "chart",
+								Usage: "chart to target, fills in the template selector",
+							},
+							&cli.StringFlag{
+								Name:  "app",
+								Usage: "app of the chart to target, every app by default",
+							},
+							&cli.IntSliceFlag{
+								Name:  "instance",
+								Usage: "app instances to target, every instance by default",
+							},
+							&cli.StringFlag{
+								Name:    "output",
+								Aliases: []string{"o"},
+								Usage:   "output format, yaml or json",
+								Value:   "yaml",
+							},
+						},
+						Action: func(c *cli.Context) error {
+							config, err := environment.ReadConfigFile(c.String("environment"))
+							if err != nil {
+								return err
+							}
+							if len(c.String("chart")) == 0 && (c.IsSet("app") || c.IsSet("instance")) {
+								return errors.New("--app and --instance require --chart")
+							}
+							data, err := environment.RenderChaosTemplate(config, c.String("template"), c.String("chart"), c.String("app"), c.IntSlice("instance")...)
+							if err != nil {
+								return err
+							}
+							if data, err = formatJSON(data, c.String("output")); err != nil {
+								return err
+							}
+							_, err = os.Stdout.Write(data)
+							return err
+						},
+					},
+					{
+						Name:  "run",
+						Usage: "runs chaos scenario, experiments are stopped when it ends or is interrupted",
+						Flags: []cli.Flag{
+							environmentFlag,
+							&cli.StringFlag{
+								Name:     "scenario",
+								Aliases:  []string{"s"},
+								Usage:    "chaos scenario file",
+								Required: true,
+							},
+						},
+						Action: func(c *cli.Context) error {
+							environmentPath := c.String("environment")
+							e, err := environment.DeployOrLoadEnvironmentFromConfigFile(environmentPath)
+							if err != nil {
+								return err
+							}
+							scenario, err := chaos.LoadScenario(c.String("scenario"))
+							if err != nil {
+								return err
+							}
+							ctx, stop := signal.NotifyContext(c.Context, os.Interrupt)
+							defer stop()
+							res, err := e.RunChaosScenario(ctx, scenario)
+							if res != nil {
+								printTimeline(res)
+							}
+							return err
+						},
+					},
 					{
 						Name:    "stop",
 						Aliases: []string{"s"},
@@ -201,9 +679,9 @@ func main() {
 							if err != nil {
 								return err
 							}
-							expInfo, ok := e.Config.Experiments[chaosID]
-							if !ok {
-								return fmt.Errorf("experiment with id %s not found", expInfo.Name)
+							expInfo, err := e.FindChaosExperiment(chaosID)
+							if err != nil {
+								return err
 							}
 							if err = e.StopChaosStandaloneExperiment(expInfo); err != nil {
 								return err
@@ -211,6 +689,76 @@ func main() {
 							return nil
 						},
 					},
+					{
+						Name:  "pause",
+						Usage: "pauses chaos experiment, injected chaos is recovered until it's resumed",
+						Flags: []cli.Flag{
+							environmentFlag,
+							&cli.StringFlag{
+								Name:     "chaos_id",
+								Aliases:  []string{"c"},
+								Usage:    "chaos experiment name",
+								Required: true,
+							},
+						},
+						Action: func(c *cli.Context) error {
+							environmentPath := c.String("environment")
+							e, err := environment.DeployOrLoadEnvironmentFromConfigFile(environmentPath)
+							if err != nil {
+								return err
+							}
+							return e.PauseChaosExperiment(c.String("chaos_id"))
+						},
+					},
+					{
+						Name:  "resume",
+						Usage: "resumes paused chaos experiment",
+						Flags: []cli.Flag{
+							environmentFlag,
+							&cli.StringFlag{
+								Name:     "chaos_id",
+								Aliases:  []string{"c"},
+								Usage:    "chaos experiment name",
+								Required: true,
+							},
+						},
+						Action: func(c *cli.Context) error {
+							environmentPath := c.String("environment")
+							e, err := environment.DeployOrLoadEnvironmentFromConfigFile(environmentPath)
+							if err != nil {
+								return err
+							}
+							return e.ResumeChaosExperiment(c.String("chaos_id"))
+						},
+					},
+					{
+						Name:    "list",
+						Aliases: []string{"l"},
+						Usage:   "lists chaos experiments created by helmenv in the environment namespace",
+						Flags:   []cli.Flag{environmentFlag},
+						Action: func(c *cli.Context) error {
+							environmentPath := c.String("environment")
+							e, err := environment.DeployOrLoadEnvironmentFromConfigFile(environmentPath)
+							if err != nil {
+								return err
+							}
+							infos, err := e.ListChaosExperiments()
+							if err != nil {
+								return err
+							}
+							w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+							defer w.Flush()
+							fmt.Fprintln(w, "NAME\tRESOURCE\tRUN ID\tAGE")
+							for _, info := range infos {
+								age := "-"
+								if info.Created != nil {
+									age = time.Since(*info.Created).Round(time.Second).String()
+								}
+								fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", info.Name, info.Resource, info.RunID, age)
+							}
+							return nil
+						},
+					},
 					{
 						Name:    "clear",
 						Aliases: []string{"c"},
@@ -228,12 +776,215 @@ func main() {
 							return nil
 						},
 					},
+					{
+						Name:  "watchdog",
+						Usage: "deletes chaos experiments older than max duration, including ones of other processes, until interrupted",
+						Flags: []cli.Flag{
+							environmentFlag,
+							&cli.DurationFlag{
+								Name:  "max_duration",
+								Usage: "max experiment age, chaos_max_duration of the environment by default",
+							},
+							&cli.DurationFlag{
+								Name:  "interval",
+								Usage: "interval between checks",
+								Value: chaos.DefaultWatchdogInterval,
+							},
+						},
+						Action: func(c *cli.Context) error {
+							environmentPath := c.String("environment")
+							e, err := environment.DeployOrLoadEnvironmentFromConfigFile(environmentPath)
+							if err != nil {
+								return err
+							}
+							ctx, stop := signal.NotifyContext(c.Context, os.Interrupt)
+							defer stop()
+							return e.RunChaosWatchdog(ctx, c.Duration("max_duration"), c.Duration("interval"))
+						},
+					},
+					{
+						Name:  "preflight",
+						Usage: "checks chaos kinds and Chaos Mesh version installed in the cluster, installs Chaos Mesh if configured",
+						Flags: []cli.Flag{environmentFlag},
+						Action: func(c *cli.Context) error {
+							environmentPath := c.String("environment")
+							e, err := environment.DeployOrLoadEnvironmentFromConfigFile(environmentPath)
+							if err != nil {
+								return err
+							}
+							s, err := e.ChaosPreflight()
+							if err != nil {
+								return err
+							}
+							w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+							defer w.Flush()
+							fmt.Fprintf(w, "Version:\t%s\n", s.Version)
+							fmt.Fprintf(w, "Namespace:\t%s\n", s.Namespace)
+							fmt.Fprintf(w, "Kinds:\t%s\n", strings.Join(s.KindNames(), ", "))
+							return nil
+						},
+					},
 				},
 			},
 		},
 	}
+	redactCommandLogs(app.Commands)
 	err := app.Run(os.Args)
 	if err != nil {
 		log.Error().Err(err).Send()
 	}
 }
+
+func printTimeline(res *chaos.ScenarioResult) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	defer w.Flush()
+	fmt.Fprintln(w, "OFFSET\tEVENT\tEXPERIMENT\tRUN\tNAME\tCHECK\tERROR")
+	for _, e := range res.Timeline {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%s\t%s\t%s\n",
+			e.Offset.Round(time.Millisecond), e.Type, e.Experiment, e.Run, e.Name, e.Check, e.Error)
+	}
+}
+
+// interspersedArgs returns positional arguments, applying flags that follow them, arguments after -- are
+// returned as they are
+func interspersedArgs(c *cli.Context) ([]string, error) {
+	var positional []string
+	args := c.Args().Slice()
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if arg == "--" {
+			return append(positional, args[i+1:]...), nil
+		}
+		if len(arg) < 2 || arg[0] != '-' {
+			positional = append(positional, arg)
+			continue
+		}
+		name := strings.TrimLeft(arg, "-")
+		value, hasValue := "", false
+		if j := strings.IndexByte(name, '='); j >= 0 {
+			name, value, hasValue = name[:j], name[j+1:], true
+		}
+		flag := commandFlag(c.Command, name)
+		if flag == nil {
+			return nil, fmt.Errorf("flag provided but not defined: %s", arg)
+		}
+		if _, ok := flag.(*cli.BoolFlag); ok && !hasValue {
+			value, hasValue = "true", true
+		}
+		if !hasValue {
+			if i+1 >= len(args) {
+				return nil, fmt.Errorf("flag needs an argument: %s", arg)
+			}
+			i++
+			value = args[i]
+		}
+		// aliases are separate flags once parsed
+		for _, n := range flag.Names() {
+			if err := c.Set(n, value); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return positional, nil
+}
+
+func commandFlag(cmd *cli.Command, name string) cli.Flag {
+	for _, f := range cmd.Flags {
+		for _, n := range f.Names() {
+			if n == name {
+				return f
+			}
+		}
+	}
+	return nil
+}
+
+func printValuesDiff(changes []environment.ValueChange) {
+	format := func(v interface{}) string {
+		if _, ok := v.(string); ok {
+			return fmt.Sprint(v)
+		}
+		b, err := json.Marshal(v)
+		if err != nil {
+			return fmt.Sprint(v)
+		}
+		return string(b)
+	}
+	for _, c := range changes {
+		switch {
+		case c.Old == nil:
+			fmt.Printf("+ %s: %s\n", c.Key, format(c.New))
+		case c.New == nil:
+			fmt.Printf("- %s: %s\n", c.Key, format(c.Old))
+		default:
+			fmt.Printf("~ %s: %s -> %s\n", c.Key, format(c.Old), format(c.New))
+		}
+	}
+}
+
+// formatJSON formats JSON as indented JSON or as YAML
+func formatJSON(data []byte, format string) ([]byte, error) {
+	switch format {
+	case "yaml":
+		return yaml.JSONToYAML(data)
+	case "json":
+		var out bytes.Buffer
+		if err := json.Indent(&out, data, "", "  "); err != nil {
+			return nil, err
+		}
+		out.WriteByte('\n')
+		return out.Bytes(), nil
+	default:
+		return nil, fmt.Errorf("unknown output format %s", format)
+	}
+}
+
+// printStatus prints environment status, watched tables are redrawn, watched JSON is printed a line per refresh
+// and watched YAML as a stream of documents
+func printStatus(s *environment.Status, format string, watch bool) error {
+	if format == "table" {
+		if watch {
+			fmt.Print("\033[H\033[2J")
+			fmt.Printf("Updated at %s\n\n", s.Time.Format(time.RFC3339))
+		}
+		return s.WriteTable(os.Stdout)
+	}
+	data, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+	switch {
+	case watch && format == "json":
+		data = append(data, '\n')
+	case watch && format == "yaml":
+		fmt.Println("---")
+		fallthrough
+	default:
+		if data, err = formatJSON(data, format); err != nil {
+			return err
+		}
+	}
+	_, err = os.Stdout.Write(data)
+	return err
+}
+
+func printManifest(m *environment.ArtifactsManifest) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	defer w.Flush()
+	fmt.Fprintf(w, "Namespace:\t%s\n", m.Namespace)
+	fmt.Fprintf(w, "Preset:\t%s\n", m.Preset)
+	fmt.Fprintf(w, "Namespace prefix:\t%s\n", m.NamespacePrefix)
+	fmt.Fprintf(w, "Collected:\t%s - %s\n", m.StartedAt.Format(time.RFC3339), m.FinishedAt.Format(time.RFC3339))
+	fmt.Fprintln(w, "\nCHART\tNAME\tVERSION\tAPP VERSION\tREVISION")
+	for name, chart := range m.Charts {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%d\n", name, chart.Chart, chart.Version, chart.AppVersion, chart.Revision)
+	}
+	fmt.Fprintln(w, "\nPOD\tCONTAINER\tIMAGE\tDIGEST")
+	for _, image := range m.Images {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", image.Pod, image.Container, image.Image, image.ImageID)
+	}
+	fmt.Fprintln(w, "\nFILE\tSIZE\tSHA256")
+	for _, file := range m.Files {
+		fmt.Fprintf(w, "%s\t%d\t%s\n", file.Path, file.Size, file.SHA256)
+	}
+}
@@ -8,6 +8,7 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"sync"
 
 	"github.com/pkg/errors"
 	"github.com/rs/zerolog/log"
@@ -20,29 +21,84 @@ import (
 
 // Artifacts is an artifacts dumping structure that copies logs and database dumps for all deployed pods
 type Artifacts struct {
-	env        *Environment
-	DBName     string
+	env    *Environment
+	DBName string
+	// LogRotationSize size in bytes after which a streamed log file is rotated, see StartStreaming
+	LogRotationSize int64
+	// LogRotationFiles amount of rotated files kept for every streamed container, see StartStreaming
+	LogRotationFiles int
+	// Sinks every dumped artifact is uploaded to, see Config.ArtifactSinks
+	Sinks []ArtifactSink
+	// SinkKeyPrefix prefix for uploaded artifact keys, defaults to the namespace so every run has its own prefix
+	SinkKeyPrefix string
+	// Collectors collect additional artifacts like metrics and profiles on every dump, see ProfileCollector
+	Collectors []ArtifactCollector
+
 	podsClient clientV1.PodInterface
+	streamMu   sync.Mutex
+	streamer   *logStreamer
 }
 
 // NewArtifacts create new artifacts instance for provided environment
 func NewArtifacts(env *Environment) (*Artifacts, error) {
 	podsClient := env.k8sClient.CoreV1().Pods(env.Config.Namespace)
+	sinks := make([]ArtifactSink, 0)
+	for _, sinkURL := range env.Config.ArtifactSinks {
+		sink, err := NewArtifactSink(sinkURL)
+		if err != nil {
+			return nil, err
+		}
+		sinks = append(sinks, sink)
+	}
 	return &Artifacts{
-		env:        env,
-		podsClient: podsClient,
+		env:              env,
+		LogRotationSize:  DefaultLogRotationSize,
+		LogRotationFiles: DefaultLogRotationFiles,
+		Sinks:            sinks,
+		SinkKeyPrefix:    env.Config.ArtifactSinkPrefix,
+		podsClient:       podsClient,
 	}, nil
 }
 
-// DumpTestResult dumps all pods logs and db dump in a separate test dir
+// DumpTestResult dumps all pods logs, db dump and chaos timeline in a separate test dir,
+// if testDir ends with .tar.gz, .tgz or .zip a single bundle file with a manifest is written instead
 func (a *Artifacts) DumpTestResult(testDir string, dbName string) error {
 	a.DBName = dbName
+	if BundleFormat(testDir) != "" {
+		if err := a.dumpBundle(testDir); err != nil {
+			return err
+		}
+		return a.Upload(testDir)
+	}
 	if err := mkdirIfNotExists(testDir); err != nil {
 		return err
 	}
 	if err := a.writePodArtifacts(testDir); err != nil {
 		return err
 	}
+	if err := a.writeChaosTimeline(testDir); err != nil {
+		return err
+	}
+	a.runCollectors(context.Background(), testDir)
+	return a.Upload(testDir)
+}
+
+// Upload uploads a dumped artifacts dir or bundle to every configured sink
+func (a *Artifacts) Upload(src string) error {
+	prefix := a.SinkKeyPrefix
+	if len(prefix) == 0 {
+		prefix = a.env.Config.Namespace
+	}
+	for _, sink := range a.Sinks {
+		log.Info().
+			Str("Source", src).
+			Str("Sink", sink.String()).
+			Str("Prefix", prefix).
+			Msg("Uploading artifacts")
+		if err := UploadArtifacts(context.Background(), sink, prefix, src); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
@@ -142,10 +198,13 @@ func (a *Artifacts) writeContainerLogs(podDir string, pod coreV1.Pod, cont coreV
 	if err != nil {
 		return err
 	}
-	_, err = logFile.Write(buf.Bytes())
+	redactor, err := a.env.Config.Redactor()
 	if err != nil {
 		return err
 	}
+	if err = redactLines(redactor, logFile, buf.Bytes()); err != nil {
+		return err
+	}
 
 	if err = logFile.Close(); err != nil {
 		return err
@@ -0,0 +1,432 @@
+package environment
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// ManifestFileName name of the manifest file inside an artifacts bundle
+	ManifestFileName = "manifest.json"
+	// BundleFormatTarGz gzipped tarball artifacts bundle
+	BundleFormatTarGz = "tar.gz"
+	// BundleFormatZip zip artifacts bundle
+	BundleFormatZip = "zip"
+)
+
+// ArtifactsManifest describes the environment and every file collected into an artifacts bundle
+type ArtifactsManifest struct {
+	Namespace       string                    `json:"namespace"`
+	Preset          string                    `json:"preset,omitempty"`
+	NamespacePrefix string                    `json:"namespace_prefix,omitempty"`
+	ConfigPath      string                    `json:"config_path,omitempty"`
+	StartedAt       time.Time                 `json:"started_at"`
+	FinishedAt      time.Time                 `json:"finished_at"`
+	Charts          map[string]*ManifestChart `json:"charts,omitempty"`
+	Images          []*ManifestImage          `json:"images,omitempty"`
+	Files           []*ManifestFile           `json:"files"`
+}
+
+// ManifestChart deployed chart info
+type ManifestChart struct {
+	Chart      string                 `json:"chart,omitempty"`
+	Version    string                 `json:"version,omitempty"`
+	AppVersion string                 `json:"app_version,omitempty"`
+	Revision   int                    `json:"revision,omitempty"`
+	Values     map[string]interface{} `json:"values,omitempty"`
+}
+
+// ManifestImage image a container was running from, ImageID contains the image digest
+type ManifestImage struct {
+	Pod       string `json:"pod"`
+	Container string `json:"container"`
+	Image     string `json:"image"`
+	ImageID   string `json:"image_id,omitempty"`
+}
+
+// ManifestFile single file in a bundle
+type ManifestFile struct {
+	Path        string    `json:"path"`
+	Size        int64     `json:"size"`
+	SHA256      string    `json:"sha256"`
+	CollectedAt time.Time `json:"collected_at"`
+}
+
+// BundleFormat returns the bundle format matching the path extension, empty string if path is a plain directory
+func BundleFormat(path string) string {
+	switch {
+	case strings.HasSuffix(path, ".tar.gz"), strings.HasSuffix(path, ".tgz"):
+		return BundleFormatTarGz
+	case strings.HasSuffix(path, ".zip"):
+		return BundleFormatZip
+	default:
+		return ""
+	}
+}
+
+// dumpBundle collects all artifacts into a temporary dir and packs them with a manifest into a single bundle file
+func (a *Artifacts) dumpBundle(bundlePath string) error {
+	manifest := &ArtifactsManifest{
+		Namespace:       a.env.Config.Namespace,
+		Preset:          a.env.Config.Preset,
+		NamespacePrefix: a.env.Config.NamespacePrefix,
+		ConfigPath:      a.env.Config.Path,
+		StartedAt:       time.Now().UTC(),
+	}
+	tmpDir, err := os.MkdirTemp("", "helmenv-artifacts-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmpDir)
+	if err := a.writePodArtifacts(tmpDir); err != nil {
+		return err
+	}
+	if err := a.writeChaosTimeline(tmpDir); err != nil {
+		return err
+	}
+	a.runCollectors(context.Background(), tmpDir)
+	if err := a.describeEnvironment(manifest); err != nil {
+		return err
+	}
+	manifest.FinishedAt = time.Now().UTC()
+	if err := WriteArtifactsBundle(tmpDir, bundlePath, manifest); err != nil {
+		return err
+	}
+	log.Info().
+		Str("Bundle", bundlePath).
+		Int("Files", len(manifest.Files)).
+		Msg("Artifacts bundle written")
+	return nil
+}
+
+// describeEnvironment fills the manifest with charts and images info
+func (a *Artifacts) describeEnvironment(manifest *ArtifactsManifest) error {
+	redactor, err := a.env.Config.Redactor()
+	if err != nil {
+		return err
+	}
+	manifest.Charts = map[string]*ManifestChart{}
+	for name, chart := range a.env.Config.Charts {
+		mc := &ManifestChart{Values: redactor.Map(chart.Values)}
+		rel, err := chart.Release()
+		if err != nil {
+			log.Warn().Err(err).Str("Release", chart.ReleaseName).Msg("Error fetching chart release info")
+		} else if rel.Chart != nil && rel.Chart.Metadata != nil {
+			mc.Chart = rel.Chart.Metadata.Name
+			mc.Version = rel.Chart.Metadata.Version
+			mc.AppVersion = rel.Chart.Metadata.AppVersion
+			mc.Revision = rel.Version
+		}
+		manifest.Charts[name] = mc
+	}
+	podsList, err := a.podsClient.List(context.Background(), metaV1.ListOptions{})
+	if err != nil {
+		return err
+	}
+	for _, pod := range podsList.Items {
+		for _, status := range pod.Status.ContainerStatuses {
+			manifest.Images = append(manifest.Images, &ManifestImage{
+				Pod:       pod.Name,
+				Container: status.Name,
+				Image:     status.Image,
+				ImageID:   status.ImageID,
+			})
+		}
+	}
+	return nil
+}
+
+// WriteArtifactsBundle packs every file from srcDir into a bundle, format is chosen by the bundle path extension,
+// manifest files list is filled with checksums and written as the first bundle entry
+func WriteArtifactsBundle(srcDir, bundlePath string, manifest *ArtifactsManifest) error {
+	format := BundleFormat(bundlePath)
+	if format == "" {
+		return fmt.Errorf("unknown bundle format for %s, use .tar.gz, .tgz or .zip", bundlePath)
+	}
+	var paths []string
+	manifest.Files = nil
+	err := filepath.WalkDir(srcDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(srcDir, path)
+		if err != nil {
+			return err
+		}
+		mf, err := describeFile(path, filepath.ToSlash(rel))
+		if err != nil {
+			return err
+		}
+		manifest.Files = append(manifest.Files, mf)
+		paths = append(paths, path)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	manifestBytes, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	if dir := filepath.Dir(bundlePath); dir != "." {
+		if err := mkdirIfNotExists(dir); err != nil {
+			return err
+		}
+	}
+	f, err := os.Create(bundlePath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	switch format {
+	case BundleFormatZip:
+		err = writeZip(f, manifestBytes, manifest.Files, paths)
+	default:
+		err = writeTarGz(f, manifestBytes, manifest.Files, paths)
+	}
+	if err != nil {
+		return errors.Wrapf(err, "failed to write bundle %s", bundlePath)
+	}
+	return f.Close()
+}
+
+func describeFile(path, name string) (*ManifestFile, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return nil, err
+	}
+	return &ManifestFile{
+		Path:        name,
+		Size:        info.Size(),
+		SHA256:      hex.EncodeToString(h.Sum(nil)),
+		CollectedAt: info.ModTime().UTC(),
+	}, nil
+}
+
+func writeTarGz(w io.Writer, manifest []byte, files []*ManifestFile, paths []string) error {
+	gw := gzip.NewWriter(w)
+	tw := tar.NewWriter(gw)
+	if err := tw.WriteHeader(&tar.Header{
+		Name:    ManifestFileName,
+		Mode:    0644,
+		Size:    int64(len(manifest)),
+		ModTime: time.Now(),
+	}); err != nil {
+		return err
+	}
+	if _, err := tw.Write(manifest); err != nil {
+		return err
+	}
+	for i, mf := range files {
+		if err := tw.WriteHeader(&tar.Header{
+			Name:    mf.Path,
+			Mode:    0644,
+			Size:    mf.Size,
+			ModTime: mf.CollectedAt,
+		}); err != nil {
+			return err
+		}
+		if err := copyFile(tw, paths[i]); err != nil {
+			return err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return gw.Close()
+}
+
+func writeZip(w io.Writer, manifest []byte, files []*ManifestFile, paths []string) error {
+	zw := zip.NewWriter(w)
+	mw, err := zw.Create(ManifestFileName)
+	if err != nil {
+		return err
+	}
+	if _, err := mw.Write(manifest); err != nil {
+		return err
+	}
+	for i, mf := range files {
+		fw, err := zw.CreateHeader(&zip.FileHeader{
+			Name:     mf.Path,
+			Method:   zip.Deflate,
+			Modified: mf.CollectedAt,
+		})
+		if err != nil {
+			return err
+		}
+		if err := copyFile(fw, paths[i]); err != nil {
+			return err
+		}
+	}
+	return zw.Close()
+}
+
+func copyFile(w io.Writer, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = io.Copy(w, f)
+	return err
+}
+
+// ReadArtifactsManifest reads the manifest of an artifacts bundle
+func ReadArtifactsManifest(bundlePath string) (*ArtifactsManifest, error) {
+	var manifest *ArtifactsManifest
+	err := walkBundle(bundlePath, func(name string, r io.Reader) (bool, error) {
+		if name != ManifestFileName {
+			return true, nil
+		}
+		manifest = &ArtifactsManifest{}
+		return false, json.NewDecoder(r).Decode(manifest)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if manifest == nil {
+		return nil, fmt.Errorf("bundle %s has no %s", bundlePath, ManifestFileName)
+	}
+	return manifest, nil
+}
+
+// ExtractArtifactsBundle extracts every bundle file into dir and verifies checksums against the manifest,
+// files listed in the manifest must all be in the bundle
+func ExtractArtifactsBundle(bundlePath, dir string) (*ArtifactsManifest, error) {
+	manifest, err := ReadArtifactsManifest(bundlePath)
+	if err != nil {
+		return nil, err
+	}
+	checksums := map[string]string{}
+	for _, mf := range manifest.Files {
+		checksums[mf.Path] = mf.SHA256
+	}
+	err = walkBundle(bundlePath, func(name string, r io.Reader) (bool, error) {
+		target := filepath.Join(dir, filepath.FromSlash(name))
+		rel, err := filepath.Rel(dir, target)
+		if err != nil || rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(os.PathSeparator)) {
+			return false, fmt.Errorf("bundle entry %s points outside of %s", name, dir)
+		}
+		if err := mkdirIfNotExists(filepath.Dir(target)); err != nil {
+			return false, err
+		}
+		f, err := os.Create(target)
+		if err != nil {
+			return false, err
+		}
+		defer f.Close()
+		h := sha256.New()
+		if _, err := io.Copy(io.MultiWriter(f, h), r); err != nil {
+			return false, err
+		}
+		if name == ManifestFileName {
+			return true, f.Close()
+		}
+		expected, ok := checksums[name]
+		if !ok {
+			return false, fmt.Errorf("bundle entry %s is not listed in the manifest", name)
+		}
+		if sum := hex.EncodeToString(h.Sum(nil)); sum != expected {
+			return false, fmt.Errorf("checksum mismatch for %s: expected %s, got %s", name, expected, sum)
+		}
+		delete(checksums, name)
+		return true, f.Close()
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(checksums) > 0 {
+		missing := make([]string, 0, len(checksums))
+		for name := range checksums {
+			missing = append(missing, name)
+		}
+		sort.Strings(missing)
+		return nil, fmt.Errorf("bundle %s is missing files listed in the manifest: %s", bundlePath, strings.Join(missing, ", "))
+	}
+	sort.Slice(manifest.Files, func(i, j int) bool {
+		return manifest.Files[i].Path < manifest.Files[j].Path
+	})
+	return manifest, nil
+}
+
+// walkBundle calls f for every regular file in a bundle until f returns false or an error
+func walkBundle(bundlePath string, f func(name string, r io.Reader) (bool, error)) error {
+	switch BundleFormat(bundlePath) {
+	case BundleFormatZip:
+		zr, err := zip.OpenReader(bundlePath)
+		if err != nil {
+			return err
+		}
+		defer zr.Close()
+		for _, zf := range zr.File {
+			if zf.FileInfo().IsDir() {
+				continue
+			}
+			rc, err := zf.Open()
+			if err != nil {
+				return err
+			}
+			next, err := f(zf.Name, rc)
+			rc.Close()
+			if err != nil || !next {
+				return err
+			}
+		}
+		return nil
+	case BundleFormatTarGz:
+		file, err := os.Open(bundlePath)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		gr, err := gzip.NewReader(file)
+		if err != nil {
+			return err
+		}
+		tr := tar.NewReader(gr)
+		for {
+			hdr, err := tr.Next()
+			if err == io.EOF {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			if hdr.Typeflag != tar.TypeReg {
+				continue
+			}
+			next, err := f(hdr.Name, tr)
+			if err != nil || !next {
+				return err
+			}
+		}
+	default:
+		return fmt.Errorf("unknown bundle format for %s, use .tar.gz, .tgz or .zip", bundlePath)
+	}
+}
@@ -0,0 +1,108 @@
+package environment_test
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/goplugin/helmenv/environment"
+	"github.com/stretchr/testify/require"
+)
+
+func TestArtifactsBundle(t *testing.T) {
+	t.Parallel()
+
+	for _, bundleName := range []string{"bundle.tar.gz", "bundle.zip"} {
+		bundleName := bundleName
+		t.Run(bundleName, func(t *testing.T) {
+			t.Parallel()
+
+			srcDir := t.TempDir()
+			require.NoError(t, os.MkdirAll(filepath.Join(srcDir, "plugin-node_0"), os.ModePerm))
+			require.NoError(t, os.WriteFile(filepath.Join(srcDir, "plugin-node_0", "node.log"), []byte("node logs"), 0644))
+			require.NoError(t, os.WriteFile(filepath.Join(srcDir, "timeline.json"), []byte("[]"), 0644))
+
+			bundlePath := filepath.Join(t.TempDir(), bundleName)
+			err := environment.WriteArtifactsBundle(srcDir, bundlePath, &environment.ArtifactsManifest{Namespace: "test-ns", Preset: "plugin"})
+			require.NoError(t, err)
+
+			manifest, err := environment.ReadArtifactsManifest(bundlePath)
+			require.NoError(t, err)
+			require.Equal(t, "test-ns", manifest.Namespace)
+			require.Equal(t, "plugin", manifest.Preset)
+			require.Len(t, manifest.Files, 2)
+
+			extractDir := t.TempDir()
+			_, err = environment.ExtractArtifactsBundle(bundlePath, extractDir)
+			require.NoError(t, err)
+			b, err := os.ReadFile(filepath.Join(extractDir, "plugin-node_0", "node.log"))
+			require.NoError(t, err)
+			require.Equal(t, "node logs", string(b))
+		})
+	}
+}
+
+// not parallel, the working dir is changed
+func TestArtifactsBundleExtractToWorkingDir(t *testing.T) {
+	srcDir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(srcDir, "plugin-node_0"), os.ModePerm))
+	require.NoError(t, os.WriteFile(filepath.Join(srcDir, "plugin-node_0", "node.log"), []byte("node logs"), 0644))
+	bundlePath := filepath.Join(t.TempDir(), "bundle.tar.gz")
+	require.NoError(t, environment.WriteArtifactsBundle(srcDir, bundlePath, &environment.ArtifactsManifest{Namespace: "test-ns"}))
+
+	wd, err := os.Getwd()
+	require.NoError(t, err)
+	require.NoError(t, os.Chdir(t.TempDir()))
+	defer func() { require.NoError(t, os.Chdir(wd)) }()
+	_, err = environment.ExtractArtifactsBundle(bundlePath, ".")
+	require.NoError(t, err)
+	b, err := os.ReadFile(filepath.Join("plugin-node_0", "node.log"))
+	require.NoError(t, err)
+	require.Equal(t, "node logs", string(b))
+}
+
+// writeTarBundle writes a tar.gz bundle with the manifest and files, without checking them
+func writeTarBundle(t *testing.T, manifest *environment.ArtifactsManifest, files map[string]string) string {
+	data, err := json.Marshal(manifest)
+	require.NoError(t, err)
+	bundlePath := filepath.Join(t.TempDir(), "bundle.tar.gz")
+	f, err := os.Create(bundlePath)
+	require.NoError(t, err)
+	gw := gzip.NewWriter(f)
+	tw := tar.NewWriter(gw)
+	require.NoError(t, tw.WriteHeader(&tar.Header{Name: environment.ManifestFileName, Mode: 0644, Size: int64(len(data))}))
+	_, err = tw.Write(data)
+	require.NoError(t, err)
+	for name, content := range files {
+		require.NoError(t, tw.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(content))}))
+		_, err = tw.Write([]byte(content))
+		require.NoError(t, err)
+	}
+	require.NoError(t, tw.Close())
+	require.NoError(t, gw.Close())
+	require.NoError(t, f.Close())
+	return bundlePath
+}
+
+func TestArtifactsBundleMissingFile(t *testing.T) {
+	t.Parallel()
+
+	bundlePath := writeTarBundle(t, &environment.ArtifactsManifest{
+		Namespace: "test-ns",
+		Files:     []*environment.ManifestFile{{Path: "plugin-node_0/node.log", Size: 9}},
+	}, nil)
+	_, err := environment.ExtractArtifactsBundle(bundlePath, t.TempDir())
+	require.EqualError(t, err, "bundle "+bundlePath+" is missing files listed in the manifest: plugin-node_0/node.log")
+}
+
+func TestArtifactsBundleEntryOutsideDir(t *testing.T) {
+	t.Parallel()
+
+	bundlePath := writeTarBundle(t, &environment.ArtifactsManifest{Namespace: "test-ns"}, map[string]string{"../evil.log": "evil"})
+	dir := filepath.Join(t.TempDir(), "extract")
+	_, err := environment.ExtractArtifactsBundle(bundlePath, dir)
+	require.EqualError(t, err, "bundle entry ../evil.log points outside of "+dir)
+}
@@ -0,0 +1,46 @@
+package environment
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+
+	"github.com/goplugin/helmenv/chaos"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// ChaosTimelineFile chaos timeline artifact, see chaos.TimelineEvent
+	ChaosTimelineFile = "chaos_timeline.json"
+	// ChaosTimelineReportFile human-readable chaos timeline artifact
+	ChaosTimelineReportFile = "chaos_timeline.txt"
+)
+
+// writeChaosTimeline writes chaos experiment events of the environment as JSON and as a report,
+// nothing is written if no experiments were run
+func (a *Artifacts) writeChaosTimeline(dir string) error {
+	if a.env.Chaos == nil {
+		return nil
+	}
+	events := a.env.Chaos.Timeline()
+	if len(events) == 0 {
+		return nil
+	}
+	log.Info().Int("Events", len(events)).Msg("Writing chaos timeline")
+	data, err := json.MarshalIndent(events, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(dir, ChaosTimelineFile), data, 0644); err != nil {
+		return err
+	}
+	f, err := os.Create(filepath.Join(dir, ChaosTimelineReportFile))
+	if err != nil {
+		return err
+	}
+	if err := chaos.WriteTimelineReport(f, events); err != nil {
+		_ = f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,57 @@
+package environment
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/goplugin/helmenv/chaos"
+	"github.com/goplugin/helmenv/chaos/experiments"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestWriteChaosTimeline(t *testing.T) {
+	t.Parallel()
+
+	client := fake.NewSimpleClientset(&v1.Pod{ObjectMeta: metaV1.ObjectMeta{
+		Name:      "plugin-node-0",
+		Namespace: "env",
+		Labels:    map[string]string{AppEnumerationLabelKey: "plugin-node"},
+	}})
+	cc, err := chaos.NewController(&chaos.Config{
+		NamespaceName: "env",
+		Backend:       &chaos.NativeBackend{Client: client, Namespace: "env"},
+	})
+	require.NoError(t, err)
+	a := &Artifacts{env: &Environment{Config: &Config{}, Chaos: cc}}
+
+	dir := t.TempDir()
+	require.NoError(t, a.writeChaosTimeline(dir))
+	_, err = os.Stat(filepath.Join(dir, ChaosTimelineFile))
+	require.True(t, os.IsNotExist(err), "nothing is written without experiments")
+
+	name, err := cc.Run(&experiments.PodKill{Mode: experiments.ModeAll, LabelKey: AppEnumerationLabelKey, LabelValue: "plugin-node"})
+	require.NoError(t, err)
+	_, err = cc.Status(name)
+	require.NoError(t, err)
+	require.NoError(t, cc.Stop(name))
+	require.NoError(t, a.writeChaosTimeline(dir))
+
+	data, err := os.ReadFile(filepath.Join(dir, ChaosTimelineFile))
+	require.NoError(t, err)
+	var events []chaos.TimelineEvent
+	require.NoError(t, json.Unmarshal(data, &events))
+	require.Len(t, events, 3)
+	require.Equal(t, chaos.EventInjected, events[1].Type)
+	require.Equal(t, []string{"env/plugin-node-0"}, events[1].Pods)
+
+	report, err := os.ReadFile(filepath.Join(dir, ChaosTimelineReportFile))
+	require.NoError(t, err)
+	require.True(t, strings.HasPrefix(string(report), "TIME"))
+	require.Contains(t, string(report), name)
+}
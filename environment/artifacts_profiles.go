@@ -0,0 +1,215 @@
+package environment
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// ProfileCPU CPU profile, collected for ProfileCollector.CPUProfileDuration
+	ProfileCPU = "profile"
+	// ProfileHeap heap profile
+	ProfileHeap = "heap"
+	// ProfileGoroutine goroutine stacks profile
+	ProfileGoroutine = "goroutine"
+
+	// DefaultCPUProfileDuration default duration of a collected CPU profile
+	DefaultCPUProfileDuration = 10 * time.Second
+)
+
+// ArtifactCollector collects additional artifacts into a dir every time environment artifacts are dumped
+type ArtifactCollector interface {
+	Collect(ctx context.Context, env *Environment, dir string) error
+}
+
+// ProfileCollector scrapes Prometheus metrics and fetches pprof profiles from every pod of a chart that exposes
+// the named port, ports are reached through port forwarding, so the chart is connected if it wasn't yet
+type ProfileCollector struct {
+	// Chart name of the chart to collect from
+	Chart string
+	// PortName named port that serves metrics and /debug/pprof, defaults to "access"
+	PortName string
+	// MetricsPath path of Prometheus metrics, defaults to /metrics, metrics aren't scraped if Metrics is false
+	MetricsPath string
+	Metrics     bool
+	// Profiles pprof profiles to fetch, see ProfileCPU, ProfileHeap and ProfileGoroutine
+	Profiles []string
+	// CPUProfileDuration defaults to DefaultCPUProfileDuration
+	CPUProfileDuration time.Duration
+	// Headers added to every request, for example when pprof endpoints require authorization
+	Headers map[string]string
+	Client  *http.Client
+}
+
+// NewProfileCollector creates a collector of metrics and CPU, heap and goroutine profiles for a chart
+func NewProfileCollector(chart string) *ProfileCollector {
+	return &ProfileCollector{
+		Chart:    chart,
+		Metrics:  true,
+		Profiles: []string{ProfileCPU, ProfileHeap, ProfileGoroutine},
+	}
+}
+
+// Collect writes metrics and profiles of every matching chart connection to dir/<chart>/<connection>/
+func (p *ProfileCollector) Collect(ctx context.Context, env *Environment, dir string) error {
+	chart, err := env.Charts.Get(p.Chart)
+	if err != nil {
+		return err
+	}
+	portName := p.portName()
+	targets, err := p.targets(chart, portName)
+	if err != nil {
+		return err
+	}
+	keys := make([]string, 0, len(targets))
+	for key := range targets {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		connDir := filepath.Join(dir, p.Chart, key)
+		if err := mkdirIfNotExists(connDir); err != nil {
+			return err
+		}
+		baseURL := fmt.Sprintf("http://localhost:%d", targets[key])
+		if p.Metrics {
+			if err := p.fetch(ctx, baseURL+p.metricsPath(), filepath.Join(connDir, "metrics.txt")); err != nil {
+				log.Warn().Err(err).Str("Connection", key).Msg("Error scraping metrics")
+			}
+		}
+		for _, profile := range p.Profiles {
+			profileURL := fmt.Sprintf("%s/debug/pprof/%s", baseURL, profile)
+			if profile == ProfileCPU {
+				profileURL = fmt.Sprintf("%s?seconds=%d", profileURL, int(p.cpuProfileDuration().Seconds()))
+			}
+			if err := p.fetch(ctx, profileURL, filepath.Join(connDir, profile+".pprof")); err != nil {
+				log.Warn().Err(err).Str("Connection", key).Str("Profile", profile).Msg("Error fetching profile")
+			}
+		}
+	}
+	return nil
+}
+
+// targets returns local forwarded ports of every connection exposing the port, keyed by the connection key
+func (p *ProfileCollector) targets(chart *HelmChart, portName string) (map[string]int, error) {
+	connected := true
+	targets := map[string]int{}
+	chart.ChartConnections.Range(func(key string, cc *ChartConnection) bool {
+		if _, ok := cc.RemotePorts[portName]; !ok {
+			return true
+		}
+		if _, ok := cc.LocalPorts[portName]; !ok {
+			connected = false
+		}
+		return true
+	})
+	if !connected {
+		log.Info().Str("Chart", p.Chart).Msg("Connecting chart to collect profiles")
+		if err := chart.Connect(); err != nil {
+			return nil, err
+		}
+	}
+	chart.ChartConnections.Range(func(key string, cc *ChartConnection) bool {
+		if localPort, ok := cc.LocalPorts[portName]; ok {
+			targets[key] = localPort
+		}
+		return true
+	})
+	if len(targets) == 0 {
+		return nil, fmt.Errorf("no connections with port %s found in chart %s", portName, p.Chart)
+	}
+	return targets, nil
+}
+
+func (p *ProfileCollector) fetch(ctx context.Context, url, path string) error {
+	timeout := p.cpuProfileDuration() + 30*time.Second
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	for k, v := range p.Headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := httpClient(p.Client).Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s failed with status %s", url, resp.Status)
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(f, resp.Body); err != nil {
+		f.Close()
+		return errors.Wrapf(err, "failed to write %s", path)
+	}
+	return f.Close()
+}
+
+func (p *ProfileCollector) portName() string {
+	if len(p.PortName) == 0 {
+		return "access"
+	}
+	return p.PortName
+}
+
+func (p *ProfileCollector) metricsPath() string {
+	if len(p.MetricsPath) == 0 {
+		return "/metrics"
+	}
+	return p.MetricsPath
+}
+
+func (p *ProfileCollector) cpuProfileDuration() time.Duration {
+	if p.CPUProfileDuration <= 0 {
+		return DefaultCPUProfileDuration
+	}
+	return p.CPUProfileDuration
+}
+
+// runCollectors runs every collector into dir, errors are logged so a single failing collector doesn't fail the dump
+func (a *Artifacts) runCollectors(ctx context.Context, dir string) {
+	for _, c := range a.Collectors {
+		if err := c.Collect(ctx, a.env, dir); err != nil {
+			log.Error().Err(err).Str("Dir", dir).Msg("Error collecting artifacts")
+		}
+	}
+}
+
+// CollectEvery runs collectors every interval in the background until ctx is cancelled,
+// every round is written to dir/<timestamp>
+func (a *Artifacts) CollectEvery(ctx context.Context, dir string, interval time.Duration) error {
+	if len(a.Collectors) == 0 {
+		return errors.New("no artifact collectors configured")
+	}
+	if err := mkdirIfNotExists(dir); err != nil {
+		return err
+	}
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case t := <-ticker.C:
+				a.runCollectors(ctx, filepath.Join(dir, t.UTC().Format("20060102T150405Z")))
+			}
+		}
+	}()
+	return nil
+}
@@ -0,0 +1,70 @@
+package environment
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestProfileCollector(t *testing.T) {
+	t.Parallel()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/metrics", func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte("up 1\n"))
+	})
+	mux.HandleFunc("/debug/pprof/", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(r.URL.Path + "?" + r.URL.RawQuery))
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+	u, err := url.Parse(srv.URL)
+	require.NoError(t, err)
+	port, err := strconv.Atoi(u.Port())
+	require.NoError(t, err)
+
+	env := &Environment{Config: &Config{Charts: Charts{
+		"plugin": &HelmChart{
+			ReleaseName: "plugin",
+			ChartConnections: ChartConnections{
+				"plugin-node_0_node": {
+					RemotePorts: map[string]int{"access": 6688, "p2p": 6690},
+					LocalPorts:  map[string]int{"access": port},
+				},
+				"plugin-node_0_plugin-db": {
+					RemotePorts: map[string]int{"postgres": 5432},
+					LocalPorts:  map[string]int{"postgres": 5432},
+				},
+			},
+		},
+	}}}
+	collector := NewProfileCollector("plugin")
+	collector.CPUProfileDuration = time.Second
+	dir := t.TempDir()
+	require.NoError(t, collector.Collect(context.Background(), env, dir))
+
+	connDir := filepath.Join(dir, "plugin", "plugin-node_0_node")
+	for file, expected := range map[string]string{
+		"metrics.txt":     "up 1\n",
+		"profile.pprof":   "/debug/pprof/profile?seconds=1",
+		"heap.pprof":      "/debug/pprof/heap?",
+		"goroutine.pprof": "/debug/pprof/goroutine?",
+	} {
+		b, err := os.ReadFile(filepath.Join(connDir, file))
+		require.NoError(t, err)
+		require.Equal(t, expected, string(b))
+	}
+	_, err = os.Stat(filepath.Join(dir, "plugin", "plugin-node_0_plugin-db"))
+	require.True(t, os.IsNotExist(err), "connections without the port must be skipped")
+
+	collector.Chart = "geth"
+	require.Error(t, collector.Collect(context.Background(), env, dir))
+}
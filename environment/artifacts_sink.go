@@ -0,0 +1,340 @@
+package environment
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+)
+
+// unsignedPayload SigV4 payload hash of streamed bodies
+const unsignedPayload = "UNSIGNED-PAYLOAD"
+
+// ArtifactSink is a storage artifacts are uploaded to after they are dumped
+type ArtifactSink interface {
+	// Put stores size bytes read from r under the key
+	Put(ctx context.Context, key string, r io.Reader, size int64) error
+	// String returns human-readable sink location
+	String() string
+}
+
+// NewArtifactSink creates a sink from URL:
+//
+//	file:///path/to/dir - LocalDirSink
+//	s3://bucket?endpoint=http://minio:9000&region=us-east-1&path_style=true - S3Sink, credentials are read from
+//	AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY and AWS_SESSION_TOKEN
+//	http(s)://host/path - HTTPPutSink, every key is PUT to URL/key
+func NewArtifactSink(rawURL string) (ArtifactSink, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid artifact sink URL %s", rawURL)
+	}
+	switch u.Scheme {
+	case "file", "":
+		dir := u.Path
+		if len(u.Host) > 0 {
+			dir = filepath.Join(u.Host, u.Path)
+		}
+		return &LocalDirSink{Dir: dir}, nil
+	case "s3":
+		q := u.Query()
+		return &S3Sink{
+			Endpoint:        q.Get("endpoint"),
+			Region:          q.Get("region"),
+			Bucket:          u.Host,
+			PathStyle:       q.Get("path_style") == "true",
+			AccessKeyID:     os.Getenv("AWS_ACCESS_KEY_ID"),
+			SecretAccessKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+			SessionToken:    os.Getenv("AWS_SESSION_TOKEN"),
+		}, nil
+	case "http", "https":
+		return &HTTPPutSink{URL: rawURL}, nil
+	default:
+		return nil, fmt.Errorf("unsupported artifact sink scheme %s, use file, s3, http or https", u.Scheme)
+	}
+}
+
+// UploadArtifacts uploads a single file or every file of a dir to the sink,
+// file keys are prefix/<file name>, dir keys are prefix/<dir name>/<relative path>
+func UploadArtifacts(ctx context.Context, sink ArtifactSink, prefix, src string) error {
+	info, err := os.Stat(src)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return putFile(ctx, sink, path.Join(prefix, filepath.Base(src)), src)
+	}
+	root := filepath.Base(filepath.Clean(src))
+	return filepath.WalkDir(src, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(src, p)
+		if err != nil {
+			return err
+		}
+		return putFile(ctx, sink, path.Join(prefix, root, filepath.ToSlash(rel)), p)
+	})
+}
+
+func putFile(ctx context.Context, sink ArtifactSink, key, filePath string) error {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	log.Debug().Str("Sink", sink.String()).Str("Key", key).Msg("Uploading artifact")
+	if err := sink.Put(ctx, key, f, info.Size()); err != nil {
+		return errors.Wrapf(err, "failed to upload %s to %s", key, sink)
+	}
+	return nil
+}
+
+// LocalDirSink copies artifacts to a local directory
+type LocalDirSink struct {
+	Dir string
+}
+
+// Put writes r to Dir/key
+func (s *LocalDirSink) Put(_ context.Context, key string, r io.Reader, _ int64) error {
+	target := filepath.Join(s.Dir, filepath.FromSlash(key))
+	if err := mkdirIfNotExists(filepath.Dir(target)); err != nil {
+		return err
+	}
+	f, err := os.Create(target)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(f, r); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func (s *LocalDirSink) String() string {
+	return "file://" + s.Dir
+}
+
+// HTTPPutSink uploads every artifact with an HTTP PUT request to URL/key
+type HTTPPutSink struct {
+	URL     string
+	Headers map[string]string
+	Client  *http.Client
+}
+
+// Put PUTs r to URL/key, key segments are path escaped
+func (s *HTTPPutSink) Put(ctx context.Context, key string, r io.Reader, size int64) error {
+	segments := strings.Split(key, "/")
+	for i, segment := range segments {
+		segments[i] = url.PathEscape(segment)
+	}
+	target := strings.TrimSuffix(s.URL, "/") + "/" + strings.Join(segments, "/")
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, target, putBody(r, size))
+	if err != nil {
+		return err
+	}
+	req.ContentLength = size
+	for k, v := range s.Headers {
+		req.Header.Set(k, v)
+	}
+	return doPut(httpClient(s.Client), req)
+}
+
+func (s *HTTPPutSink) String() string {
+	return s.URL
+}
+
+// S3Sink uploads artifacts to S3 compatible object storage, like AWS S3 or MinIO
+type S3Sink struct {
+	// Endpoint storage URL, defaults to https://s3.<region>.amazonaws.com
+	Endpoint string
+	// Region defaults to us-east-1
+	Region string
+	Bucket string
+	// PathStyle addresses bucket as Endpoint/Bucket instead of Bucket.Endpoint, usually required for MinIO
+	PathStyle       bool
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
+	Client          *http.Client
+}
+
+// Put uploads r as a Bucket object with the key name
+func (s *S3Sink) Put(ctx context.Context, key string, r io.Reader, size int64) error {
+	u, err := s.objectURL(key)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, u.String(), putBody(r, size))
+	if err != nil {
+		return err
+	}
+	req.ContentLength = size
+	s.sign(req, time.Now().UTC(), unsignedPayload)
+	return doPut(httpClient(s.Client), req)
+}
+
+func (s *S3Sink) String() string {
+	return fmt.Sprintf("s3://%s", s.Bucket)
+}
+
+func (s *S3Sink) region() string {
+	if len(s.Region) == 0 {
+		return "us-east-1"
+	}
+	return s.Region
+}
+
+func (s *S3Sink) objectURL(key string) (*url.URL, error) {
+	endpoint := s.Endpoint
+	if len(endpoint) == 0 {
+		endpoint = fmt.Sprintf("https://s3.%s.amazonaws.com", s.region())
+	}
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid S3 endpoint %s", endpoint)
+	}
+	if s.PathStyle {
+		u.Path = path.Join("/", u.Path, s.Bucket, key)
+	} else {
+		u.Host = s.Bucket + "." + u.Host
+		u.Path = path.Join("/", u.Path, key)
+	}
+	// the request path must be encoded the same way as the signed one
+	u.RawPath = uriEncode(u.Path, false)
+	return u, nil
+}
+
+// sign signs the request with AWS Signature Version 4, payloadHash is hex encoded SHA256 of the body
+// or unsignedPayload, so objects can be streamed
+func (s *S3Sink) sign(req *http.Request, now time.Time, payloadHash string) {
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+	req.Header.Set("Host", req.URL.Host)
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	if len(s.SessionToken) > 0 {
+		req.Header.Set("X-Amz-Security-Token", s.SessionToken)
+	}
+	if len(s.AccessKeyID) == 0 {
+		return
+	}
+	var headerNames []string
+	for name := range req.Header {
+		headerNames = append(headerNames, strings.ToLower(name))
+	}
+	sort.Strings(headerNames)
+	var canonicalHeaders strings.Builder
+	for _, name := range headerNames {
+		canonicalHeaders.WriteString(name + ":" + strings.TrimSpace(req.Header.Get(name)) + "\n")
+	}
+	signedHeaders := strings.Join(headerNames, ";")
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		uriEncode(req.URL.Path, false),
+		canonicalQuery(req.URL.Query()),
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+	scope := strings.Join([]string{date, s.region(), "s3", "aws4_request"}, "/")
+	canonicalHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := strings.Join([]string{
+		"AWS4-HMAC-SHA256",
+		amzDate,
+		scope,
+		hex.EncodeToString(canonicalHash[:]),
+	}, "\n")
+	key := hmacSHA256([]byte("AWS4"+s.SecretAccessKey), date)
+	key = hmacSHA256(key, s.region())
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+	req.Header.Set("Authorization", fmt.Sprintf(
+		"AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s.AccessKeyID, scope, signedHeaders, signature,
+	))
+}
+
+// canonicalQuery returns query sorted by key and encoded as SigV4 requires
+func canonicalQuery(query url.Values) string {
+	var pairs []string
+	for k, values := range query {
+		for _, v := range values {
+			pairs = append(pairs, uriEncode(k, true)+"="+uriEncode(v, true))
+		}
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, "&")
+}
+
+// uriEncode percent encodes everything except RFC 3986 unreserved characters, like S3 does,
+// slashes are kept unless encodeSlash is set
+func uriEncode(s string, encodeSlash bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9',
+			c == '-', c == '_', c == '.', c == '~', c == '/' && !encodeSlash:
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+// putBody returns http.NoBody for empty bodies, otherwise an unknown body type with zero ContentLength
+// is sent chunked, which object storages reject
+func putBody(r io.Reader, size int64) io.Reader {
+	if size == 0 {
+		return http.NoBody
+	}
+	return r
+}
+
+func httpClient(c *http.Client) *http.Client {
+	if c == nil {
+		return http.DefaultClient
+	}
+	return c
+}
+
+func doPut(client *http.Client, req *http.Request) error {
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("PUT %s failed with status %s: %s", req.URL.Redacted(), resp.Status, string(body))
+	}
+	return nil
+}
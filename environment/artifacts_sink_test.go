@@ -0,0 +1,130 @@
+package environment_test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/goplugin/helmenv/environment"
+	"github.com/stretchr/testify/require"
+)
+
+// objectStore is a minimal S3-like stand-in that stores every PUT object by its path
+type objectStore struct {
+	mu      sync.Mutex
+	objects map[string]string
+	auth    map[string]string
+	// requests headers of every PUT object, with Content-Length and Transfer-Encoding as they were received
+	requests map[string]http.Header
+}
+
+func newObjectStore() *objectStore {
+	return &objectStore{objects: map[string]string{}, auth: map[string]string{}, requests: map[string]http.Header{}}
+}
+
+func (s *objectStore) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	b, err := io.ReadAll(r.Body)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.objects[r.URL.Path] = string(b)
+	s.auth[r.URL.Path] = r.Header.Get("Authorization")
+	h := r.Header.Clone()
+	h.Set("Content-Length", strconv.FormatInt(r.ContentLength, 10))
+	h["Transfer-Encoding"] = r.TransferEncoding
+	s.requests[r.URL.Path] = h
+	w.WriteHeader(http.StatusOK)
+}
+
+func writeArtifactsDir(t *testing.T) string {
+	dir := filepath.Join(t.TempDir(), "test-artifacts")
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "plugin-node_0"), os.ModePerm))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "plugin-node_0", "node.log"), []byte("node logs"), 0644))
+	return dir
+}
+
+func TestS3Sink(t *testing.T) {
+	t.Parallel()
+
+	store := newObjectStore()
+	srv := httptest.NewServer(store)
+	defer srv.Close()
+
+	sink := &environment.S3Sink{
+		Endpoint:        srv.URL,
+		Bucket:          "artifacts",
+		PathStyle:       true,
+		AccessKeyID:     "minio",
+		SecretAccessKey: "minio-secret",
+	}
+	err := environment.UploadArtifacts(context.Background(), sink, "run-1", writeArtifactsDir(t))
+	require.NoError(t, err)
+
+	key := "/artifacts/run-1/test-artifacts/plugin-node_0/node.log"
+	require.Equal(t, "node logs", store.objects[key])
+	require.True(t, strings.HasPrefix(store.auth[key], "AWS4-HMAC-SHA256 Credential=minio/"), store.auth[key])
+}
+
+func TestHTTPPutSink(t *testing.T) {
+	t.Parallel()
+
+	store := newObjectStore()
+	srv := httptest.NewServer(store)
+	defer srv.Close()
+
+	sink, err := environment.NewArtifactSink(srv.URL + "/upload")
+	require.NoError(t, err)
+	err = environment.UploadArtifacts(context.Background(), sink, "run-1", writeArtifactsDir(t))
+	require.NoError(t, err)
+	require.Equal(t, "node logs", store.objects["/upload/run-1/test-artifacts/plugin-node_0/node.log"])
+}
+
+func TestSinkEmptyFile(t *testing.T) {
+	t.Parallel()
+
+	store := newObjectStore()
+	srv := httptest.NewServer(store)
+	defer srv.Close()
+
+	dir := writeArtifactsDir(t)
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "plugin-node_0", "empty #1.log"), nil, 0644))
+	s3, err := environment.NewArtifactSink("s3://artifacts?path_style=true&endpoint=" + srv.URL)
+	require.NoError(t, err)
+	httpPut, err := environment.NewArtifactSink(srv.URL + "/upload")
+	require.NoError(t, err)
+	for prefix, sink := range map[string]environment.ArtifactSink{"/artifacts": s3, "/upload": httpPut} {
+		require.NoError(t, environment.UploadArtifacts(context.Background(), sink, "run-1", dir))
+		key := prefix + "/run-1/test-artifacts/plugin-node_0/empty #1.log"
+		require.Contains(t, store.objects, key, "key must be path escaped")
+		require.Empty(t, store.objects[key])
+		require.Equal(t, "0", store.requests[key].Get("Content-Length"), sink.String())
+		require.Empty(t, store.requests[key]["Transfer-Encoding"], "empty files must not be sent chunked")
+	}
+}
+
+func TestLocalDirSink(t *testing.T) {
+	t.Parallel()
+
+	target := t.TempDir()
+	sink, err := environment.NewArtifactSink("file://" + target)
+	require.NoError(t, err)
+	err = environment.UploadArtifacts(context.Background(), sink, "run-1", writeArtifactsDir(t))
+	require.NoError(t, err)
+	b, err := os.ReadFile(filepath.Join(target, "run-1", "test-artifacts", "plugin-node_0", "node.log"))
+	require.NoError(t, err)
+	require.Equal(t, "node logs", string(b))
+}
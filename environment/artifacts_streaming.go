@@ -0,0 +1,411 @@
+package environment
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+	coreV1 "k8s.io/api/core/v1"
+	k8sErrors "k8s.io/apimachinery/pkg/api/errors"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/watch"
+)
+
+const (
+	// DefaultLogRotationSize size in bytes after which a streamed container log file is rotated
+	DefaultLogRotationSize int64 = 50 * 1024 * 1024
+	// DefaultLogRotationFiles amount of rotated log files kept for every streamed container
+	DefaultLogRotationFiles = 5
+	// logStreamRetryInterval interval between attempts to resume a finished or failed log stream
+	logStreamRetryInterval = 2 * time.Second
+)
+
+// logStreamer follows logs of every container in the namespace and writes them to disk
+type logStreamer struct {
+	artifacts *Artifacts
+	dir       string
+	cancel    context.CancelFunc
+	wg        sync.WaitGroup
+	mu        sync.Mutex
+	following map[string]bool
+	files     map[string]*logFile
+}
+
+// logFile log file shared by followers of the pod and container name, a pod recreated under the same name
+// writes to the file of the previous one, which is closed once both followers are done
+type logFile struct {
+	*rotatingFile
+	refs int
+}
+
+// StartStreaming starts following logs of every container in the environment namespace in the background,
+// pods created later are picked up as they appear. Logs are written to the dir as timestamped, rotated files
+// and streaming lasts until StopStreaming, Teardown is called or the process exits
+func (a *Artifacts) StartStreaming(dir string) error {
+	return a.StartStreamingContext(context.Background(), dir)
+}
+
+// StartStreamingContext same as StartStreaming, but streaming is also stopped when ctx is cancelled
+func (a *Artifacts) StartStreamingContext(ctx context.Context, dir string) error {
+	a.streamMu.Lock()
+	defer a.streamMu.Unlock()
+	if a.streamer != nil {
+		return fmt.Errorf("logs are already streaming to %s", a.streamer.dir)
+	}
+	if err := mkdirIfNotExists(dir); err != nil {
+		return err
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	s := &logStreamer{
+		artifacts: a,
+		dir:       dir,
+		cancel:    cancel,
+		following: map[string]bool{},
+		files:     map[string]*logFile{},
+	}
+	a.streamer = s
+	log.Info().
+		Str("Dir", dir).
+		Msg("Streaming environment logs")
+	s.wg.Add(1)
+	go s.watchPods(ctx)
+	return nil
+}
+
+// StopStreaming stops all log streams and waits until every log file is flushed and closed
+func (a *Artifacts) StopStreaming() {
+	a.streamMu.Lock()
+	s := a.streamer
+	a.streamer = nil
+	a.streamMu.Unlock()
+	if s == nil {
+		return
+	}
+	s.cancel()
+	s.wg.Wait()
+	log.Info().
+		Str("Dir", s.dir).
+		Msg("Stopped streaming environment logs")
+}
+
+// watchPods lists pods and then watches for changes, following every container that has started
+func (s *logStreamer) watchPods(ctx context.Context) {
+	defer s.wg.Done()
+	for {
+		podsList, err := s.artifacts.podsClient.List(ctx, metaV1.ListOptions{})
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			log.Warn().Err(err).Msg("Error listing pods to stream logs from, retrying")
+			if !sleepContext(ctx, logStreamRetryInterval) {
+				return
+			}
+			continue
+		}
+		for i := range podsList.Items {
+			s.followPod(ctx, &podsList.Items[i])
+		}
+		watcher, err := s.artifacts.podsClient.Watch(ctx, metaV1.ListOptions{
+			ResourceVersion: podsList.ResourceVersion,
+		})
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			log.Warn().Err(err).Msg("Error watching pods to stream logs from, retrying")
+			if !sleepContext(ctx, logStreamRetryInterval) {
+				return
+			}
+			continue
+		}
+		s.handleEvents(ctx, watcher)
+		watcher.Stop()
+		if ctx.Err() != nil {
+			return
+		}
+	}
+}
+
+func (s *logStreamer) handleEvents(ctx context.Context, watcher watch.Interface) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case event, ok := <-watcher.ResultChan():
+			if !ok {
+				return
+			}
+			if event.Type != watch.Added && event.Type != watch.Modified {
+				continue
+			}
+			pod, ok := event.Object.(*coreV1.Pod)
+			if !ok {
+				continue
+			}
+			s.followPod(ctx, pod)
+		}
+	}
+}
+
+// followPod starts a log follower for every started container of a pod that is not yet followed
+func (s *logStreamer) followPod(ctx context.Context, pod *coreV1.Pod) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, status := range pod.Status.ContainerStatuses {
+		if status.State.Running == nil && status.State.Terminated == nil {
+			continue
+		}
+		key := fmt.Sprintf("%s/%s", pod.UID, status.Name)
+		if s.following[key] {
+			continue
+		}
+		s.following[key] = true
+		s.wg.Add(1)
+		go s.followContainer(ctx, pod.Name, pod.UID, status.Name)
+	}
+}
+
+// followContainer follows container logs, resuming the stream when the container restarts,
+// until the pod is gone, recreated under the same name or streaming is stopped
+func (s *logStreamer) followContainer(ctx context.Context, podName string, uid types.UID, containerName string) {
+	defer s.wg.Done()
+	podDir := filepath.Join(s.dir, podName)
+	if err := mkdirIfNotExists(podDir); err != nil {
+		log.Error().Err(err).Str("Pod", podName).Msg("Error creating pod log directory")
+		return
+	}
+	path := filepath.Join(podDir, containerName) + ".log"
+	out := s.openLogFile(path)
+	defer func() {
+		if err := s.closeLogFile(path); err != nil {
+			log.Error().Err(err).Str("Pod", podName).Str("Container", containerName).Msg("Error closing log file")
+		}
+	}()
+	log.Debug().
+		Str("Pod", podName).
+		Str("Container", containerName).
+		Msg("Following container logs")
+	var lastSeen time.Time
+	for {
+		var err error
+		lastSeen, err = s.streamContainer(ctx, podName, containerName, lastSeen, out)
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			log.Debug().Err(err).Str("Pod", podName).Str("Container", containerName).Msg("Log stream interrupted")
+		}
+		pod, err := s.artifacts.podsClient.Get(ctx, podName, metaV1.GetOptions{})
+		// a recreated pod has its own follower
+		if k8sErrors.IsNotFound(err) || (err == nil && (pod.UID != uid || isPodFinished(pod))) {
+			return
+		}
+		if !sleepContext(ctx, logStreamRetryInterval) {
+			return
+		}
+	}
+}
+
+// openLogFile returns log file of the path, opened by another follower already or a new one
+func (s *logStreamer) openLogFile(path string) *rotatingFile {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	f, ok := s.files[path]
+	if !ok {
+		f = &logFile{rotatingFile: newRotatingFile(path, s.artifacts.LogRotationSize, s.artifacts.LogRotationFiles)}
+		s.files[path] = f
+	}
+	f.refs++
+	return f.rotatingFile
+}
+
+// closeLogFile closes log file of the path when the last follower writing to it is done
+func (s *logStreamer) closeLogFile(path string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	f := s.files[path]
+	f.refs--
+	if f.refs > 0 {
+		return nil
+	}
+	delete(s.files, path)
+	return f.Close()
+}
+
+// streamContainer copies a single log stream to out, skipping lines already written before the stream was resumed,
+// returns timestamp of the last written line
+func (s *logStreamer) streamContainer(
+	ctx context.Context,
+	podName, containerName string,
+	lastSeen time.Time,
+	out *rotatingFile,
+) (time.Time, error) {
+	opts := &coreV1.PodLogOptions{
+		Container:  containerName,
+		Follow:     true,
+		Timestamps: true,
+	}
+	if !lastSeen.IsZero() {
+		since := metaV1.NewTime(lastSeen)
+		opts.SinceTime = &since
+	}
+	stream, err := s.artifacts.podsClient.GetLogs(podName, opts).Stream(ctx)
+	if err != nil {
+		return lastSeen, err
+	}
+	defer stream.Close()
+	var redactor *Redactor
+	if s.artifacts.env != nil {
+		if redactor, err = s.artifacts.env.Config.Redactor(); err != nil {
+			return lastSeen, err
+		}
+	}
+	reader := bufio.NewReader(stream)
+	for {
+		line, err := reader.ReadString('\n')
+		if len(line) > 0 {
+			ts, ok := logLineTime(line)
+			if !ok || ts.After(lastSeen) {
+				if _, werr := out.Write([]byte(redactor.String(line))); werr != nil {
+					return lastSeen, werr
+				}
+				if ok {
+					lastSeen = ts
+				}
+			}
+		}
+		if err != nil {
+			return lastSeen, err
+		}
+	}
+}
+
+// logLineTime parses the timestamp k8s prepends to every log line when timestamps are requested
+func logLineTime(line string) (time.Time, bool) {
+	i := strings.IndexByte(line, ' ')
+	if i < 0 {
+		return time.Time{}, false
+	}
+	ts, err := time.Parse(time.RFC3339Nano, line[:i])
+	if err != nil {
+		return time.Time{}, false
+	}
+	return ts, true
+}
+
+func isPodFinished(pod *coreV1.Pod) bool {
+	return pod.DeletionTimestamp != nil ||
+		pod.Status.Phase == coreV1.PodSucceeded ||
+		pod.Status.Phase == coreV1.PodFailed
+}
+
+// sleepContext sleeps for d, returns false if ctx was cancelled meanwhile
+func sleepContext(ctx context.Context, d time.Duration) bool {
+	select {
+	case <-ctx.Done():
+		return false
+	case <-time.After(d):
+		return true
+	}
+}
+
+// rotatingFile is a log file writer that moves the file to path.1 ... path.N once it reaches max size,
+// it's safe for concurrent use
+type rotatingFile struct {
+	mu       sync.Mutex
+	path     string
+	maxSize  int64
+	maxFiles int
+	file     *os.File
+	size     int64
+}
+
+func newRotatingFile(path string, maxSize int64, maxFiles int) *rotatingFile {
+	if maxSize <= 0 {
+		maxSize = DefaultLogRotationSize
+	}
+	if maxFiles <= 0 {
+		maxFiles = DefaultLogRotationFiles
+	}
+	return &rotatingFile{path: path, maxSize: maxSize, maxFiles: maxFiles}
+}
+
+// Write writes p to the current file, rotating it beforehand if p doesn't fit
+func (r *rotatingFile) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.file != nil && r.size+int64(len(p)) > r.maxSize {
+		if err := r.rotate(); err != nil {
+			return 0, err
+		}
+	}
+	if r.file == nil {
+		if err := r.open(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := r.file.Write(p)
+	r.size += int64(n)
+	return n, err
+}
+
+// Close closes the current file
+func (r *rotatingFile) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.close()
+}
+
+func (r *rotatingFile) close() error {
+	if r.file == nil {
+		return nil
+	}
+	err := r.file.Close()
+	r.file = nil
+	return err
+}
+
+func (r *rotatingFile) open() error {
+	f, err := os.OpenFile(r.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	r.file = f
+	r.size = info.Size()
+	return nil
+}
+
+func (r *rotatingFile) rotate() error {
+	if err := r.close(); err != nil {
+		return err
+	}
+	for i := r.maxFiles - 1; i > 0; i-- {
+		from := fmt.Sprintf("%s.%d", r.path, i-1)
+		if i == 1 {
+			from = r.path
+		}
+		if _, err := os.Stat(from); err != nil {
+			continue
+		}
+		if err := os.Rename(from, fmt.Sprintf("%s.%d", r.path, i)); err != nil {
+			return errors.Wrapf(err, "failed to rotate log file %s", from)
+		}
+	}
+	if r.maxFiles == 1 {
+		return os.Remove(r.path)
+	}
+	return nil
+}
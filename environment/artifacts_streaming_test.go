@@ -0,0 +1,150 @@
+package environment
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	coreV1 "k8s.io/api/core/v1"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestStreamingFollowsPods(t *testing.T) {
+	t.Parallel()
+
+	client := fake.NewSimpleClientset()
+	pods := client.CoreV1().Pods("test")
+	a := &Artifacts{podsClient: pods}
+	dir := t.TempDir()
+
+	_, err := pods.Create(context.Background(), &coreV1.Pod{
+		ObjectMeta: metaV1.ObjectMeta{Name: "node-0", Namespace: "test", UID: "uid-0"},
+		Status: coreV1.PodStatus{
+			Phase: coreV1.PodRunning,
+			ContainerStatuses: []coreV1.ContainerStatus{
+				{Name: "node", State: coreV1.ContainerState{Running: &coreV1.ContainerStateRunning{}}},
+				{Name: "waiting", State: coreV1.ContainerState{Waiting: &coreV1.ContainerStateWaiting{}}},
+			},
+		},
+	}, metaV1.CreateOptions{})
+	require.NoError(t, err)
+	require.NoError(t, a.StartStreaming(dir))
+	require.Error(t, a.StartStreaming(dir), "streaming twice must fail")
+
+	logFile := filepath.Join(dir, "node-0", "node.log")
+	require.Eventually(t, func() bool {
+		b, err := os.ReadFile(logFile)
+		return err == nil && len(b) > 0
+	}, 10*time.Second, 50*time.Millisecond)
+	a.StopStreaming()
+
+	_, err = os.Stat(filepath.Join(dir, "node-0", "waiting.log"))
+	require.True(t, os.IsNotExist(err), "containers that haven't started must not be followed")
+}
+
+func TestStreamingRecreatedPod(t *testing.T) {
+	t.Parallel()
+
+	client := fake.NewSimpleClientset()
+	pods := client.CoreV1().Pods("test")
+	pod := &coreV1.Pod{
+		ObjectMeta: metaV1.ObjectMeta{Name: "node-0", Namespace: "test", UID: "uid-0"},
+		Status:     coreV1.PodStatus{Phase: coreV1.PodRunning},
+	}
+	_, err := pods.Create(context.Background(), pod, metaV1.CreateOptions{})
+	require.NoError(t, err)
+	s := &logStreamer{artifacts: &Artifacts{podsClient: pods}, dir: t.TempDir(), files: map[string]*logFile{}}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	s.wg.Add(1)
+	go s.followContainer(ctx, "node-0", "uid-0", "node")
+
+	// the statefulset recreates the pod under the same name
+	require.NoError(t, pods.Delete(context.Background(), "node-0", metaV1.DeleteOptions{}))
+	pod.UID = "uid-1"
+	_, err = pods.Create(context.Background(), pod, metaV1.CreateOptions{})
+	require.NoError(t, err)
+
+	done := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		t.Fatal("follower of the previous pod must stop")
+	}
+}
+
+func TestStreamingSharedLogFile(t *testing.T) {
+	t.Parallel()
+
+	s := &logStreamer{artifacts: &Artifacts{}, dir: t.TempDir(), files: map[string]*logFile{}}
+	path := filepath.Join(s.dir, "node.log")
+	// followers of the previous and the recreated pod write to the same file
+	outs := []*rotatingFile{s.openLogFile(path), s.openLogFile(path)}
+	require.Same(t, outs[0], outs[1])
+	var wg sync.WaitGroup
+	for i, out := range outs {
+		wg.Add(1)
+		go func(line string, out *rotatingFile) {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				_, err := out.Write([]byte(line))
+				require.NoError(t, err)
+			}
+		}(fmt.Sprintf("pod %d\n", i), out)
+	}
+	wg.Wait()
+	require.NoError(t, s.closeLogFile(path))
+	_, err := outs[1].Write([]byte("pod 1\n"))
+	require.NoError(t, err, "file must stay open until the last follower is done")
+	require.NoError(t, s.closeLogFile(path))
+	require.Empty(t, s.files)
+
+	b, err := os.ReadFile(path)
+	require.NoError(t, err)
+	lines := strings.Split(strings.TrimSpace(string(b)), "\n")
+	require.Len(t, lines, 201)
+	for _, line := range lines {
+		require.Contains(t, []string{"pod 0", "pod 1"}, line)
+	}
+}
+
+func TestRotatingFile(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "node.log")
+	r := newRotatingFile(path, 10, 3)
+	for _, line := range []string{"aaaaaaaa\n", "bbbbbbbb\n", "cccccccc\n", "dddddddd\n"} {
+		_, err := r.Write([]byte(line))
+		require.NoError(t, err)
+	}
+	require.NoError(t, r.Close())
+
+	for suffix, expected := range map[string]string{"": "dddddddd\n", ".1": "cccccccc\n", ".2": "bbbbbbbb\n"} {
+		b, err := os.ReadFile(path + suffix)
+		require.NoError(t, err)
+		require.Equal(t, expected, string(b))
+	}
+	_, err := os.Stat(path + ".3")
+	require.True(t, os.IsNotExist(err), "only maxFiles files must be kept")
+}
+
+func TestLogLineTime(t *testing.T) {
+	t.Parallel()
+
+	ts, ok := logLineTime("2022-06-01T10:00:00.123456789Z some log line\n")
+	require.True(t, ok)
+	require.Equal(t, 123456789, ts.Nanosecond())
+	_, ok = logLineTime("no timestamp here\n")
+	require.False(t, ok)
+}
@@ -1,12 +1,29 @@
 package environment
 
-import "github.com/goplugin/helmenv/chaos"
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
 
-// ClearAllChaosStandaloneExperiments remove all chaos experiments from a standalone env
+	"github.com/goplugin/helmenv/chaos"
+	"github.com/goplugin/helmenv/chaos/experiments"
+	"github.com/rs/zerolog/log"
+)
+
+// ChaosBackendChaosMesh chaos backend applying experiments as Chaosmesh resources, the default
+const ChaosBackendChaosMesh = "chaosmesh"
+
+// ClearAllChaosStandaloneExperiments remove all chaos experiments from a standalone env, including
+// experiments created by helmenv which are missing in expInfos
 func (k *Environment) ClearAllChaosStandaloneExperiments(expInfos map[string]*chaos.ExperimentInfo) error {
 	if err := k.Chaos.StopAllStandalone(expInfos); err != nil {
 		return err
 	}
+	if err := k.Chaos.StopAll(); err != nil {
+		return err
+	}
 	k.Config.Experiments = nil
 	if err := k.SyncConfig(); err != nil {
 		return err
@@ -19,7 +36,7 @@ func (k *Environment) StopChaosStandaloneExperiment(expInfo *chaos.ExperimentInf
 	if err := k.Chaos.StopStandalone(expInfo); err != nil {
 		return err
 	}
-	k.Config.Experiments[expInfo.Name] = nil
+	delete(k.Config.Experiments, expInfo.Name)
 	if len(k.Config.Experiments) == 0 {
 		k.Config.Experiments = nil
 	}
@@ -31,7 +48,16 @@ func (k *Environment) StopChaosStandaloneExperiment(expInfo *chaos.ExperimentInf
 
 // ApplyChaosExperimentFromTemplate applies experiment to a standalone env
 func (k *Environment) ApplyChaosExperimentFromTemplate(tmplPath string) error {
-	expInfo, err := k.Chaos.RunTemplate(tmplPath)
+	return k.ApplyChaosExperimentFromTemplateOn(tmplPath, nil)
+}
+
+// ApplyChaosExperimentFromTemplateOn applies experiment to a standalone env, template selector is filled in
+// from the target, see ChaosTarget
+func (k *Environment) ApplyChaosExperimentFromTemplateOn(tmplPath string, target *experiments.Selector) error {
+	if err := k.chaosPreflight(); err != nil {
+		return err
+	}
+	expInfo, err := k.Chaos.RunTemplateOn(tmplPath, target)
 	if err != nil {
 		return err
 	}
@@ -42,27 +68,290 @@ func (k *Environment) ApplyChaosExperimentFromTemplate(tmplPath string) error {
 	return nil
 }
 
-// ApplyChaosExperiment applies experiment to an ephemeral env
-func (k *Environment) ApplyChaosExperiment(exp chaos.Experimentable) (string, error) {
+// ApplyChaosExperiment applies experiment to an ephemeral env, before probes failure prevents the experiment,
+// during probes failure is returned with the name of the running experiment, after probes run when it's stopped
+func (k *Environment) ApplyChaosExperiment(exp chaos.Experimentable, probes ...ChaosProbes) (string, error) {
+	var p ChaosProbes
+	for _, hooks := range probes {
+		p.Before = append(p.Before, hooks.Before...)
+		p.During = append(p.During, hooks.During...)
+		p.After = append(p.After, hooks.After...)
+		if hooks.InjectTimeout > p.InjectTimeout {
+			p.InjectTimeout = hooks.InjectTimeout
+		}
+	}
+	if err := k.chaosPreflight(); err != nil {
+		return "", err
+	}
+	results, err := k.RunProbes(context.Background(), ProbeBefore, p.Before...)
+	if err != nil {
+		return "", err
+	}
 	chaosName, err := k.Chaos.Run(exp)
 	if err != nil {
 		return chaosName, err
 	}
-	return chaosName, nil
+	k.addProbeResults(chaosName, results)
+	if len(p.After) > 0 {
+		if k.chaosProbes == nil {
+			k.chaosProbes = map[string]ChaosProbes{}
+		}
+		k.chaosProbes[chaosName] = p
+	}
+	if len(p.During) == 0 {
+		return chaosName, nil
+	}
+	if p.InjectTimeout > 0 {
+		if _, err := k.Chaos.WaitInjected(chaosName, p.InjectTimeout); err != nil {
+			return chaosName, err
+		}
+	}
+	results, err = k.RunProbes(context.Background(), ProbeDuring, p.During...)
+	k.addProbeResults(chaosName, results)
+	return chaosName, err
 }
 
-// StopChaosExperiment stops experiment in a ephemeral env
+// StopChaosExperiment stops experiment in a ephemeral env and runs its after probes
 func (k *Environment) StopChaosExperiment(id string) error {
 	if err := k.Chaos.Stop(id); err != nil {
 		return err
 	}
-	return nil
+	return k.runAfterProbes(id)
 }
 
-// ClearAllChaosExperiments clears all chaos experiments
+// ClearAllChaosExperiments clears all chaos experiments and runs their after probes
 func (k *Environment) ClearAllChaosExperiments() error {
 	if err := k.Chaos.StopAll(); err != nil {
 		return err
 	}
+	var probeErr error
+	for id := range k.chaosProbes {
+		if err := k.runAfterProbes(id); err != nil && probeErr == nil {
+			probeErr = err
+		}
+	}
+	return probeErr
+}
+
+// ChaosProbeResults returns results of the probes run around the experiment
+func (k *Environment) ChaosProbeResults(id string) []*ProbeResult {
+	return k.probeResults[id]
+}
+
+func (k *Environment) runAfterProbes(id string) error {
+	p, ok := k.chaosProbes[id]
+	if !ok {
+		return nil
+	}
+	delete(k.chaosProbes, id)
+	results, err := k.RunProbes(context.Background(), ProbeAfter, p.After...)
+	k.addProbeResults(id, results)
+	return err
+}
+
+func (k *Environment) addProbeResults(id string, results []*ProbeResult) {
+	if len(results) == 0 {
+		return
+	}
+	if k.probeResults == nil {
+		k.probeResults = map[string][]*ProbeResult{}
+	}
+	k.probeResults[id] = append(k.probeResults[id], results...)
+}
+
+// RunChaosScenario runs chaos scenario, experiments applied by the scenario are stopped when it ends,
+// declarative checks are run as probes of the environment, see ScenarioCheckProbe
+func (k *Environment) RunChaosScenario(ctx context.Context, s *chaos.Scenario) (*chaos.ScenarioResult, error) {
+	if err := k.chaosPreflight(); err != nil {
+		return nil, err
+	}
+	if err := s.Validate(); err != nil {
+		return nil, err
+	}
+	k.resolveScenarioChecks(s)
+	return k.Chaos.RunScenario(ctx, s)
+}
+
+// resolveScenarioChecks sets Func of declarative scenario checks to run their probes
+func (k *Environment) resolveScenarioChecks(s *chaos.Scenario) {
+	for _, c := range s.Checks {
+		if c.Func != nil {
+			continue
+		}
+		if p := ScenarioCheckProbe(c); p != nil {
+			c.Func = k.ProbeCheck(p)
+		}
+	}
+}
+
+// ListChaosExperiments lists chaos experiments created by helmenv in the namespace by any process
+func (k *Environment) ListChaosExperiments() ([]*chaos.ExperimentInfo, error) {
+	return k.Chaos.List()
+}
+
+// RunChaosWatchdog removes chaos experiments older than maxDuration in the namespace every interval until ctx is done,
+// configured ChaosMaxDuration is used when maxDuration is zero
+func (k *Environment) RunChaosWatchdog(ctx context.Context, maxDuration, interval time.Duration) error {
+	if maxDuration > 0 {
+		k.Chaos.Cfg.MaxDuration = maxDuration
+	}
+	if err := k.Chaos.Watchdog(ctx, interval); err != nil {
+		return err
+	}
+	log.Info().
+		Str("Namespace", k.Namespace).
+		Dur("MaxDuration", k.Chaos.Cfg.MaxDuration).
+		Msg("Watching chaos experiments")
+	<-ctx.Done()
 	return nil
 }
+
+// RenderChaosTemplate renders chaos template as it's applied to the environment of the config, targeting app instances
+// of the chart when it's set, see ChaosTarget, the cluster isn't contacted
+func RenderChaosTemplate(config *Config, tmplPath, chart, app string, instances ...int) ([]byte, error) {
+	cc, err := chaos.NewController(&chaos.Config{
+		NamespaceName: config.Namespace,
+		Templates:     config.ChaosTemplates,
+		RunID:         config.ChaosRunID,
+	})
+	if err != nil {
+		return nil, err
+	}
+	var target *experiments.Selector
+	if len(chart) > 0 {
+		// presets which aren't deployed yet have no release names
+		for key, hc := range config.Charts {
+			if len(hc.ReleaseName) == 0 {
+				hc.ReleaseName = key
+			}
+		}
+		env := &Environment{Config: config}
+		if target, err = env.ChaosTarget(chart, app, instances...); err != nil {
+			return nil, err
+		}
+	}
+	return cc.RenderTemplate(tmplPath, target)
+}
+
+// FindChaosExperiment finds experiment applied to a standalone env, experiments missing in the config
+// are discovered in the cluster
+func (k *Environment) FindChaosExperiment(name string) (*chaos.ExperimentInfo, error) {
+	if expInfo, ok := k.Config.Experiments[name]; ok && expInfo != nil {
+		return expInfo, nil
+	}
+	infos, err := k.Chaos.List()
+	if err != nil {
+		return nil, err
+	}
+	for _, info := range infos {
+		if info.Name == name {
+			return info, nil
+		}
+	}
+	return nil, fmt.Errorf("experiment with id %s not found", name)
+}
+
+// ChaosExperimentStatus returns experiment status, including experiments applied from templates to a standalone env
+// or by another process
+func (k *Environment) ChaosExperimentStatus(name string) (*chaos.ExperimentStatus, error) {
+	if _, ok := k.Chaos.Experiment(name); ok {
+		return k.Chaos.Status(name)
+	}
+	expInfo, err := k.FindChaosExperiment(name)
+	if err != nil {
+		return nil, err
+	}
+	return k.Chaos.StatusOf(expInfo)
+}
+
+// PauseChaosExperiment pauses experiment, including experiments applied to a standalone env or by another process
+func (k *Environment) PauseChaosExperiment(name string) error {
+	if _, ok := k.Chaos.Experiment(name); ok {
+		return k.Chaos.Pause(name)
+	}
+	expInfo, err := k.FindChaosExperiment(name)
+	if err != nil {
+		return err
+	}
+	return k.Chaos.PauseOf(expInfo)
+}
+
+// ResumeChaosExperiment resumes paused experiment
+func (k *Environment) ResumeChaosExperiment(name string) error {
+	if _, ok := k.Chaos.Experiment(name); ok {
+		return k.Chaos.Resume(name)
+	}
+	expInfo, err := k.FindChaosExperiment(name)
+	if err != nil {
+		return err
+	}
+	return k.Chaos.ResumeOf(expInfo)
+}
+
+// ChaosTarget returns selector of every pod of the chart app, or only of its instances, pods are selected by
+// ReleaseLabelKey helmenv adds to pods of every chart, app and instance labels, app can be empty to select instances
+// of every chart app
+func (k *Environment) ChaosTarget(chart, app string, instances ...int) (*experiments.Selector, error) {
+	hc, err := k.Charts.Get(chart)
+	if err != nil {
+		return nil, err
+	}
+	sel := &experiments.Selector{
+		Mode:   experiments.ModeAll,
+		Labels: releaseLabels(hc.ReleaseName, app),
+	}
+	if len(instances) == 0 {
+		return sel, nil
+	}
+	values := make([]string, 0, len(instances))
+	for _, i := range instances {
+		instance := strconv.Itoa(i)
+		if len(app) > 0 && len(hc.ChartConnections) > 0 && !hasInstance(hc.ChartConnections, app, instance) {
+			return nil, fmt.Errorf("instance %s of app %s not found in chart %s", instance, app, chart)
+		}
+		values = append(values, instance)
+	}
+	sel.Expressions = []experiments.Expression{{
+		Key:      InstanceEnumerationLabelKey,
+		Operator: experiments.OpIn,
+		Values:   values,
+	}}
+	return sel, nil
+}
+
+// ChaosTargetConnections returns selector of pods by chart connection keys, for example plugin-node_2_node
+func (k *Environment) ChaosTargetConnections(chart string, keys ...string) (*experiments.Selector, error) {
+	hc, err := k.Charts.Get(chart)
+	if err != nil {
+		return nil, err
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("no connections of chart %s to target", chart)
+	}
+	pods := make([]string, 0, len(keys))
+	seen := map[string]bool{}
+	for _, key := range keys {
+		cc, ok := hc.ChartConnections[key]
+		if !ok {
+			return nil, fmt.Errorf("chart connection %s not found in chart %s", key, chart)
+		}
+		if !seen[cc.PodName] {
+			seen[cc.PodName] = true
+			pods = append(pods, cc.PodName)
+		}
+	}
+	return &experiments.Selector{
+		Mode: experiments.ModeAll,
+		Pods: map[string][]string{k.Config.Namespace: pods},
+	}, nil
+}
+
+func hasInstance(connections ChartConnections, app, instance string) bool {
+	prefix := fmt.Sprintf("%s_%s_", app, instance)
+	for key := range connections {
+		if strings.HasPrefix(key, prefix) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,107 @@
+package environment
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/goplugin/helmenv/chaos"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+	v1 "k8s.io/api/core/v1"
+	k8sErrors "k8s.io/apimachinery/pkg/api/errors"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// DefaultChaosMeshNamespace namespace Chaos Mesh is installed into
+	DefaultChaosMeshNamespace = "chaos-mesh"
+	// DefaultChaosMeshReleaseName Chaos Mesh release name
+	DefaultChaosMeshReleaseName = "chaos-mesh"
+)
+
+// ChaosMeshConfig installs Chaos Mesh chart into a dedicated namespace before the first experiment if it's missing,
+// the release is shared by environments and isn't removed on teardown
+type ChaosMeshConfig struct {
+	Install     bool   `yaml:"install,omitempty" json:"install,omitempty" envconfig:"install"`
+	Namespace   string `yaml:"namespace,omitempty" json:"namespace,omitempty" envconfig:"namespace"`
+	ReleaseName string `yaml:"release_name,omitempty" json:"release_name,omitempty" envconfig:"release_name"`
+	// Path local Chaos Mesh chart, for example pulled with helm pull chaos-mesh/chaos-mesh --untar
+	Path string `yaml:"path,omitempty" json:"path,omitempty" envconfig:"path"`
+	// URL Chaos Mesh chart archive to download if Path is not set
+	URL string `yaml:"url,omitempty" json:"url,omitempty" envconfig:"url"`
+	// Values chart values, for example chaosDaemon.runtime and chaosDaemon.socketPath of the cluster container runtime
+	Values map[string]interface{} `yaml:"values,omitempty" json:"values,omitempty" ignored:"true"`
+}
+
+// ChaosPreflight checks which chaos kinds the cluster supports and Chaos Mesh version, Chaos Mesh is installed
+// first if it's missing and ChaosMesh.Install is set, experiments of unsupported kinds are rejected afterwards
+func (k *Environment) ChaosPreflight() (*chaos.Support, error) {
+	s, err := k.Chaos.Preflight()
+	if err == nil || !errors.Is(err, chaos.ErrCRDNotInstalled) || !k.Config.ChaosMesh.Install {
+		return s, err
+	}
+	if err := k.installChaosMesh(); err != nil {
+		return nil, errors.Wrap(err, "failed to install Chaos Mesh")
+	}
+	return k.Chaos.Preflight()
+}
+
+// chaosPreflight runs preflight once before the first experiment applied with the Chaos Mesh backend
+func (k *Environment) chaosPreflight() error {
+	if _, ok := k.Chaos.Backend.(*chaos.ChaosMeshBackend); !ok || k.Chaos.Support != nil || k.Chaos.Client == nil {
+		return nil
+	}
+	s, err := k.ChaosPreflight()
+	if err != nil {
+		return err
+	}
+	log.Info().
+		Str("Version", s.Version).
+		Strs("Kinds", s.KindNames()).
+		Msg("Chaos Mesh preflight passed")
+	return nil
+}
+
+func (k *Environment) installChaosMesh() error {
+	cfg := k.Config.ChaosMesh
+	if len(cfg.Path) == 0 && len(cfg.URL) == 0 {
+		return fmt.Errorf("chaos_mesh.install requires chart path or url")
+	}
+	namespace := cfg.Namespace
+	if len(namespace) == 0 {
+		namespace = DefaultChaosMeshNamespace
+	}
+	releaseName := cfg.ReleaseName
+	if len(releaseName) == 0 {
+		releaseName = DefaultChaosMeshReleaseName
+	}
+	_, err := k.k8sClient.CoreV1().Namespaces().Get(context.Background(), namespace, metaV1.GetOptions{})
+	if k8sErrors.IsNotFound(err) {
+		log.Info().Str("Namespace", namespace).Msg("Creating Chaos Mesh namespace")
+		_, err = k.k8sClient.CoreV1().Namespaces().Create(
+			context.Background(),
+			&v1.Namespace{ObjectMeta: metaV1.ObjectMeta{Name: namespace}},
+			metaV1.CreateOptions{},
+		)
+	}
+	if err != nil {
+		return err
+	}
+	hc := &HelmChart{
+		ReleaseName:   releaseName,
+		Path:          cfg.Path,
+		URL:           cfg.URL,
+		Values:        cfg.Values,
+		namespaceName: namespace,
+		env:           k,
+	}
+	if err := hc.init(); err != nil {
+		return err
+	}
+	if len(hc.Path) == 0 {
+		if err := hc.downloadChart(); err != nil {
+			return err
+		}
+	}
+	return hc.deployChart()
+}
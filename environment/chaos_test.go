@@ -0,0 +1,88 @@
+package environment
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/goplugin/helmenv/chaos/experiments"
+	"github.com/stretchr/testify/require"
+)
+
+func chaosTargetEnv() *Environment {
+	return &Environment{Config: &Config{
+		Namespace: "env",
+		Charts: Charts{
+			"plugin": &HelmChart{
+				ReleaseName: "plugin",
+				ChartConnections: ChartConnections{
+					"plugin-node_0_node":      {PodName: "plugin-node-0"},
+					"plugin-node_0_plugin-db": {PodName: "plugin-node-0"},
+					"plugin-node_1_node":      {PodName: "plugin-node-1"},
+					"plugin-node_2_node":      {PodName: "plugin-node-2"},
+				},
+			},
+		},
+	}}
+}
+
+func TestChaosTarget(t *testing.T) {
+	t.Parallel()
+
+	env := chaosTargetEnv()
+	sel, err := env.ChaosTarget("plugin", "plugin-node", 0, 2)
+	require.NoError(t, err)
+	require.Equal(t, &experiments.Selector{
+		Mode:   experiments.ModeAll,
+		Labels: map[string]string{ReleaseLabelKey: "plugin", "app": "plugin-node"},
+		Expressions: []experiments.Expression{
+			{Key: "instance", Operator: experiments.OpIn, Values: []string{"0", "2"}},
+		},
+	}, sel)
+
+	sel, err = env.ChaosTarget("plugin", "")
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{ReleaseLabelKey: "plugin"}, sel.Labels)
+	require.Empty(t, sel.Expressions)
+
+	_, err = env.ChaosTarget("plugin", "plugin-node", 3)
+	require.EqualError(t, err, "instance 3 of app plugin-node not found in chart plugin")
+	_, err = env.ChaosTarget("geth", "geth")
+	require.Error(t, err)
+}
+
+func TestChaosTargetConnections(t *testing.T) {
+	t.Parallel()
+
+	env := chaosTargetEnv()
+	sel, err := env.ChaosTargetConnections("plugin", "plugin-node_0_node", "plugin-node_0_plugin-db", "plugin-node_2_node")
+	require.NoError(t, err)
+	require.Equal(t, map[string][]string{"env": {"plugin-node-0", "plugin-node-2"}}, sel.Pods)
+
+	_, err = env.ChaosTargetConnections("plugin", "plugin-node_5_node")
+	require.Error(t, err)
+}
+
+func TestRenderChaosTemplate(t *testing.T) {
+	t.Parallel()
+
+	config := chaosTargetEnv().Config
+	config.Charts["geth"] = &HelmChart{}
+	data, err := RenderChaosTemplate(config, "../examples/chaos/schedule-pod-kill.yml", "geth", "geth")
+	require.NoError(t, err)
+	var crd struct {
+		Metadata struct {
+			Namespace string `json:"namespace"`
+		} `json:"metadata"`
+		Spec struct {
+			PodChaos struct {
+				Selector experiments.SelectorSpec `json:"selector"`
+			} `json:"podChaos"`
+		} `json:"spec"`
+	}
+	require.NoError(t, json.Unmarshal(data, &crd))
+	require.Equal(t, "env", crd.Metadata.Namespace)
+	require.Equal(t, map[string]string{ReleaseLabelKey: "geth", "app": "geth"}, crd.Spec.PodChaos.Selector.LabelSelectors)
+
+	_, err = RenderChaosTemplate(config, "../examples/chaos/schedule-pod-kill.yml", "plugin", "plugin-node", 5)
+	require.Error(t, err)
+}
@@ -3,10 +3,12 @@ package environment
 import (
 	"errors"
 	"fmt"
+	"io/fs"
 	"io/ioutil"
 	"os"
 	"path/filepath"
 	"sort"
+	"strings"
 	"time"
 
 	"github.com/imdario/mergo"
@@ -53,6 +55,26 @@ func (d *MarshalSafeDuration) UnmarshalJSON(b []byte) error {
 	}
 }
 
+// UnmarshalYAML unmarshals durations from yaml strings such as 10m, or from nanoseconds
+func (d *MarshalSafeDuration) UnmarshalYAML(value *yaml.Node) error {
+	var ns int64
+	if err := value.Decode(&ns); err == nil {
+		*d = MarshalSafeDuration(ns)
+		return nil
+	}
+	return d.Decode(value.Value)
+}
+
+// Decode decodes durations from environment variables, see envconfig.Decoder
+func (d *MarshalSafeDuration) Decode(value string) error {
+	tmp, err := time.ParseDuration(value)
+	if err != nil {
+		return err
+	}
+	*d = MarshalSafeDuration(tmp)
+	return nil
+}
+
 func unmarshalYAML(path string, to interface{}) error {
 	ap, err := filepath.Abs(path)
 	if err != nil {
@@ -75,10 +97,59 @@ type Config struct {
 	MarshalSafeTimeout MarshalSafeDuration              `yaml:"timeout" json:"timeout" ignored:"true" default:"3m"`
 	Timeout            time.Duration                    `yaml:"-" json:"-" envconfig:"timeout" default:"3m"`
 	Persistent         bool                             `yaml:"persistent" json:"persistent" envconfig:"persistent"`
+	Preset             string                           `yaml:"preset,omitempty" json:"preset,omitempty" envconfig:"preset"`
 	NamespacePrefix    string                           `yaml:"namespace_prefix,omitempty" json:"namespace_prefix,omitempty" envconfig:"namespace_prefix"`
 	Namespace          string                           `yaml:"namespace,omitempty" json:"namespace,omitempty" envconfig:"namespace"`
 	Charts             Charts                           `yaml:"charts,omitempty" json:"charts,omitempty" envconfig:"charts"`
 	Experiments        map[string]*chaos.ExperimentInfo `yaml:"experiments,omitempty" json:"experiments,omitempty" envconfig:"experiments"`
+	ChaosRunID         string                           `yaml:"chaos_run_id,omitempty" json:"chaos_run_id,omitempty" envconfig:"chaos_run_id"`
+	ChaosBackend       string                           `yaml:"chaos_backend,omitempty" json:"chaos_backend,omitempty" envconfig:"chaos_backend"`
+	ChaosPodFailure    string                           `yaml:"chaos_pod_failure,omitempty" json:"chaos_pod_failure,omitempty" envconfig:"chaos_pod_failure"`
+	ChaosMesh          ChaosMeshConfig                  `yaml:"chaos_mesh,omitempty" json:"chaos_mesh,omitempty" envconfig:"chaos_mesh"`
+	ChaosMaxDuration   MarshalSafeDuration              `yaml:"chaos_max_duration,omitempty" json:"chaos_max_duration,omitempty" envconfig:"chaos_max_duration"`
+	ArtifactSinks      []string                         `yaml:"artifact_sinks,omitempty" json:"artifact_sinks,omitempty" envconfig:"artifact_sinks"`
+	ArtifactSinkPrefix string                           `yaml:"artifact_sink_prefix,omitempty" json:"artifact_sink_prefix,omitempty" envconfig:"artifact_sink_prefix"`
+	RedactKeys         []string                         `yaml:"redact_keys,omitempty" json:"redact_keys,omitempty" envconfig:"redact_keys"`
+	RedactPatterns     []string                         `yaml:"redact_patterns,omitempty" json:"redact_patterns,omitempty" envconfig:"redact_patterns"`
+	DisableRedaction   bool                             `yaml:"disable_redaction,omitempty" json:"disable_redaction,omitempty" envconfig:"disable_redaction"`
+	// ChaosTemplates extra chaos templates, overriding embedded ones with the same file names
+	ChaosTemplates fs.FS `yaml:"-" json:"-" ignored:"true"`
+
+	redactor *Redactor
+}
+
+// Redactor returns the redactor for secrets in logs, persisted config and artifacts,
+// default keys and patterns are extended with RedactKeys and RedactPatterns
+func (m *Config) Redactor() (*Redactor, error) {
+	if m.redactor != nil {
+		return m.redactor, nil
+	}
+	r, err := NewRedactor(m.RedactKeys, m.RedactPatterns, m.DisableRedaction)
+	if err != nil {
+		return nil, err
+	}
+	m.redactor = r
+	return r, nil
+}
+
+// redacted returns a copy of the config with redacted chart values to be persisted, values of the config
+// are kept as they are, so the environment can still be upgraded with them
+func (m *Config) redacted() (*Config, error) {
+	r, err := m.Redactor()
+	if err != nil {
+		return nil, err
+	}
+	if !r.Enabled() {
+		return m, nil
+	}
+	c := *m
+	c.Charts = make(Charts, len(m.Charts))
+	for name, chart := range m.Charts {
+		redactedChart := *chart
+		redactedChart.Values = r.Map(chart.Values)
+		c.Charts[name] = &redactedChart
+	}
+	return &c, nil
 }
 
 // ToJSON marshals the config to JSON
@@ -187,8 +258,12 @@ func (c Charts) OrderedKeys() [][]string {
 	return keys
 }
 
-// DumpConfig dumps config to a yaml file
+// DumpConfig dumps config to a yaml file, secrets in chart values are redacted
 func DumpConfig(cfg *Config, path string) error {
+	cfg, err := cfg.redacted()
+	if err != nil {
+		return err
+	}
 	f, err := os.Create(path)
 	if err != nil {
 		return err
@@ -204,8 +279,12 @@ func DumpConfig(cfg *Config, path string) error {
 	return nil
 }
 
-// DumpConfigJson dumps config to a json file
+// DumpConfigJson dumps config to a json file, secrets in chart values are redacted
 func DumpConfigJson(cfg *Config, path string) error {
+	cfg, err := cfg.redacted()
+	if err != nil {
+		return err
+	}
 	f, err := os.Create(path)
 	if err != nil {
 		return err
@@ -234,6 +313,31 @@ func DeployOrLoadEnvironment(config *Config) (*Environment, error) {
 
 // DeployOrLoadEnvironmentFromConfigFile returns an environment based on a preset file, mostly for use as a presets CLI
 func DeployOrLoadEnvironmentFromConfigFile(configFilePath string) (*Environment, error) {
+	config, err := ReadConfigFile(configFilePath)
+	if err != nil {
+		return nil, err
+	}
+	return deployOrLoadEnvironment(config)
+}
+
+// LoadEnvironmentFromConfigFile loads an environment deployed from a preset file, unlike
+// DeployOrLoadEnvironmentFromConfigFile it never deploys one
+func LoadEnvironmentFromConfigFile(configFilePath string) (*Environment, error) {
+	config, err := ReadConfigFile(configFilePath)
+	if err != nil {
+		return nil, err
+	}
+	if err := envconfig.Process("", config); err != nil {
+		return nil, err
+	}
+	if len(config.Namespace) == 0 {
+		return nil, fmt.Errorf("environment of %s is not deployed", configFilePath)
+	}
+	return LoadEnvironment(config)
+}
+
+// ReadConfigFile reads yaml or json environment config file without contacting the cluster
+func ReadConfigFile(configFilePath string) (*Config, error) {
 	contents, err := os.ReadFile(configFilePath)
 	if err != nil {
 		return nil, err
@@ -260,11 +364,14 @@ func DeployOrLoadEnvironmentFromConfigFile(configFilePath string) (*Environment,
 	}
 
 	config.Path = configFilePath
+	if len(config.Preset) == 0 {
+		config.Preset = strings.TrimSuffix(filepath.Base(configFilePath), configFileExt)
+	}
 	config.Timeout = config.MarshalSafeTimeout.AsTimeDuration()
 	// Always set to true when loading from file as the environment state would be lost on deployment since if false
 	// config isn't written to disk
 	config.Persistent = true
-	return deployOrLoadEnvironment(config)
+	return config, nil
 }
 
 func deployOrLoadEnvironment(config *Config) (*Environment, error) {
@@ -22,6 +22,7 @@ func NewPluginChart(index int, values map[string]interface{}) *HelmChart {
 // NewPluginCCIPReorgConfig returns a Plugin environment for the purpose of CCIP testing
 func NewPluginCCIPReorgConfig(pluginValues map[string]interface{}, networkIDs []int) *Config {
 	return &Config{
+		Preset:          "plugin-ccip-reorg",
 		NamespacePrefix: "plugin-ccip",
 		Charts: Charts{
 			"geth-reorg": {
@@ -56,6 +57,7 @@ func NewPluginCCIPReorgConfig(pluginValues map[string]interface{}, networkIDs []
 // NewTerraPluginConfig returns a Plugin environment designed for testing with a Terra relay
 func NewTerraPluginConfig(pluginValues map[string]interface{}) *Config {
 	return &Config{
+		Preset:          "plugin-terra",
 		NamespacePrefix: "plugin-terra",
 		Charts: Charts{
 			"localterra": {Index: 1},
@@ -68,6 +70,7 @@ func NewTerraPluginConfig(pluginValues map[string]interface{}) *Config {
 // NewPluginReorgConfig returns a Plugin environment designed for simulating re-orgs within testing
 func NewPluginReorgConfig(pluginValues map[string]interface{}) *Config {
 	return &Config{
+		Preset:          "plugin-reorg",
 		NamespacePrefix: "plugin-reorg",
 		Charts: Charts{
 			"geth-reorg": {Index: 1},
@@ -98,6 +101,7 @@ func NewPluginConfig(
 	nameSpacePrefix := loadNetworkCharts(optionalNamespacePrefix, charts, networks)
 
 	return &Config{
+		Preset:          "plugin",
 		NamespacePrefix: nameSpacePrefix,
 		Charts:          charts,
 	}
@@ -160,6 +164,7 @@ func NewPerformancePluginConfig(
 	nameSpacePrefix := loadNetworkCharts(optionalNamespacePrefix, charts, networks)
 
 	return &Config{
+		Preset:          "plugin-performance",
 		NamespacePrefix: nameSpacePrefix,
 		Charts:          charts,
 	}
@@ -3,10 +3,13 @@ package environment_test
 import (
 	"fmt"
 	"os"
+	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/goplugin/helmenv/environment"
 	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
 )
 
 func TestChartsFile(t *testing.T) {
@@ -57,3 +60,48 @@ func TestChartsFile(t *testing.T) {
 	err = pluginConfig.Charts.Decode(chartsTestFilePath)
 	require.NoError(t, err)
 }
+
+func TestChaosMaxDurationConfig(t *testing.T) {
+	t.Parallel()
+
+	config := &environment.Config{}
+	require.NoError(t, yaml.Unmarshal([]byte("chaos_max_duration: 10m\ntimeout: 60000000000\n"), config))
+	require.Equal(t, 10*time.Minute, config.ChaosMaxDuration.AsTimeDuration())
+	require.Equal(t, time.Minute, config.MarshalSafeTimeout.AsTimeDuration())
+	require.Error(t, yaml.Unmarshal([]byte("chaos_max_duration: forever\n"), config))
+
+	var d environment.MarshalSafeDuration
+	require.NoError(t, d.Decode("90s"))
+	require.Equal(t, 90*time.Second, d.AsTimeDuration())
+}
+
+func TestPresetFromConfigFile(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "plugin-example.yaml")
+	require.NoError(t, os.WriteFile(path, []byte("namespace_prefix: plugin\n"), 0644))
+	config, err := environment.ReadConfigFile(path)
+	require.NoError(t, err)
+	require.Equal(t, "plugin-example", config.Preset)
+
+	require.NoError(t, os.WriteFile(path, []byte("preset: plugin\n"), 0644))
+	config, err = environment.ReadConfigFile(path)
+	require.NoError(t, err)
+	require.Equal(t, "plugin", config.Preset, "preset of the env file is kept")
+}
+
+func TestDumpConfigRedacted(t *testing.T) {
+	t.Parallel()
+
+	cfg := environment.NewPluginConfig(map[string]interface{}{
+		"env": map[string]interface{}{"PLUGIN_PGPASSWORD": "node"},
+	}, "")
+	path := filepath.Join(t.TempDir(), "env.yaml")
+	require.NoError(t, environment.DumpConfig(cfg, path))
+	persisted, err := environment.ReadConfigFile(path)
+	require.NoError(t, err)
+	require.Equal(t, environment.RedactedValue,
+		persisted.Charts["plugin"].Values["env"].(map[string]interface{})["PLUGIN_PGPASSWORD"])
+	require.Equal(t, "node", cfg.Charts["plugin"].Values["env"].(map[string]interface{})["PLUGIN_PGPASSWORD"],
+		"values in memory must be kept")
+}
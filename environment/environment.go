@@ -52,6 +52,9 @@ type Environment struct {
 	k8sClient  *kubernetes.Clientset
 	k8sConfig  *rest.Config
 	forwarders []*portforward.PortForwarder
+
+	chaosProbes  map[string]ChaosProbes
+	probeResults map[string][]*ProbeResult
 }
 
 // NewEnvironment creates new environment from charts
@@ -64,6 +67,13 @@ func NewEnvironment(config *Config) (*Environment, error) {
 		config.Charts = map[string]*HelmChart{}
 	}
 	defaultK8sConfig(config, kc)
+	redactor, err := config.Redactor()
+	if err != nil {
+		return nil, err
+	}
+	if RedactGlobalLogs {
+		RedactLogs(redactor)
+	}
 	he := &Environment{
 		Config:    config,
 		k8sClient: ks,
@@ -236,18 +246,17 @@ func LoadEnvironment(config *Config) (*Environment, error) {
 		return nil, err
 	}
 	environment.Artifacts = artifacts
-	cc, err := chaos.NewController(&chaos.Config{
-		Client:        environment.k8sClient,
-		NamespaceName: config.Namespace,
-	})
-	if err != nil {
+	if err := environment.initChaos(); err != nil {
 		return nil, err
 	}
-	environment.Chaos = cc
 	for _, chart := range environment.Config.Charts {
 		if err := chart.Init(environment); err != nil {
 			return environment, err
 		}
+		// values are kept real in memory, the persisted config has them redacted
+		if err := chart.restoreRedactedValues(); err != nil {
+			log.Warn().Err(err).Str("Chart", chart.ReleaseName).Msg("Redacted chart values are kept")
+		}
 	}
 	return environment, nil
 }
@@ -267,16 +276,30 @@ func GetLocalK8sDeps() (*kubernetes.Clientset, *rest.Config, error) {
 	return k8sClient, k8sConfig, nil
 }
 
-// Disconnect closes any current open port forwarder rules
+// Disconnect closes any current open port forwarder rules and stops chaos experiments started by this environment
 func (k *Environment) Disconnect() {
 	log.Info().Str("Namespace", k.Namespace).Msg("Disconnecting all open forwarded ports")
 	for _, forwarder := range k.forwarders {
 		forwarder.Close()
 	}
+	if k.Chaos != nil {
+		if err := k.Chaos.StopStarted(); err != nil {
+			log.Error().Err(err).Msg("Error while stopping chaos experiments")
+		}
+	}
 }
 
 // Teardown tears down the helm releases
 func (k *Environment) Teardown() error {
+	if k.Artifacts != nil {
+		k.Artifacts.StopStreaming()
+	}
+	// experiments left behind block pods recovery and namespace removal, teardown continues anyway
+	if k.Chaos != nil {
+		if err := k.Chaos.StopAll(); err != nil {
+			log.Error().Err(err).Msg("Error while stopping chaos experiments")
+		}
+	}
 	k.Disconnect()
 	group := &errgroup.Group{}
 	for _, c := range k.Charts {
@@ -317,14 +340,36 @@ func (k *Environment) Init(namespacePrefix string) error {
 		return err
 	}
 	k.Artifacts = a
-	cc, err := chaos.NewController(&chaos.Config{
+	return k.initChaos()
+}
+
+// initChaos creates chaos controller with the configured backend
+func (k *Environment) initChaos() error {
+	cfg := &chaos.Config{
 		Client:        k.k8sClient,
 		NamespaceName: k.Config.Namespace,
-	})
+		Templates:     k.Config.ChaosTemplates,
+		RunID:         k.Config.ChaosRunID,
+		MaxDuration:   k.Config.ChaosMaxDuration.AsTimeDuration(),
+	}
+	switch k.Config.ChaosBackend {
+	case "", ChaosBackendChaosMesh:
+	case chaos.NativeBackendName:
+		cfg.Backend = &chaos.NativeBackend{
+			Client:     k.k8sClient,
+			Namespace:  k.Config.Namespace,
+			RESTConfig: k.k8sConfig,
+			PodFailure: k.Config.ChaosPodFailure,
+		}
+	default:
+		return fmt.Errorf("unknown chaos backend %s", k.Config.ChaosBackend)
+	}
+	cc, err := chaos.NewController(cfg)
 	if err != nil {
 		return err
 	}
 	k.Chaos = cc
+	k.Config.ChaosRunID = cc.Cfg.RunID
 	return nil
 }
 
@@ -465,10 +510,10 @@ func (k *Environment) ConnectAll() error {
 // GetSecretField retrieves field data from k8s secret
 func (k *Environment) GetSecretField(namespace string, secretName string, fieldName string) (string, error) {
 	res, err := k.k8sClient.CoreV1().Secrets(namespace).Get(context.Background(), secretName, metaV1.GetOptions{})
-	log.Debug().Interface("Data", res.Data).Send()
 	if err != nil {
 		return "", err
 	}
+	log.Debug().Str("Secret", secretName).Str("Field", fieldName).Msg("Read secret field")
 	return string(res.Data[fieldName]), nil
 }
 
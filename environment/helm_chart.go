@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"context"
 	"fmt"
+	"io"
 	"io/fs"
 	"net/url"
 	"os"
@@ -22,15 +23,13 @@ import (
 	"helm.sh/helm/v3/pkg/chart/loader"
 	"helm.sh/helm/v3/pkg/chartutil"
 	"helm.sh/helm/v3/pkg/kube"
+	"helm.sh/helm/v3/pkg/release"
 	v1 "k8s.io/api/core/v1"
 	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	"k8s.io/apimachinery/pkg/runtime/schema"
-	"k8s.io/apimachinery/pkg/runtime/serializer"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/types"
-	"k8s.io/cli-runtime/pkg/genericclioptions"
 	"k8s.io/client-go/kubernetes/scheme"
 	"k8s.io/client-go/tools/remotecommand"
-	"k8s.io/kubectl/pkg/cmd/cp"
 )
 
 const (
@@ -142,8 +141,20 @@ func (hc *HelmChart) Uninstall() error {
 	return nil
 }
 
-// Upgrade an already deployed Helm chart with new values
+// Upgrade an already deployed Helm chart with new values, values redacted in a persisted config
+// are restored from the deployed release
 func (hc *HelmChart) Upgrade() error {
+	if err := hc.upgradeChart(); err != nil {
+		return err
+	}
+	return hc.refreshSettings()
+}
+
+// upgradeChart upgrades the Helm release with the chart values
+func (hc *HelmChart) upgradeChart() error {
+	if err := hc.restoreRedactedValues(); err != nil {
+		return err
+	}
 	helmChart, err := hc.loadChart()
 	if err != nil {
 		return err
@@ -154,10 +165,14 @@ func (hc *HelmChart) Upgrade() error {
 	upgrader.Timeout = HelmInstallTimeout
 	// blocks until all podsPortsInfo are healthy
 	upgrader.Wait = true
+	upgrader.PostRenderer = &releaseLabeler{releaseName: hc.ReleaseName}
 
-	if _, err := upgrader.Run(hc.ReleaseName, helmChart, hc.Values); err != nil {
-		return err
-	}
+	_, err = upgrader.Run(hc.ReleaseName, helmChart, hc.Values)
+	return err
+}
+
+// refreshSettings reads apps and pods of the deployed release and updates chart settings
+func (hc *HelmChart) refreshSettings() error {
 	if err := hc.enumerateApps(); err != nil {
 		return err
 	}
@@ -167,20 +182,27 @@ func (hc *HelmChart) Upgrade() error {
 	return hc.updateChartSettings()
 }
 
+// restoreRedactedValues restores values redacted in a persisted config from the deployed release
+func (hc *HelmChart) restoreRedactedValues() error {
+	if !containsRedacted(hc.Values) {
+		return nil
+	}
+	rel, err := hc.Release()
+	if err != nil {
+		return errors.Wrap(err, "failed to restore redacted values from the deployed release")
+	}
+	restoreRedacted(hc.Values, rel.Config)
+	return nil
+}
+
+// Release returns the latest deployed Helm release of the chart
+func (hc *HelmChart) Release() (*release.Release, error) {
+	return action.NewGet(hc.actionConfig).Run(hc.ReleaseName)
+}
+
 // CopyToPod copies src to a particular container. Destination should be in the form of a proper K8s destination path
 // NAMESPACE/POD_NAME:folder/FILE_NAME
 func (hc *HelmChart) CopyToPod(src, destination, containername string) (*bytes.Buffer, *bytes.Buffer, *bytes.Buffer, error) {
-	hc.env.k8sConfig.APIPath = "/api"
-	hc.env.k8sConfig.GroupVersion = &schema.GroupVersion{Version: "v1"} // this targets the core api groups so the url path will be /api/v1
-	hc.env.k8sConfig.NegotiatedSerializer = serializer.WithoutConversionCodecFactory{CodecFactory: scheme.Codecs}
-	ioStreams, in, out, errOut := genericclioptions.NewTestIOStreams()
-
-	copyOptions := cp.NewCopyOptions(ioStreams)
-	copyOptions.Clientset = hc.env.k8sClient
-	copyOptions.ClientConfig = hc.env.k8sConfig
-	copyOptions.Container = containername
-	copyOptions.Namespace = hc.env.Namespace
-
 	formatted, err := regexp.MatchString(".*?\\/.*?\\:.*", destination)
 	if err != nil {
 		return nil, nil, nil, fmt.Errorf("Could not run copy operation: %v", err)
@@ -197,15 +219,25 @@ func (hc *HelmChart) CopyToPod(src, destination, containername string) (*bytes.B
 		Str("Container", containername).
 		Msg("Uploading file to pod")
 
-	err = copyOptions.Run([]string{src, destination})
-	if err != nil {
-		return nil, nil, nil, fmt.Errorf("Could not run copy operation: %v", err)
-	}
-	return in, out, errOut, nil
+	return hc.env.copyFiles(src, destination, containername)
 }
 
 // ExecuteInPod is similar to kubectl exec
 func (hc *HelmChart) ExecuteInPod(podName string, containerName string, command []string) ([]byte, []byte, error) {
+	var stdout, stderr bytes.Buffer
+	if err := hc.ExecuteInPodStreams(podName, containerName, command, nil, &stdout, &stderr); err != nil {
+		return []byte{}, []byte{}, err
+	}
+	return stdout.Bytes(), stderr.Bytes(), nil
+}
+
+// ExecuteInPodStreams is similar to kubectl exec -i, stdin is passed to the command if it's set
+func (hc *HelmChart) ExecuteInPodStreams(
+	podName, containerName string,
+	command []string,
+	stdin io.Reader,
+	stdout, stderr io.Writer,
+) error {
 	req := hc.env.k8sClient.CoreV1().RESTClient().Post().
 		Resource("pods").
 		Name(podName).
@@ -214,7 +246,7 @@ func (hc *HelmChart) ExecuteInPod(podName string, containerName string, command
 	req.VersionedParams(&v1.PodExecOptions{
 		Container: containerName,
 		Command:   command,
-		Stdin:     false,
+		Stdin:     stdin != nil,
 		Stdout:    true,
 		Stderr:    true,
 		TTY:       false,
@@ -222,19 +254,13 @@ func (hc *HelmChart) ExecuteInPod(podName string, containerName string, command
 
 	exec, err := remotecommand.NewSPDYExecutor(hc.env.k8sConfig, "POST", req.URL())
 	if err != nil {
-		return []byte{}, []byte{}, err
+		return err
 	}
-
-	var stdout, stderr bytes.Buffer
-	err = exec.Stream(remotecommand.StreamOptions{
-		Stdin:  nil,
-		Stdout: &stdout,
-		Stderr: &stderr,
+	return exec.Stream(remotecommand.StreamOptions{
+		Stdin:  stdin,
+		Stdout: stdout,
+		Stderr: stderr,
 	})
-	if err != nil {
-		return []byte{}, []byte{}, err
-	}
-	return stdout.Bytes(), stderr.Bytes(), nil
 }
 
 // GetPodsByNameSubstring retrieves all running pods whose names contain the provided substring
@@ -342,28 +368,39 @@ func (hc *HelmChart) loadChart() (*chart.Chart, error) {
 			return nil, errors.Wrapf(err, "faild to load embedded char files: %s", hc.Path)
 		}
 	}
+	redactor, err := hc.env.Config.Redactor()
+	if err != nil {
+		return nil, err
+	}
 	log.Info().Str("Path", hc.Path).
 		Str("Source", source).
 		Str("Release", hc.ReleaseName).
 		Str("Namespace", hc.namespaceName).
-		Interface("Overrides", hc.Values).
+		Interface("Overrides", redactor.Map(hc.Values)).
 		Msg("Installing Helm chart")
 	loadedChart.Values, err = chartutil.CoalesceValues(loadedChart, hc.Values)
 	if err != nil {
 		return nil, err
 	}
-	log.Info().Interface("Values", loadedChart.Values).Msg("Merged chart values")
+	log.Info().Interface("Values", redactor.Map(loadedChart.Values)).Msg("Merged chart values")
 	return loadedChart, nil
 }
 
 // deployChart deploys the helm Charts
 func (hc *HelmChart) deployChart() error {
+	if containsRedacted(hc.Values) {
+		return fmt.Errorf(
+			"chart %s values contain %s secrets from a persisted config, set them again before deploying",
+			hc.ReleaseName, RedactedValue,
+		)
+	}
 	install := action.NewInstall(hc.actionConfig)
 	install.Namespace = hc.namespaceName
 	install.ReleaseName = hc.ReleaseName
 	install.Timeout = HelmInstallTimeout
 	// blocks until all podsPortsInfo are healthy
 	install.Wait = true
+	install.PostRenderer = &releaseLabeler{releaseName: hc.ReleaseName}
 
 	helmChart, err := hc.loadChart()
 	if err != nil {
@@ -418,7 +455,7 @@ func (hc *HelmChart) fetchPods() error {
 	var err error
 	k8sPods := hc.env.k8sClient.CoreV1().Pods(hc.namespaceName)
 	hc.podsList, err = k8sPods.List(context.Background(), metaV1.ListOptions{
-		LabelSelector: fmt.Sprintf("release=%s", hc.ReleaseName),
+		LabelSelector: labels.SelectorFromSet(releaseLabels(hc.ReleaseName, "")).String(),
 	})
 	if err != nil {
 		return err
@@ -0,0 +1,310 @@
+package environment
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+	"golang.org/x/sync/errgroup"
+	v1 "k8s.io/api/core/v1"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/runtime/serializer"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+	"k8s.io/client-go/kubernetes/scheme"
+	typedV1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/kubectl/pkg/cmd/cp"
+)
+
+// DefaultContainerAnnotation annotation of the container used when none is set, same as kubectl uses
+const DefaultContainerAnnotation = "kubectl.kubernetes.io/default-container"
+
+// PodTarget chart pods addressed as chart/app/instance, for example plugin/plugin-node/0,
+// app and instance can be omitted to target every pod of the chart or of the app
+type PodTarget struct {
+	Chart    string
+	App      string
+	Instance string
+}
+
+// ParsePodTarget parses chart/app/instance target
+func ParsePodTarget(s string) (*PodTarget, error) {
+	parts := strings.Split(s, "/")
+	if len(parts) > 3 {
+		return nil, fmt.Errorf("target %s must be chart/app/instance", s)
+	}
+	for _, p := range parts {
+		if len(p) == 0 {
+			return nil, fmt.Errorf("target %s must be chart/app/instance", s)
+		}
+	}
+	t := &PodTarget{Chart: parts[0]}
+	if len(parts) > 1 {
+		t.App = parts[1]
+	}
+	if len(parts) > 2 {
+		t.Instance = parts[2]
+	}
+	return t, nil
+}
+
+// String returns target as chart/app/instance
+func (t *PodTarget) String() string {
+	s := t.Chart
+	if len(t.App) > 0 {
+		s += "/" + t.App
+	}
+	if len(t.Instance) > 0 {
+		s += "/" + t.Instance
+	}
+	return s
+}
+
+// selector returns label selector of the target pods
+func (t *PodTarget) selector(releaseName string) string {
+	set := releaseLabels(releaseName, t.App)
+	if len(t.Instance) > 0 {
+		set[InstanceEnumerationLabelKey] = t.Instance
+	}
+	return labels.SelectorFromSet(set).String()
+}
+
+// LogOptions options of TargetLogs
+type LogOptions struct {
+	Follow bool
+	// Since only logs newer than that are returned, all logs when zero
+	Since time.Duration
+	// Tail amount of the last lines returned, all lines when zero
+	Tail int64
+	// Previous logs of the previous container run, for example before a crash
+	Previous bool
+}
+
+// TargetPods returns pods of the target sorted by name
+func (k *Environment) TargetPods(t *PodTarget) ([]v1.Pod, error) {
+	hc, ok := k.Charts[t.Chart]
+	if !ok {
+		return nil, fmt.Errorf("no chart with name %s", t.Chart)
+	}
+	return targetPods(k.k8sClient.CoreV1().Pods(k.Namespace), t, hc.ReleaseName)
+}
+
+// TargetPod returns the only pod of the target
+func (k *Environment) TargetPod(t *PodTarget) (*v1.Pod, error) {
+	pods, err := k.TargetPods(t)
+	if err != nil {
+		return nil, err
+	}
+	if len(pods) > 1 {
+		return nil, fmt.Errorf("target %s matches %d pods, set app and instance", t, len(pods))
+	}
+	return &pods[0], nil
+}
+
+// ExecTarget runs command in a container of the target pod, like kubectl exec, the default container is used
+// when container is empty
+func (k *Environment) ExecTarget(t *PodTarget, container string, command []string, stdin io.Reader, stdout, stderr io.Writer) error {
+	pod, err := k.TargetPod(t)
+	if err != nil {
+		return err
+	}
+	return k.Charts[t.Chart].ExecuteInPodStreams(pod.Name, defaultContainer(pod, container), command, stdin, stdout, stderr)
+}
+
+// TargetLogs writes logs of target containers to w until they end, or until ctx is done when following,
+// lines are prefixed with pod and container names if several containers are targeted, every container
+// of the target pods is targeted when container is empty
+func (k *Environment) TargetLogs(ctx context.Context, t *PodTarget, container string, opts LogOptions, w io.Writer) error {
+	hc, ok := k.Charts[t.Chart]
+	if !ok {
+		return fmt.Errorf("no chart with name %s", t.Chart)
+	}
+	redactor, err := k.Config.Redactor()
+	if err != nil {
+		return err
+	}
+	return targetLogs(ctx, k.k8sClient.CoreV1().Pods(k.Namespace), t, hc.ReleaseName, container, opts, redactor, w)
+}
+
+// CopyTarget copies files between the local filesystem and a container of the target pod, like kubectl cp,
+// either src or destination is a chart/app/instance:path of the environment
+func (k *Environment) CopyTarget(src, destination, container string) error {
+	var err error
+	remote := false
+	if src, remote, err = k.copyPath(src, &container); err != nil {
+		return err
+	}
+	remoteSrc := remote
+	if destination, remote, err = k.copyPath(destination, &container); err != nil {
+		return err
+	}
+	if remoteSrc == remote {
+		return fmt.Errorf("either source or destination must be a chart/app/instance:path target")
+	}
+	log.Info().
+		Str("Namespace", k.Namespace).
+		Str("Source", src).
+		Str("Destination", destination).
+		Str("Container", container).
+		Msg("Copying files")
+	_, _, errOut, err := k.copyFiles(src, destination, container)
+	if err != nil {
+		return err
+	}
+	if errOut.Len() > 0 {
+		log.Warn().Str("Output", errOut.String()).Msg("Copy reported errors")
+	}
+	return nil
+}
+
+// copyPath resolves chart/app/instance:path of a chart of the environment to namespace/pod:path,
+// local paths are returned as is, the default container of the pod is set if container is empty
+func (k *Environment) copyPath(p string, container *string) (string, bool, error) {
+	t, path, ok := splitTargetPath(p)
+	if !ok {
+		return p, false, nil
+	}
+	if _, ok := k.Charts[t.Chart]; !ok {
+		return p, false, nil
+	}
+	pod, err := k.TargetPod(t)
+	if err != nil {
+		return "", false, err
+	}
+	*container = defaultContainer(pod, *container)
+	return fmt.Sprintf("%s/%s:%s", k.Namespace, pod.Name, path), true, nil
+}
+
+// copyFiles copies files with kubectl cp, src or destination must be in the form of NAMESPACE/POD_NAME:path
+func (k *Environment) copyFiles(src, destination, container string) (*bytes.Buffer, *bytes.Buffer, *bytes.Buffer, error) {
+	k.k8sConfig.APIPath = "/api"
+	k.k8sConfig.GroupVersion = &schema.GroupVersion{Version: "v1"} // this targets the core api groups so the url path will be /api/v1
+	k.k8sConfig.NegotiatedSerializer = serializer.WithoutConversionCodecFactory{CodecFactory: scheme.Codecs}
+	ioStreams, in, out, errOut := genericclioptions.NewTestIOStreams()
+
+	copyOptions := cp.NewCopyOptions(ioStreams)
+	copyOptions.Clientset = k.k8sClient
+	copyOptions.ClientConfig = k.k8sConfig
+	copyOptions.Container = container
+	copyOptions.Namespace = k.Namespace
+
+	if err := copyOptions.Run([]string{src, destination}); err != nil {
+		return nil, nil, nil, fmt.Errorf("Could not run copy operation: %v", err)
+	}
+	return in, out, errOut, nil
+}
+
+// splitTargetPath splits chart/app/instance:path, ok is false for paths without a target
+func splitTargetPath(p string) (*PodTarget, string, bool) {
+	i := strings.IndexByte(p, ':')
+	if i <= 0 {
+		return nil, "", false
+	}
+	t, err := ParsePodTarget(p[:i])
+	if err != nil {
+		return nil, "", false
+	}
+	return t, p[i+1:], true
+}
+
+func targetPods(pods typedV1.PodInterface, t *PodTarget, releaseName string) ([]v1.Pod, error) {
+	list, err := pods.List(context.Background(), metaV1.ListOptions{LabelSelector: t.selector(releaseName)})
+	if err != nil {
+		return nil, err
+	}
+	if len(list.Items) == 0 {
+		return nil, fmt.Errorf("no pods of target %s", t)
+	}
+	sort.Slice(list.Items, func(i, j int) bool {
+		return list.Items[i].Name < list.Items[j].Name
+	})
+	return list.Items, nil
+}
+
+// defaultContainer returns container if it's set, or the default container of the pod
+func defaultContainer(pod *v1.Pod, container string) string {
+	if len(container) > 0 {
+		return container
+	}
+	if c, ok := pod.Annotations[DefaultContainerAnnotation]; ok {
+		return c
+	}
+	if len(pod.Spec.Containers) > 0 {
+		return pod.Spec.Containers[0].Name
+	}
+	return ""
+}
+
+func targetLogs(
+	ctx context.Context,
+	pods typedV1.PodInterface,
+	t *PodTarget,
+	releaseName, container string,
+	opts LogOptions,
+	redactor *Redactor,
+	w io.Writer,
+) error {
+	targets, err := targetPods(pods, t, releaseName)
+	if err != nil {
+		return err
+	}
+	type stream struct{ pod, container string }
+	var streams []stream
+	for _, pod := range targets {
+		if len(container) > 0 {
+			streams = append(streams, stream{pod.Name, container})
+			continue
+		}
+		for _, c := range pod.Spec.Containers {
+			streams = append(streams, stream{pod.Name, c.Name})
+		}
+	}
+	var mu sync.Mutex
+	group, ctx := errgroup.WithContext(ctx)
+	for _, s := range streams {
+		s := s
+		prefix := ""
+		if len(streams) > 1 {
+			prefix = fmt.Sprintf("[%s/%s] ", s.pod, s.container)
+		}
+		group.Go(func() error {
+			logOpts := &v1.PodLogOptions{Container: s.container, Follow: opts.Follow, Previous: opts.Previous}
+			if opts.Since > 0 {
+				seconds := int64(opts.Since.Seconds())
+				logOpts.SinceSeconds = &seconds
+			}
+			if opts.Tail > 0 {
+				logOpts.TailLines = &opts.Tail
+			}
+			rc, err := pods.GetLogs(s.pod, logOpts).Stream(ctx)
+			if err != nil {
+				return errors.Wrapf(err, "failed to stream logs of %s/%s", s.pod, s.container)
+			}
+			defer rc.Close()
+			scanner := bufio.NewScanner(rc)
+			scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+			for scanner.Scan() {
+				mu.Lock()
+				_, err := fmt.Fprintf(w, "%s%s\n", prefix, redactor.String(scanner.Text()))
+				mu.Unlock()
+				if err != nil {
+					return err
+				}
+			}
+			if ctx.Err() != nil {
+				return nil
+			}
+			return scanner.Err()
+		})
+	}
+	return group.Wait()
+}
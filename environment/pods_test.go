@@ -0,0 +1,106 @@
+package environment
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func targetPod(name, release, app, instance string, containers ...string) *v1.Pod {
+	pod := &v1.Pod{ObjectMeta: metaV1.ObjectMeta{
+		Name:      name,
+		Namespace: "env",
+		Labels: map[string]string{
+			ReleaseLabelKey:             release,
+			AppEnumerationLabelKey:      app,
+			InstanceEnumerationLabelKey: instance,
+		},
+	}}
+	for _, c := range containers {
+		pod.Spec.Containers = append(pod.Spec.Containers, v1.Container{Name: c})
+	}
+	return pod
+}
+
+func TestParsePodTarget(t *testing.T) {
+	t.Parallel()
+
+	target, err := ParsePodTarget("plugin/plugin-node/0")
+	require.NoError(t, err)
+	require.Equal(t, &PodTarget{Chart: "plugin", App: "plugin-node", Instance: "0"}, target)
+	require.Equal(t, "plugin/plugin-node/0", target.String())
+	require.Equal(t, "app=plugin-node,app.kubernetes.io/instance=plugin,instance=0", target.selector("plugin"))
+
+	target, err = ParsePodTarget("geth")
+	require.NoError(t, err)
+	require.Equal(t, "app.kubernetes.io/instance=geth", target.selector("geth"))
+
+	for _, invalid := range []string{"", "plugin//0", "plugin/plugin-node/0/node"} {
+		_, err = ParsePodTarget(invalid)
+		require.Error(t, err, invalid)
+	}
+
+	target, path, ok := splitTargetPath("plugin/plugin-node/1:/tmp/config.toml")
+	require.True(t, ok)
+	require.Equal(t, "1", target.Instance)
+	require.Equal(t, "/tmp/config.toml", path)
+	_, _, ok = splitTargetPath("./config.toml")
+	require.False(t, ok)
+}
+
+func TestTargetPods(t *testing.T) {
+	t.Parallel()
+
+	client := fake.NewSimpleClientset(
+		targetPod("plugin-node-1", "plugin", "plugin-node", "1", "node"),
+		targetPod("plugin-node-0", "plugin", "plugin-node", "0", "node", "plugin-db"),
+		targetPod("geth-0", "geth", "geth", "0", "geth"),
+	)
+	pods := client.CoreV1().Pods("env")
+	found, err := targetPods(pods, &PodTarget{Chart: "plugin", App: "plugin-node"}, "plugin")
+	require.NoError(t, err)
+	require.Len(t, found, 2)
+	require.Equal(t, "plugin-node-0", found[0].Name)
+
+	found, err = targetPods(pods, &PodTarget{Chart: "plugin", App: "plugin-node", Instance: "1"}, "plugin")
+	require.NoError(t, err)
+	require.Len(t, found, 1)
+	_, err = targetPods(pods, &PodTarget{Chart: "plugin", App: "plugin-node", Instance: "2"}, "plugin")
+	require.EqualError(t, err, "no pods of target plugin/plugin-node/2")
+
+	pod := targetPod("plugin-node-0", "plugin", "plugin-node", "0", "node", "plugin-db")
+	require.Equal(t, "node", defaultContainer(pod, ""))
+	require.Equal(t, "plugin-db", defaultContainer(pod, "plugin-db"))
+	pod.Annotations = map[string]string{DefaultContainerAnnotation: "plugin-db"}
+	require.Equal(t, "plugin-db", defaultContainer(pod, ""))
+}
+
+func TestTargetLogs(t *testing.T) {
+	t.Parallel()
+
+	client := fake.NewSimpleClientset(
+		targetPod("plugin-node-0", "plugin", "plugin-node", "0", "node", "plugin-db"),
+		targetPod("plugin-node-1", "plugin", "plugin-node", "1", "node"),
+	)
+	pods := client.CoreV1().Pods("env")
+	var buf bytes.Buffer
+	target := &PodTarget{Chart: "plugin", App: "plugin-node", Instance: "0"}
+	require.NoError(t, targetLogs(context.Background(), pods, target, "plugin", "node", LogOptions{}, nil, &buf))
+	require.Equal(t, "fake logs\n", buf.String(), "single container isn't prefixed")
+
+	buf.Reset()
+	target.Instance = ""
+	require.NoError(t, targetLogs(context.Background(), pods, target, "plugin", "", LogOptions{}, nil, &buf))
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	require.ElementsMatch(t, []string{
+		"[plugin-node-0/node] fake logs",
+		"[plugin-node-0/plugin-db] fake logs",
+		"[plugin-node-1/node] fake logs",
+	}, lines)
+}
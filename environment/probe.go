@@ -0,0 +1,465 @@
+package environment
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/goplugin/helmenv/chaos"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+	v1 "k8s.io/api/core/v1"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	// ProbeBefore probes run before the experiment is applied
+	ProbeBefore = "before"
+	// ProbeDuring probes run while the experiment is active
+	ProbeDuring = "during"
+	// ProbeAfter probes run after the experiment is stopped
+	ProbeAfter = "after"
+
+	// DefaultProbeTimeout default timeout of a single probe
+	DefaultProbeTimeout = 30 * time.Second
+	// DefaultBlockHeightWindow default time blocks must be produced in, shorter than DefaultProbeTimeout,
+	// so a stalled chain is reported with its block heights instead of a timeout, see BlockHeightProbe.Timeout
+	DefaultBlockHeightWindow = 20 * time.Second
+)
+
+// ChaosProbes probes run around a chaos experiment, during probes run after chaos is injected if InjectTimeout is set,
+// otherwise right after the experiment is applied
+type ChaosProbes struct {
+	Before        []Probe
+	During        []Probe
+	After         []Probe
+	InjectTimeout time.Duration
+}
+
+// Probe steady state probe, returns error if the system is not healthy
+type Probe interface {
+	Name() string
+	Probe(ctx context.Context, env *Environment) error
+}
+
+// TimedProbe probe with its own timeout, RunProbes uses DefaultProbeTimeout for other probes
+type TimedProbe interface {
+	Probe
+	// ProbeTimeout returns the probe timeout, DefaultProbeTimeout is used when it's zero
+	ProbeTimeout() time.Duration
+}
+
+// ProbeEndpoint port of a chart connection, reached through port forwarding, the chart is connected if it wasn't yet
+type ProbeEndpoint struct {
+	Chart string
+	// Connection chart connection key, for example plugin-node_0_node
+	Connection string
+	// PortName named port, defaults to "access"
+	PortName string
+}
+
+// HTTPProbe checks response status and body of an endpoint
+type HTTPProbe struct {
+	ProbeName string
+	Endpoint  ProbeEndpoint
+	// Method defaults to GET
+	Method string
+	Path   string
+	Body   string
+	// Status expected status, defaults to 200
+	Status int
+	// BodyMatch regular expression response body must match
+	BodyMatch string
+	// Timeout defaults to DefaultProbeTimeout
+	Timeout time.Duration
+	Client  *http.Client
+}
+
+// BlockHeightProbe checks that a JSON-RPC node produces MinBlocks blocks in Window
+type BlockHeightProbe struct {
+	ProbeName string
+	Endpoint  ProbeEndpoint
+	// MinBlocks defaults to 1
+	MinBlocks int
+	// Window defaults to DefaultBlockHeightWindow, it must be shorter than Timeout
+	Window time.Duration
+	// PollInterval defaults to a second
+	PollInterval time.Duration
+	// Timeout defaults to DefaultProbeTimeout, set it for slow chains or many blocks
+	Timeout time.Duration
+	Client  *http.Client
+}
+
+// PodsReadyProbe checks that at least Min pods of a chart app are ready, every pod must be ready if Min is zero
+type PodsReadyProbe struct {
+	ProbeName string
+	Chart     string
+	// App every app of the chart by default
+	App string
+	Min int
+	// Timeout defaults to DefaultProbeTimeout
+	Timeout time.Duration
+}
+
+// FuncProbe custom probe
+type FuncProbe struct {
+	ProbeName string
+	Func      func(ctx context.Context, env *Environment) error
+	// Timeout defaults to DefaultProbeTimeout
+	Timeout time.Duration
+}
+
+// ProbeResult pass or fail of a probe
+type ProbeResult struct {
+	Probe    string        `json:"probe"`
+	Phase    string        `json:"phase"`
+	Start    time.Time     `json:"start"`
+	Duration time.Duration `json:"duration"`
+	Passed   bool          `json:"passed"`
+	Error    string        `json:"error,omitempty"`
+}
+
+// ProbeError probes failed
+type ProbeError struct {
+	Phase   string
+	Results []*ProbeResult
+}
+
+func (e *ProbeError) Error() string {
+	var msgs []string
+	for _, r := range e.Results {
+		if !r.Passed {
+			msgs = append(msgs, fmt.Sprintf("%s: %s", r.Probe, r.Error))
+		}
+	}
+	return fmt.Sprintf("%s probes failed: %s", e.Phase, strings.Join(msgs, "; "))
+}
+
+// ProbeTimeout returns the probe timeout
+func (p *HTTPProbe) ProbeTimeout() time.Duration {
+	return p.Timeout
+}
+
+// Name returns the probe name
+func (p *HTTPProbe) Name() string {
+	if len(p.ProbeName) > 0 {
+		return p.ProbeName
+	}
+	return fmt.Sprintf("http %s%s", p.Endpoint.Connection, p.Path)
+}
+
+// Probe requests the endpoint and checks the response
+func (p *HTTPProbe) Probe(ctx context.Context, env *Environment) error {
+	baseURL, err := env.probeURL(p.Endpoint)
+	if err != nil {
+		return err
+	}
+	method := p.Method
+	if len(method) == 0 {
+		method = http.MethodGet
+	}
+	req, err := http.NewRequestWithContext(ctx, method, baseURL+p.Path, strings.NewReader(p.Body))
+	if err != nil {
+		return err
+	}
+	resp, err := httpClient(p.Client).Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	status := p.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	if resp.StatusCode != status {
+		return fmt.Errorf("expected status %d, got %d", status, resp.StatusCode)
+	}
+	if len(p.BodyMatch) > 0 {
+		matched, err := regexp.Match(p.BodyMatch, body)
+		if err != nil {
+			return err
+		}
+		if !matched {
+			return fmt.Errorf("body doesn't match %s", p.BodyMatch)
+		}
+	}
+	return nil
+}
+
+// ProbeTimeout returns the probe timeout
+func (p *BlockHeightProbe) ProbeTimeout() time.Duration {
+	return p.Timeout
+}
+
+// Name returns the probe name
+func (p *BlockHeightProbe) Name() string {
+	if len(p.ProbeName) > 0 {
+		return p.ProbeName
+	}
+	return fmt.Sprintf("block height %s", p.Endpoint.Connection)
+}
+
+// Probe polls eth_blockNumber until the height grows by MinBlocks
+func (p *BlockHeightProbe) Probe(ctx context.Context, env *Environment) error {
+	rpcURL, err := env.probeURL(p.Endpoint)
+	if err != nil {
+		return err
+	}
+	minBlocks, window, interval := p.MinBlocks, p.Window, p.PollInterval
+	if minBlocks == 0 {
+		minBlocks = 1
+	}
+	if window == 0 {
+		window = DefaultBlockHeightWindow
+	}
+	if interval == 0 {
+		interval = time.Second
+	}
+	if timeout := probeTimeout(p); window >= timeout {
+		return fmt.Errorf("block height window %s must be shorter than the probe timeout %s", window, timeout)
+	}
+	start, err := p.blockNumber(ctx, rpcURL)
+	if err != nil {
+		return err
+	}
+	deadline := time.Now().Add(window)
+	height := start
+	for height < start+uint64(minBlocks) {
+		if time.Now().After(deadline) {
+			return fmt.Errorf("block height grew from %d to %d in %s, expected %d blocks", start, height, window, minBlocks)
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(interval):
+		}
+		if height, err = p.blockNumber(ctx, rpcURL); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p *BlockHeightProbe) blockNumber(ctx context.Context, rpcURL string) (uint64, error) {
+	body := []byte(`{"jsonrpc":"2.0","method":"eth_blockNumber","params":[],"id":1}`)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, rpcURL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := httpClient(p.Client).Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	var res struct {
+		Result string `json:"result"`
+		Error  *struct {
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&res); err != nil {
+		return 0, errors.Wrap(err, "failed to decode eth_blockNumber response")
+	}
+	if res.Error != nil {
+		return 0, fmt.Errorf("eth_blockNumber failed: %s", res.Error.Message)
+	}
+	return strconv.ParseUint(strings.TrimPrefix(res.Result, "0x"), 16, 64)
+}
+
+// ProbeTimeout returns the probe timeout
+func (p *PodsReadyProbe) ProbeTimeout() time.Duration {
+	return p.Timeout
+}
+
+// Name returns the probe name
+func (p *PodsReadyProbe) Name() string {
+	if len(p.ProbeName) > 0 {
+		return p.ProbeName
+	}
+	return fmt.Sprintf("pods ready %s %s", p.Chart, p.App)
+}
+
+// Probe counts ready pods of the chart app
+func (p *PodsReadyProbe) Probe(ctx context.Context, env *Environment) error {
+	hc, err := env.Charts.Get(p.Chart)
+	if err != nil {
+		return err
+	}
+	return p.probe(ctx, env.k8sClient, env.Config.Namespace, hc.ReleaseName)
+}
+
+func (p *PodsReadyProbe) probe(ctx context.Context, client kubernetes.Interface, namespace, release string) error {
+	selector := labels.SelectorFromSet(releaseLabels(release, p.App)).String()
+	pods, err := client.CoreV1().Pods(namespace).List(ctx, metaV1.ListOptions{LabelSelector: selector})
+	if err != nil {
+		return err
+	}
+	if len(pods.Items) == 0 {
+		return fmt.Errorf("no pods match %s", selector)
+	}
+	ready := 0
+	for _, pod := range pods.Items {
+		if podReady(pod) {
+			ready++
+		}
+	}
+	min := p.Min
+	if min == 0 {
+		min = len(pods.Items)
+	}
+	if ready < min {
+		return fmt.Errorf("%d of %d pods are ready, expected %d", ready, len(pods.Items), min)
+	}
+	return nil
+}
+
+func podReady(pod v1.Pod) bool {
+	for _, c := range pod.Status.Conditions {
+		if c.Type == v1.PodReady {
+			return c.Status == v1.ConditionTrue
+		}
+	}
+	return false
+}
+
+// ProbeTimeout returns the probe timeout
+func (p *FuncProbe) ProbeTimeout() time.Duration {
+	return p.Timeout
+}
+
+// Name returns the probe name
+func (p *FuncProbe) Name() string {
+	return p.ProbeName
+}
+
+// Probe runs the func
+func (p *FuncProbe) Probe(ctx context.Context, env *Environment) error {
+	return p.Func(ctx, env)
+}
+
+// probeURL returns the local forwarded URL of an endpoint
+func (k *Environment) probeURL(e ProbeEndpoint) (string, error) {
+	hc, err := k.Charts.Get(e.Chart)
+	if err != nil {
+		return "", err
+	}
+	portName := e.PortName
+	if len(portName) == 0 {
+		portName = "access"
+	}
+	cc, ok := hc.ChartConnections[e.Connection]
+	if !ok {
+		return "", fmt.Errorf("chart connection by the key of '%s' doesn't exist", e.Connection)
+	}
+	if _, ok := cc.RemotePorts[portName]; !ok {
+		return "", fmt.Errorf("connection %s has no port %s", e.Connection, portName)
+	}
+	if _, ok := cc.LocalPorts[portName]; !ok {
+		log.Info().Str("Chart", e.Chart).Msg("Connecting chart to probe it")
+		if err := hc.Connect(); err != nil {
+			return "", err
+		}
+	}
+	return fmt.Sprintf("http://localhost:%d", cc.LocalPorts[portName]), nil
+}
+
+// ScenarioCheckProbe returns the probe of a declarative scenario check, nil if the check has none
+func ScenarioCheckProbe(c *chaos.ScenarioCheck) Probe {
+	timeout := time.Duration(c.Timeout)
+	switch {
+	case c.HTTP != nil:
+		return &HTTPProbe{
+			ProbeName: c.Name,
+			Endpoint:  checkEndpoint(c.HTTP.CheckEndpoint),
+			Method:    c.HTTP.Method,
+			Path:      c.HTTP.Path,
+			Body:      c.HTTP.Body,
+			Status:    c.HTTP.Status,
+			BodyMatch: c.HTTP.BodyMatch,
+			Timeout:   timeout,
+		}
+	case c.BlockHeight != nil:
+		return &BlockHeightProbe{
+			ProbeName:    c.Name,
+			Endpoint:     checkEndpoint(c.BlockHeight.CheckEndpoint),
+			MinBlocks:    c.BlockHeight.MinBlocks,
+			Window:       time.Duration(c.BlockHeight.Window),
+			PollInterval: time.Duration(c.BlockHeight.PollInterval),
+			Timeout:      timeout,
+		}
+	case c.PodsReady != nil:
+		return &PodsReadyProbe{
+			ProbeName: c.Name,
+			Chart:     c.PodsReady.Chart,
+			App:       c.PodsReady.App,
+			Min:       c.PodsReady.Min,
+			Timeout:   timeout,
+		}
+	}
+	return nil
+}
+
+func checkEndpoint(e chaos.CheckEndpoint) ProbeEndpoint {
+	return ProbeEndpoint{Chart: e.Chart, Connection: e.Connection, PortName: e.Port}
+}
+
+// probeTimeout returns the timeout of a TimedProbe, or DefaultProbeTimeout
+func probeTimeout(p Probe) time.Duration {
+	if tp, ok := p.(TimedProbe); ok && tp.ProbeTimeout() > 0 {
+		return tp.ProbeTimeout()
+	}
+	return DefaultProbeTimeout
+}
+
+// RunProbes runs probes one by one with their timeouts, DefaultProbeTimeout by default, returns ProbeError
+// if any probe failed
+func (k *Environment) RunProbes(ctx context.Context, phase string, probes ...Probe) ([]*ProbeResult, error) {
+	results := make([]*ProbeResult, 0, len(probes))
+	failed := false
+	for _, p := range probes {
+		pctx, cancel := context.WithTimeout(ctx, probeTimeout(p))
+		res := &ProbeResult{Probe: p.Name(), Phase: phase, Start: time.Now()}
+		err := p.Probe(pctx, k)
+		cancel()
+		res.Duration = time.Since(res.Start)
+		res.Passed = err == nil
+		if err != nil {
+			failed = true
+			res.Error = err.Error()
+		}
+		log.Info().
+			Str("Probe", res.Probe).
+			Str("Phase", phase).
+			Bool("Passed", res.Passed).
+			Str("Duration", res.Duration.String()).
+			Str("Error", res.Error).
+			Msg("Steady state probe")
+		results = append(results, res)
+	}
+	if failed {
+		return results, &ProbeError{Phase: phase, Results: results}
+	}
+	return results, nil
+}
+
+// ProbeCheck adapts probes to a chaos scenario check
+func (k *Environment) ProbeCheck(probes ...Probe) chaos.Check {
+	return func(ctx context.Context) error {
+		_, err := k.RunProbes(ctx, "check", probes...)
+		return err
+	}
+}
@@ -0,0 +1,276 @@
+package environment
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/goplugin/helmenv/chaos"
+	"github.com/goplugin/helmenv/chaos/experiments"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/kubernetes/scheme"
+	restfake "k8s.io/client-go/rest/fake"
+)
+
+// probeEnv returns environment with the plugin node connection forwarded to the server
+func probeEnv(t *testing.T, handler http.Handler) *Environment {
+	srv := httptest.NewServer(handler)
+	t.Cleanup(srv.Close)
+	u, err := url.Parse(srv.URL)
+	require.NoError(t, err)
+	port, err := strconv.Atoi(u.Port())
+	require.NoError(t, err)
+	return &Environment{Config: &Config{
+		Namespace: "env",
+		Charts: Charts{
+			"plugin": &HelmChart{
+				ReleaseName: "plugin",
+				ChartConnections: ChartConnections{
+					"plugin-node_0_node": {
+						RemotePorts: map[string]int{"access": 6688},
+						LocalPorts:  map[string]int{"access": port},
+					},
+				},
+			},
+		},
+	}}
+}
+
+func TestHTTPProbe(t *testing.T) {
+	t.Parallel()
+
+	env := probeEnv(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/health" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_, _ = w.Write([]byte(`{"status":"passing"}`))
+	}))
+	endpoint := ProbeEndpoint{Chart: "plugin", Connection: "plugin-node_0_node"}
+	ctx := context.Background()
+	require.NoError(t, (&HTTPProbe{Endpoint: endpoint, Path: "/health", BodyMatch: `"passing"`}).Probe(ctx, env))
+	require.EqualError(t, (&HTTPProbe{Endpoint: endpoint, Path: "/health", BodyMatch: "failing"}).Probe(ctx, env),
+		"body doesn't match failing")
+	require.EqualError(t, (&HTTPProbe{Endpoint: endpoint, Path: "/missing"}).Probe(ctx, env),
+		"expected status 200, got 404")
+	require.NoError(t, (&HTTPProbe{Endpoint: endpoint, Path: "/missing", Status: http.StatusNotFound}).Probe(ctx, env))
+	require.EqualError(t, (&HTTPProbe{Endpoint: ProbeEndpoint{Chart: "plugin", Connection: "plugin-node_0_node", PortName: "p2p"}}).Probe(ctx, env),
+		"connection plugin-node_0_node has no port p2p")
+}
+
+func TestBlockHeightProbe(t *testing.T) {
+	t.Parallel()
+
+	var height, step int64 = 100, 1
+	env := probeEnv(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if !strings.Contains(string(body), "eth_blockNumber") {
+			_, _ = w.Write([]byte(`{"jsonrpc":"2.0","id":1,"error":{"message":"method not found"}}`))
+			return
+		}
+		h := atomic.AddInt64(&height, atomic.LoadInt64(&step))
+		_, _ = fmt.Fprintf(w, `{"jsonrpc":"2.0","id":1,"result":"0x%x"}`, h)
+	}))
+	probe := &BlockHeightProbe{
+		Endpoint:     ProbeEndpoint{Chart: "plugin", Connection: "plugin-node_0_node"},
+		MinBlocks:    3,
+		Window:       time.Second,
+		PollInterval: time.Millisecond,
+	}
+	require.NoError(t, probe.Probe(context.Background(), env))
+
+	atomic.StoreInt64(&step, 0)
+	probe.Window = 20 * time.Millisecond
+	err := probe.Probe(context.Background(), env)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "expected 3 blocks")
+
+	probe.Window = time.Minute
+	require.EqualError(t, probe.Probe(context.Background(), env),
+		"block height window 1m0s must be shorter than the probe timeout 30s")
+	atomic.StoreInt64(&step, 1)
+	probe.Timeout = 2 * time.Minute
+	require.NoError(t, probe.Probe(context.Background(), env))
+}
+
+func readyPod(name, app string, ready bool) *v1.Pod {
+	status := v1.ConditionFalse
+	if ready {
+		status = v1.ConditionTrue
+	}
+	return &v1.Pod{
+		ObjectMeta: metaV1.ObjectMeta{
+			Name:      name,
+			Namespace: "env",
+			Labels:    map[string]string{ReleaseLabelKey: "plugin", AppEnumerationLabelKey: app},
+		},
+		Status: v1.PodStatus{Conditions: []v1.PodCondition{{Type: v1.PodReady, Status: status}}},
+	}
+}
+
+func TestPodsReadyProbe(t *testing.T) {
+	t.Parallel()
+
+	client := fake.NewSimpleClientset(
+		readyPod("plugin-node-0", "plugin-node", true),
+		readyPod("plugin-node-1", "plugin-node", false),
+		readyPod("plugin-node-2", "plugin-node", true),
+		readyPod("geth-0", "geth", true),
+	)
+	ctx := context.Background()
+	require.NoError(t, (&PodsReadyProbe{App: "plugin-node", Min: 2}).probe(ctx, client, "env", "plugin"))
+	require.EqualError(t, (&PodsReadyProbe{App: "plugin-node"}).probe(ctx, client, "env", "plugin"),
+		"2 of 3 pods are ready, expected 3")
+	require.NoError(t, (&PodsReadyProbe{App: "geth"}).probe(ctx, client, "env", "plugin"))
+	require.EqualError(t, (&PodsReadyProbe{App: "missing"}).probe(ctx, client, "env", "plugin"),
+		"no pods match app=missing,app.kubernetes.io/instance=plugin")
+}
+
+func TestRunProbes(t *testing.T) {
+	t.Parallel()
+
+	env := &Environment{Config: &Config{}}
+	results, err := env.RunProbes(context.Background(), ProbeDuring,
+		&FuncProbe{ProbeName: "ok", Func: func(ctx context.Context, env *Environment) error { return nil }},
+		&FuncProbe{ProbeName: "down", Func: func(ctx context.Context, env *Environment) error {
+			time.Sleep(time.Millisecond)
+			return errors.New("node is down")
+		}},
+	)
+	require.EqualError(t, err, "during probes failed: down: node is down")
+	var probeErr *ProbeError
+	require.True(t, errors.As(err, &probeErr))
+	require.Len(t, results, 2)
+	require.True(t, results[0].Passed)
+	require.Equal(t, ProbeDuring, results[0].Phase)
+	require.False(t, results[1].Passed)
+	require.Equal(t, "node is down", results[1].Error)
+	require.GreaterOrEqual(t, results[1].Duration, time.Millisecond)
+
+	results, err = env.RunProbes(context.Background(), ProbeAfter, &FuncProbe{
+		ProbeName: "slow",
+		Timeout:   10 * time.Millisecond,
+		Func: func(ctx context.Context, env *Environment) error {
+			<-ctx.Done()
+			return ctx.Err()
+		},
+	})
+	require.EqualError(t, err, "after probes failed: slow: context deadline exceeded")
+	require.Less(t, results[0].Duration, DefaultProbeTimeout)
+}
+
+func TestApplyChaosExperimentProbes(t *testing.T) {
+	t.Parallel()
+
+	var requests int32
+	cc, err := chaos.NewController(&chaos.Config{
+		NamespaceName: "env",
+		RESTClient: &restfake.RESTClient{
+			NegotiatedSerializer: scheme.Codecs.WithoutConversion(),
+			GroupVersion:         schema.GroupVersion{Version: "v1"},
+			Client: restfake.CreateHTTPClient(func(req *http.Request) (*http.Response, error) {
+				atomic.AddInt32(&requests, 1)
+				return &http.Response{
+					StatusCode: http.StatusOK,
+					Header:     http.Header{"Content-Type": []string{"application/json"}},
+					Body:       io.NopCloser(strings.NewReader(`{}`)),
+				}, nil
+			}),
+		},
+	})
+	require.NoError(t, err)
+	env := &Environment{Config: &Config{}, Chaos: cc}
+	var phases []string
+	probe := func(name string, err error) Probe {
+		return &FuncProbe{ProbeName: name, Func: func(ctx context.Context, env *Environment) error {
+			phases = append(phases, name)
+			return err
+		}}
+	}
+
+	_, err = env.ApplyChaosExperiment(&experiments.PodKill{Mode: "one"}, ChaosProbes{Before: []Probe{probe("unhealthy", errors.New("down"))}})
+	require.EqualError(t, err, "before probes failed: unhealthy: down")
+	require.Zero(t, atomic.LoadInt32(&requests), "experiment must not be applied")
+
+	name, err := env.ApplyChaosExperiment(&experiments.PodKill{Mode: "one"}, ChaosProbes{
+		Before: []Probe{probe("before", nil)},
+		During: []Probe{probe("during", nil)},
+		After:  []Probe{probe("after", nil)},
+	})
+	require.NoError(t, err)
+	require.Equal(t, []string{"unhealthy", "before", "during"}, phases)
+	require.NoError(t, env.StopChaosExperiment(name))
+	require.Equal(t, []string{"unhealthy", "before", "during", "after"}, phases)
+	results := env.ChaosProbeResults(name)
+	require.Len(t, results, 3)
+	for i, phase := range []string{ProbeBefore, ProbeDuring, ProbeAfter} {
+		require.Equal(t, phase, results[i].Phase)
+		require.True(t, results[i].Passed)
+	}
+}
+
+func TestScenarioCheckProbe(t *testing.T) {
+	t.Parallel()
+
+	env := probeEnv(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"status":"passing"}`))
+	}))
+	path := filepath.Join(t.TempDir(), "scenario.yml")
+	require.NoError(t, os.WriteFile(path, []byte(`
+name: checks
+experiments:
+  - name: kill
+    template: pod-kill.yml
+    checks: [health, failing, nodes]
+checks:
+  - name: health
+    http:
+      chart: plugin
+      connection: plugin-node_0_node
+      path: /health
+      body_match: passing
+    timeout: 5s
+  - name: failing
+    http:
+      chart: plugin
+      connection: plugin-node_0_node
+      body_match: failing
+  - name: nodes
+    pods_ready:
+      chart: plugin
+      app: plugin-node
+      min: 2
+`), 0600))
+	s, err := chaos.LoadScenario(path)
+	require.NoError(t, err)
+	require.NoError(t, s.Validate())
+
+	require.Equal(t, &HTTPProbe{
+		ProbeName: "health",
+		Endpoint:  ProbeEndpoint{Chart: "plugin", Connection: "plugin-node_0_node"},
+		Path:      "/health",
+		BodyMatch: "passing",
+		Timeout:   5 * time.Second,
+	}, ScenarioCheckProbe(s.Checks[0]))
+	require.Equal(t, &PodsReadyProbe{ProbeName: "nodes", Chart: "plugin", App: "plugin-node", Min: 2},
+		ScenarioCheckProbe(s.Checks[2]))
+
+	env.resolveScenarioChecks(s)
+	require.NoError(t, s.Checks[0].Func(context.Background()))
+	require.EqualError(t, s.Checks[1].Func(context.Background()), "check probes failed: failing: body doesn't match failing")
+}
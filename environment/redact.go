@@ -0,0 +1,345 @@
+package environment
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+)
+
+// RedactedValue replaces every redacted secret
+const RedactedValue = "[REDACTED]"
+
+var (
+	// DefaultRedactKeys case-insensitive key substrings, values of matching keys are always redacted
+	DefaultRedactKeys = []string{
+		"password",
+		"passwd",
+		"secret",
+		"token",
+		"api_key",
+		"apikey",
+		"private_key",
+		"access_key",
+		"credential",
+		"slack_api",
+		"slack_webhook",
+	}
+	// DefaultRedactPatterns regexes of secrets redacted in any text, if a regex has a group only the first group
+	// is redacted
+	DefaultRedactPatterns = []string{
+		`xox[abprs]-[0-9A-Za-z-]+`,
+		`https://hooks\.slack\.com/services/[\w/]+`,
+		`(?i)postgres(?:ql)?://[^:/\s]+:([^@\s]+)@`,
+	}
+)
+
+// Redactor masks secrets in chart values, logs and dumped artifacts
+type Redactor struct {
+	keys     []string
+	patterns []*regexp.Regexp
+	keyText  *regexp.Regexp
+	disabled bool
+}
+
+// RedactGlobalLogs makes NewEnvironment redact the global zerolog logger with the config redactor, see RedactLogs,
+// set it to false to keep log.Logger output as it is
+var RedactGlobalLogs = true
+
+// ansiColors terminal color codes zerolog.ConsoleWriter puts around field names
+const ansiColors = `(?:\x1b\[[0-9;]*m)*`
+
+// NewRedactor creates a redactor for the default keys and patterns extended with custom ones,
+// disabled redactor leaves everything as is, which is useful for local debugging
+func NewRedactor(keys []string, patterns []string, disabled bool) (*Redactor, error) {
+	r := &Redactor{disabled: disabled}
+	for _, k := range append(append([]string{}, DefaultRedactKeys...), keys...) {
+		if k = strings.Join(keyWords(k), ""); len(k) > 0 {
+			r.keys = append(r.keys, k)
+		}
+	}
+	for _, p := range append(append([]string{}, DefaultRedactPatterns...), patterns...) {
+		re, err := regexp.Compile(p)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid redact pattern %s", p)
+		}
+		r.patterns = append(r.patterns, re)
+	}
+	quoted := make([]string, 0, len(r.keys))
+	for _, k := range r.keys {
+		// words of a key may be separated in the text, for example api_key or api-key
+		letters := make([]string, 0, len(k))
+		for _, c := range k {
+			letters = append(letters, regexp.QuoteMeta(string(c)))
+		}
+		quoted = append(quoted, strings.Join(letters, "[_.-]?"))
+	}
+	// matches key=value, key: value and "key":"value" forms, groups are key, separator, value quote and value,
+	// matched keys are checked with IsSecretKey
+	r.keyText = regexp.MustCompile(fmt.Sprintf(
+		`(?i)([\w.-]*(?:%s)[\w.-]*)(["']?%s[ \t]*[:=]%s[ \t]*)(["']?)([^\s"',{}\[\]\x1b]+)`,
+		strings.Join(quoted, "|"), ansiColors, ansiColors,
+	))
+	return r, nil
+}
+
+// Enabled returns true if redactor masks secrets
+func (r *Redactor) Enabled() bool {
+	return r != nil && !r.disabled
+}
+
+// IsSecretKey returns true if values of the key must be redacted, a redact key must end where a word
+// of the key ends, so PGPASSWORD and apiTokens are secret, but tokenizer isn't. Keys ending with name or ref,
+// like secretName or secretKeyRef, refer to Kubernetes secrets and aren't secret
+func (r *Redactor) IsSecretKey(key string) bool {
+	if !r.Enabled() {
+		return false
+	}
+	words := keyWords(key)
+	if len(words) == 0 {
+		return false
+	}
+	if last := words[len(words)-1]; last == "name" || last == "ref" {
+		return false
+	}
+	joined := ""
+	for _, w := range words {
+		joined += w
+		for _, k := range r.keys {
+			if strings.HasSuffix(joined, k) || strings.HasSuffix(joined, k+"s") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// keyWords splits a key into lower case words on separators and camel case, APIKey is api and key
+func keyWords(key string) []string {
+	var words []string
+	var word []rune
+	runes := []rune(key)
+	flush := func() {
+		if len(word) > 0 {
+			words = append(words, strings.ToLower(string(word)))
+			word = nil
+		}
+	}
+	for i, c := range runes {
+		if !unicode.IsLetter(c) && !unicode.IsDigit(c) {
+			flush()
+			continue
+		}
+		if unicode.IsUpper(c) && i > 0 {
+			prev := runes[i-1]
+			nextLower := i+1 < len(runes) && unicode.IsLower(runes[i+1])
+			if unicode.IsLower(prev) || unicode.IsDigit(prev) || (unicode.IsUpper(prev) && nextLower) {
+				flush()
+			}
+		}
+		word = append(word, c)
+	}
+	flush()
+	return words
+}
+
+// Map returns a redacted deep copy of the values, scalars under secret keys are replaced with RedactedValue
+func (r *Redactor) Map(values map[string]interface{}) map[string]interface{} {
+	if !r.Enabled() || values == nil {
+		return values
+	}
+	return r.value(values, false).(map[string]interface{})
+}
+
+func (r *Redactor) value(v interface{}, secret bool) interface{} {
+	switch val := v.(type) {
+	case map[string]interface{}:
+		res := make(map[string]interface{}, len(val))
+		for k, item := range val {
+			res[k] = r.value(item, secret || r.IsSecretKey(k))
+		}
+		return res
+	case []interface{}:
+		res := make([]interface{}, len(val))
+		for i, item := range val {
+			res[i] = r.value(item, secret)
+		}
+		return res
+	case nil:
+		return nil
+	case string:
+		if secret {
+			return RedactedValue
+		}
+		return r.String(val)
+	default:
+		if secret {
+			return RedactedValue
+		}
+		return val
+	}
+}
+
+// String redacts secret patterns and values of secret keys in any text
+func (r *Redactor) String(s string) string {
+	if !r.Enabled() {
+		return s
+	}
+	s = r.replaceKeyValues(s)
+	for _, re := range r.patterns {
+		s = replaceFirstGroup(re, s)
+	}
+	return s
+}
+
+// replaceKeyValues redacts values of secret keys, unquoted JSON values such as numbers are replaced
+// with a quoted string, so JSON stays valid
+func (r *Redactor) replaceKeyValues(s string) string {
+	var b strings.Builder
+	last := 0
+	for _, m := range r.keyText.FindAllStringSubmatchIndex(s, -1) {
+		if !r.IsSecretKey(s[m[2]:m[3]]) {
+			continue
+		}
+		b.WriteString(s[last:m[8]])
+		separator, quote := s[m[4]:m[5]], s[m[6]:m[7]]
+		if len(quote) == 0 && strings.HasPrefix(separator, `"`) {
+			b.WriteString(strconv.Quote(RedactedValue))
+		} else {
+			b.WriteString(RedactedValue)
+		}
+		last = m[9]
+	}
+	b.WriteString(s[last:])
+	return b.String()
+}
+
+// replaceFirstGroup replaces the first group of every regex match, or the whole match if regex has no groups
+func replaceFirstGroup(re *regexp.Regexp, s string) string {
+	if re.NumSubexp() == 0 {
+		return re.ReplaceAllLiteralString(s, RedactedValue)
+	}
+	var b strings.Builder
+	last := 0
+	for _, m := range re.FindAllStringSubmatchIndex(s, -1) {
+		if m[2] < 0 {
+			continue
+		}
+		b.WriteString(s[last:m[2]])
+		b.WriteString(RedactedValue)
+		last = m[3]
+	}
+	b.WriteString(s[last:])
+	return b.String()
+}
+
+// Writer wraps w so that every write is redacted, can be used as a zerolog output, or as the output
+// of zerolog.ConsoleWriter so events are redacted once formatted
+//
+//	log.Logger = log.Output(zerolog.ConsoleWriter{Out: redactor.Writer(os.Stderr)})
+func (r *Redactor) Writer(w io.Writer) io.Writer {
+	if !r.Enabled() {
+		return w
+	}
+	return &redactWriter{r: r, w: w}
+}
+
+type redactWriter struct {
+	r *Redactor
+	w io.Writer
+}
+
+// RedactLogs sets output of the global zerolog logger to zerolog.ConsoleWriter on stderr redacted with r,
+// level and context of the logger are kept, a disabled redactor leaves the logger as it is
+func RedactLogs(r *Redactor) {
+	if !r.Enabled() {
+		return
+	}
+	log.Logger = log.Output(zerolog.ConsoleWriter{Out: r.Writer(os.Stderr)})
+}
+
+// Write redacts p and writes it to the underlying writer, zerolog writes one event per call
+func (rw *redactWriter) Write(p []byte) (int, error) {
+	redacted := rw.r.String(string(p))
+	if _, err := rw.w.Write([]byte(redacted)); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// containsRedacted returns true if any of the values was redacted
+func containsRedacted(v interface{}) bool {
+	switch val := v.(type) {
+	case map[string]interface{}:
+		for _, item := range val {
+			if containsRedacted(item) {
+				return true
+			}
+		}
+	case []interface{}:
+		for _, item := range val {
+			if containsRedacted(item) {
+				return true
+			}
+		}
+	case string:
+		return strings.Contains(val, RedactedValue)
+	}
+	return false
+}
+
+// restoreRedacted replaces redacted values with the values found at the same path in source
+func restoreRedacted(values, source map[string]interface{}) {
+	for k, v := range values {
+		if restored, ok := restoreRedactedValue(v, source[k]); ok {
+			values[k] = restored
+		}
+	}
+}
+
+// restoreRedactedValue returns source if v was redacted, entirely or by a pattern, nested maps and lists
+// are restored in place
+func restoreRedactedValue(v, source interface{}) (interface{}, bool) {
+	switch val := v.(type) {
+	case map[string]interface{}:
+		if src, ok := source.(map[string]interface{}); ok {
+			restoreRedacted(val, src)
+		}
+	case []interface{}:
+		if src, ok := source.([]interface{}); ok && len(src) == len(val) {
+			for i := range val {
+				if restored, ok := restoreRedactedValue(val[i], src[i]); ok {
+					val[i] = restored
+				}
+			}
+		}
+	case string:
+		return source, source != nil && strings.Contains(val, RedactedValue)
+	}
+	return nil, false
+}
+
+// redactLines redacts every line of data and writes it to w
+func redactLines(r *Redactor, w io.Writer, data []byte) error {
+	if !r.Enabled() {
+		_, err := w.Write(data)
+		return err
+	}
+	for _, line := range bytes.SplitAfter(data, []byte("\n")) {
+		if len(line) == 0 {
+			continue
+		}
+		if _, err := io.WriteString(w, r.String(string(line))); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,89 @@
+package environment
+
+import (
+	"bytes"
+	"io"
+
+	"gopkg.in/yaml.v3"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// ReleaseLabelKey standard Helm label with the release name, helmenv adds it to pods of every chart workload,
+// so pods are selected the same way whether the chart sets the label or not
+const ReleaseLabelKey = "app.kubernetes.io/instance"
+
+// podTemplatePaths paths of pod labels by kind of a workload
+var podTemplatePaths = map[string][]string{
+	"Pod":                   {"metadata", "labels"},
+	"Deployment":            {"spec", "template", "metadata", "labels"},
+	"StatefulSet":           {"spec", "template", "metadata", "labels"},
+	"DaemonSet":             {"spec", "template", "metadata", "labels"},
+	"ReplicaSet":            {"spec", "template", "metadata", "labels"},
+	"ReplicationController": {"spec", "template", "metadata", "labels"},
+	"Job":                   {"spec", "template", "metadata", "labels"},
+	"CronJob":               {"spec", "jobTemplate", "spec", "template", "metadata", "labels"},
+}
+
+// releaseLabels returns labels of the release pods, of the app pods when app isn't empty
+func releaseLabels(releaseName, app string) labels.Set {
+	set := labels.Set{ReleaseLabelKey: releaseName}
+	if len(app) > 0 {
+		set[AppEnumerationLabelKey] = app
+	}
+	return set
+}
+
+// releaseLabeler Helm post renderer adding ReleaseLabelKey to pods of the chart workloads,
+// a label the chart already sets is kept, so workload selectors still match
+type releaseLabeler struct {
+	releaseName string
+}
+
+// Run adds the release label to pod templates of rendered manifests
+func (l *releaseLabeler) Run(renderedManifests *bytes.Buffer) (*bytes.Buffer, error) {
+	dec := yaml.NewDecoder(renderedManifests)
+	out := &bytes.Buffer{}
+	enc := yaml.NewEncoder(out)
+	enc.SetIndent(2)
+	for {
+		var doc map[string]interface{}
+		err := dec.Decode(&doc)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if len(doc) == 0 {
+			continue
+		}
+		if kind, ok := doc["kind"].(string); ok {
+			if p, ok := podTemplatePaths[kind]; ok {
+				setLabel(doc, p, ReleaseLabelKey, l.releaseName)
+			}
+		}
+		if err := enc.Encode(doc); err != nil {
+			return nil, err
+		}
+	}
+	if err := enc.Close(); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// setLabel sets the label in labels of the path unless it's already set, missing maps are created
+func setLabel(doc map[string]interface{}, path []string, key, value string) {
+	m := doc
+	for _, p := range path {
+		next, ok := m[p].(map[string]interface{})
+		if !ok {
+			next = map[string]interface{}{}
+			m[p] = next
+		}
+		m = next
+	}
+	if _, ok := m[key]; !ok {
+		m[key] = value
+	}
+}
@@ -0,0 +1,83 @@
+package environment
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+func TestReleaseLabeler(t *testing.T) {
+	t.Parallel()
+
+	manifests := `---
+# Source: busybox/templates/deployment.yaml
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: busybox
+spec:
+  selector:
+    matchLabels:
+      app: busybox
+  template:
+    metadata:
+      labels:
+        app: busybox
+---
+# Source: busybox/templates/cronjob.yaml
+apiVersion: batch/v1
+kind: CronJob
+metadata:
+  name: cleanup
+spec:
+  jobTemplate:
+    spec:
+      template:
+        spec:
+          restartPolicy: Never
+---
+# Source: busybox/templates/pod.yaml
+apiVersion: v1
+kind: Pod
+metadata:
+  name: standard
+  labels:
+    app.kubernetes.io/instance: standard
+---
+# Source: busybox/templates/service.yaml
+apiVersion: v1
+kind: Service
+metadata:
+  name: busybox
+`
+	out, err := (&releaseLabeler{releaseName: "busybox"}).Run(bytes.NewBufferString(manifests))
+	require.NoError(t, err)
+	dec := yaml.NewDecoder(out)
+	var docs []map[string]interface{}
+	for {
+		var doc map[string]interface{}
+		if err := dec.Decode(&doc); err != nil {
+			break
+		}
+		docs = append(docs, doc)
+	}
+	require.Len(t, docs, 4)
+	field := func(doc map[string]interface{}, path ...string) interface{} {
+		var v interface{} = doc
+		for _, p := range path {
+			v = v.(map[string]interface{})[p]
+		}
+		return v
+	}
+	require.Equal(t, map[string]interface{}{"app": "busybox", ReleaseLabelKey: "busybox"},
+		field(docs[0], "spec", "template", "metadata", "labels"))
+	require.Equal(t, map[string]interface{}{"app": "busybox"},
+		field(docs[0], "spec", "selector", "matchLabels"), "selectors must not be changed")
+	require.Equal(t, map[string]interface{}{ReleaseLabelKey: "busybox"},
+		field(docs[1], "spec", "jobTemplate", "spec", "template", "metadata", "labels"))
+	require.Equal(t, map[string]interface{}{ReleaseLabelKey: "standard"},
+		field(docs[2], "metadata", "labels"), "labels set by the chart must be kept")
+	require.NotContains(t, docs[3]["metadata"], "labels")
+}
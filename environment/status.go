@@ -0,0 +1,194 @@
+package environment
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"text/tabwriter"
+	"time"
+
+	"helm.sh/helm/v3/pkg/release"
+	v1 "k8s.io/api/core/v1"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// Status state of the deployed environment, see Environment.Status
+type Status struct {
+	Namespace string         `json:"namespace" yaml:"namespace"`
+	Time      time.Time      `json:"time" yaml:"time"`
+	Charts    []*ChartStatus `json:"charts" yaml:"charts"`
+}
+
+// ChartStatus Helm release of a chart, its pods and connections
+type ChartStatus struct {
+	Name     string `json:"name" yaml:"name"`
+	Release  string `json:"release" yaml:"release"`
+	Chart    string `json:"chart,omitempty" yaml:"chart,omitempty"`
+	Status   string `json:"status" yaml:"status"`
+	Revision int    `json:"revision" yaml:"revision"`
+	// Error why the release status is unknown
+	Error       string              `json:"error,omitempty" yaml:"error,omitempty"`
+	Pods        []*PodStatus        `json:"pods" yaml:"pods"`
+	Connections []*ConnectionStatus `json:"connections" yaml:"connections"`
+}
+
+// PodStatus phase, readiness and restarts of a chart pod
+type PodStatus struct {
+	Name string `json:"name" yaml:"name"`
+	// Phase pod phase, or the reason a container is waiting, for example CrashLoopBackOff
+	Phase    string `json:"phase" yaml:"phase"`
+	Ready    int    `json:"ready" yaml:"ready"`
+	Total    int    `json:"total" yaml:"total"`
+	Restarts int32  `json:"restarts" yaml:"restarts"`
+	IP       string `json:"ip,omitempty" yaml:"ip,omitempty"`
+}
+
+// ConnectionStatus port of a chart connection, Local is empty when the port isn't forwarded
+type ConnectionStatus struct {
+	Key    string `json:"key" yaml:"key"`
+	Pod    string `json:"pod" yaml:"pod"`
+	Port   string `json:"port" yaml:"port"`
+	Remote string `json:"remote" yaml:"remote"`
+	Local  string `json:"local,omitempty" yaml:"local,omitempty"`
+}
+
+// Status returns Helm release status of every chart, with pods of the release and chart connections
+func (k *Environment) Status() (*Status, error) {
+	s := &Status{Namespace: k.Namespace, Time: time.Now()}
+	for _, name := range k.chartNames() {
+		hc := k.Charts[name]
+		pods, err := k.k8sClient.CoreV1().Pods(k.Namespace).List(context.Background(), metaV1.ListOptions{
+			LabelSelector: labels.SelectorFromSet(releaseLabels(hc.ReleaseName, "")).String(),
+		})
+		if err != nil {
+			return nil, err
+		}
+		rel, err := hc.Release()
+		cs := chartStatus(name, hc, rel, pods.Items)
+		if err != nil {
+			cs.Error = err.Error()
+		}
+		s.Charts = append(s.Charts, cs)
+	}
+	return s, nil
+}
+
+// chartNames returns chart names sorted by index and name
+func (k *Environment) chartNames() []string {
+	names := make([]string, 0, len(k.Charts))
+	for name := range k.Charts {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		ci, cj := k.Charts[names[i]], k.Charts[names[j]]
+		if ci.Index != cj.Index {
+			return ci.Index < cj.Index
+		}
+		return names[i] < names[j]
+	})
+	return names
+}
+
+func chartStatus(name string, hc *HelmChart, rel *release.Release, pods []v1.Pod) *ChartStatus {
+	cs := &ChartStatus{
+		Name:        name,
+		Release:     hc.ReleaseName,
+		Status:      "unknown",
+		Pods:        make([]*PodStatus, 0, len(pods)),
+		Connections: make([]*ConnectionStatus, 0),
+	}
+	if rel != nil {
+		cs.Revision = rel.Version
+		if rel.Info != nil {
+			cs.Status = rel.Info.Status.String()
+		}
+		if rel.Chart != nil && rel.Chart.Metadata != nil {
+			cs.Chart = fmt.Sprintf("%s-%s", rel.Chart.Metadata.Name, rel.Chart.Metadata.Version)
+		}
+	}
+	sort.Slice(pods, func(i, j int) bool {
+		return pods[i].Name < pods[j].Name
+	})
+	for _, pod := range pods {
+		cs.Pods = append(cs.Pods, podStatus(pod))
+	}
+	keys := make([]string, 0, len(hc.ChartConnections))
+	for key := range hc.ChartConnections {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		cc := hc.ChartConnections[key]
+		ports := make([]string, 0, len(cc.RemotePorts))
+		for port := range cc.RemotePorts {
+			ports = append(ports, port)
+		}
+		sort.Strings(ports)
+		for _, port := range ports {
+			conn := &ConnectionStatus{
+				Key:    key,
+				Pod:    cc.PodName,
+				Port:   port,
+				Remote: fmt.Sprintf("%s:%d", cc.PodIP, cc.RemotePorts[port]),
+			}
+			if local, ok := cc.LocalPorts[port]; ok {
+				conn.Local = fmt.Sprintf("localhost:%d", local)
+			}
+			cs.Connections = append(cs.Connections, conn)
+		}
+	}
+	return cs
+}
+
+func podStatus(pod v1.Pod) *PodStatus {
+	ps := &PodStatus{
+		Name:  pod.Name,
+		Phase: string(pod.Status.Phase),
+		Total: len(pod.Spec.Containers),
+		IP:    pod.Status.PodIP,
+	}
+	for _, c := range pod.Status.ContainerStatuses {
+		if c.Ready {
+			ps.Ready++
+		}
+		ps.Restarts += c.RestartCount
+		if c.State.Waiting != nil && len(c.State.Waiting.Reason) > 0 {
+			ps.Phase = c.State.Waiting.Reason
+		}
+	}
+	if pod.DeletionTimestamp != nil {
+		ps.Phase = "Terminating"
+	}
+	return ps
+}
+
+// WriteTable writes releases, pods and connections as tables
+func (s *Status) WriteTable(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(tw, "Namespace:\t%s\n\n", s.Namespace)
+	fmt.Fprintln(tw, "CHART\tRELEASE\tSTATUS\tREVISION\tVERSION\tERROR")
+	for _, c := range s.Charts {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%d\t%s\t%s\n", c.Name, c.Release, c.Status, c.Revision, c.Chart, c.Error)
+	}
+	fmt.Fprintln(tw)
+	fmt.Fprintln(tw, "CHART\tPOD\tPHASE\tREADY\tRESTARTS\tIP")
+	for _, c := range s.Charts {
+		for _, p := range c.Pods {
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%d/%d\t%d\t%s\n", c.Name, p.Name, p.Phase, p.Ready, p.Total, p.Restarts, p.IP)
+		}
+	}
+	fmt.Fprintln(tw)
+	fmt.Fprintln(tw, "CHART\tCONNECTION\tPOD\tPORT\tREMOTE\tLOCAL")
+	for _, c := range s.Charts {
+		for _, conn := range c.Connections {
+			local := conn.Local
+			if len(local) == 0 {
+				local = "-"
+			}
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\n", c.Name, conn.Key, conn.Pod, conn.Port, conn.Remote, local)
+		}
+	}
+	return tw.Flush()
+}
@@ -0,0 +1,69 @@
+package environment
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/release"
+	v1 "k8s.io/api/core/v1"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func statusPod(name string, phase v1.PodPhase, statuses ...v1.ContainerStatus) v1.Pod {
+	return v1.Pod{
+		ObjectMeta: metaV1.ObjectMeta{Name: name},
+		Spec:       v1.PodSpec{Containers: []v1.Container{{Name: "node"}, {Name: "plugin-db"}}},
+		Status:     v1.PodStatus{Phase: phase, PodIP: "10.0.0.1", ContainerStatuses: statuses},
+	}
+}
+
+func TestChartStatus(t *testing.T) {
+	t.Parallel()
+
+	hc := chaosTargetEnv().Charts["plugin"]
+	hc.ChartConnections["plugin-node_0_node"].PodIP = "10.0.0.1"
+	hc.ChartConnections["plugin-node_0_node"].RemotePorts = map[string]int{"access": 6688, "p2p": 6690}
+	hc.ChartConnections["plugin-node_0_node"].LocalPorts = map[string]int{"access": 50000}
+	rel := &release.Release{
+		Version: 3,
+		Info:    &release.Info{Status: release.StatusDeployed},
+		Chart:   &chart.Chart{Metadata: &chart.Metadata{Name: "plugin", Version: "0.1.0"}},
+	}
+	cs := chartStatus("plugin", hc, rel, []v1.Pod{
+		statusPod("plugin-node-1", v1.PodPending,
+			v1.ContainerStatus{RestartCount: 4, State: v1.ContainerState{Waiting: &v1.ContainerStateWaiting{Reason: "CrashLoopBackOff"}}},
+			v1.ContainerStatus{Ready: true},
+		),
+		statusPod("plugin-node-0", v1.PodRunning, v1.ContainerStatus{Ready: true}, v1.ContainerStatus{Ready: true, RestartCount: 1}),
+	})
+	require.Equal(t, "deployed", cs.Status)
+	require.Equal(t, 3, cs.Revision)
+	require.Equal(t, "plugin-0.1.0", cs.Chart)
+	require.Equal(t, []*PodStatus{
+		{Name: "plugin-node-0", Phase: "Running", Ready: 2, Total: 2, Restarts: 1, IP: "10.0.0.1"},
+		{Name: "plugin-node-1", Phase: "CrashLoopBackOff", Ready: 1, Total: 2, Restarts: 4, IP: "10.0.0.1"},
+	}, cs.Pods)
+	require.Equal(t, &ConnectionStatus{
+		Key:    "plugin-node_0_node",
+		Pod:    "plugin-node-0",
+		Port:   "access",
+		Remote: "10.0.0.1:6688",
+		Local:  "localhost:50000",
+	}, cs.Connections[0])
+	require.Equal(t, "p2p", cs.Connections[1].Port)
+	require.Empty(t, cs.Connections[1].Local)
+
+	unknown := chartStatus("geth", &HelmChart{ReleaseName: "geth"}, nil, nil)
+	require.Equal(t, "unknown", unknown.Status)
+	require.NotNil(t, unknown.Pods)
+
+	var buf bytes.Buffer
+	require.NoError(t, (&Status{Namespace: "env", Charts: []*ChartStatus{cs, unknown}}).WriteTable(&buf))
+	out := buf.String()
+	require.True(t, strings.HasPrefix(out, "Namespace:  env"))
+	require.Contains(t, out, "plugin-node-1  CrashLoopBackOff  1/2")
+	require.Regexp(t, `plugin-node_0_node\s+plugin-node-0\s+p2p\s+10.0.0.1:6690\s+-`, out)
+}
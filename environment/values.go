@@ -0,0 +1,135 @@
+package environment
+
+import (
+	"reflect"
+	"sort"
+
+	"github.com/rs/zerolog/log"
+	"helm.sh/helm/v3/pkg/cli"
+	"helm.sh/helm/v3/pkg/cli/values"
+	"helm.sh/helm/v3/pkg/getter"
+)
+
+// ValueChange chart value changed by an upgrade, Old is nil for added values and New is nil for removed ones
+type ValueChange struct {
+	Key string
+	Old interface{}
+	New interface{}
+}
+
+// ReadValueOverrides reads values files and key=value pairs the same way helm -f and --set do,
+// pairs override values from files
+func ReadValueOverrides(valueFiles, sets []string) (map[string]interface{}, error) {
+	opts := &values.Options{ValueFiles: valueFiles, Values: sets}
+	return opts.MergeValues(getter.All(cli.New()))
+}
+
+// ChartValuesDiff returns chart values changed by deep merging overrides, secrets are redacted
+func (k *Environment) ChartValuesDiff(chartName string, overrides map[string]interface{}) ([]ValueChange, error) {
+	chart, err := k.Charts.Get(chartName)
+	if err != nil {
+		return nil, err
+	}
+	r, err := k.Config.Redactor()
+	if err != nil {
+		return nil, err
+	}
+	merged := mergeValues(chart.Values, overrides)
+	changes := ValuesDiff(chart.Values, merged)
+	// changed secrets are shown, but not their values
+	oldRedacted, newRedacted := map[string]interface{}{}, map[string]interface{}{}
+	flattenValues("", r.Map(chart.Values), oldRedacted)
+	flattenValues("", r.Map(merged), newRedacted)
+	for i, c := range changes {
+		if c.Old != nil {
+			changes[i].Old = oldRedacted[c.Key]
+		}
+		if c.New != nil {
+			changes[i].New = newRedacted[c.Key]
+		}
+	}
+	return changes, nil
+}
+
+// UpgradeValues deep merges overrides into the chart values, upgrades the chart and persists the config,
+// values are left as they were if the Helm upgrade fails, and are kept and persisted once the release is upgraded,
+// even if reading its pods fails afterwards
+func (k *Environment) UpgradeValues(chartName string, overrides map[string]interface{}) error {
+	chart, err := k.Charts.Get(chartName)
+	if err != nil {
+		return err
+	}
+	previous := chart.Values
+	chart.Values = mergeValues(previous, overrides)
+	log.Info().Str("Chart", chartName).Msg("Upgrading chart values")
+	if err := chart.upgradeChart(); err != nil {
+		chart.Values = previous
+		return err
+	}
+	// the release is upgraded already, so values must match it even if chart settings aren't refreshed
+	refreshErr := chart.refreshSettings()
+	if err := k.SyncConfig(); err != nil {
+		return err
+	}
+	return refreshErr
+}
+
+// mergeValues returns a copy of values with overrides merged in, nested maps are merged and anything else is replaced
+func mergeValues(values, overrides map[string]interface{}) map[string]interface{} {
+	res := make(map[string]interface{}, len(values))
+	for k, v := range values {
+		res[k] = v
+	}
+	for k, v := range overrides {
+		override, ok := v.(map[string]interface{})
+		if !ok {
+			res[k] = v
+			continue
+		}
+		if current, ok := res[k].(map[string]interface{}); ok {
+			res[k] = mergeValues(current, override)
+		} else {
+			res[k] = mergeValues(nil, override)
+		}
+	}
+	return res
+}
+
+// ValuesDiff returns values changed between old and new sorted by key, nested keys are joined with dots
+func ValuesDiff(old, new map[string]interface{}) []ValueChange {
+	oldFlat, newFlat := map[string]interface{}{}, map[string]interface{}{}
+	flattenValues("", old, oldFlat)
+	flattenValues("", new, newFlat)
+	var changes []ValueChange
+	for k, v := range oldFlat {
+		if nv, ok := newFlat[k]; !ok {
+			changes = append(changes, ValueChange{Key: k, Old: v})
+		} else if !reflect.DeepEqual(v, nv) {
+			changes = append(changes, ValueChange{Key: k, Old: v, New: nv})
+		}
+	}
+	for k, v := range newFlat {
+		if _, ok := oldFlat[k]; !ok {
+			changes = append(changes, ValueChange{Key: k, New: v})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Key < changes[j].Key
+	})
+	return changes
+}
+
+// flattenValues flattens nested maps into dotted keys, lists and empty maps are kept as values
+func flattenValues(prefix string, values map[string]interface{}, res map[string]interface{}) {
+	for k, v := range values {
+		key := k
+		if len(prefix) > 0 {
+			key = prefix + "." + k
+		}
+		if m, ok := v.(map[string]interface{}); ok && len(m) > 0 {
+			flattenValues(key, m, res)
+			continue
+		}
+		res[key] = v
+	}
+}
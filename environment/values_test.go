@@ -0,0 +1,63 @@
+package environment
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadValueOverrides(t *testing.T) {
+	t.Parallel()
+
+	file := filepath.Join(t.TempDir(), "values.yaml")
+	require.NoError(t, os.WriteFile(file, []byte("plugin:\n  image:\n    version: 1.5.0\n  replicas: 2\n"), 0644))
+	overrides, err := ReadValueOverrides([]string{file}, []string{"plugin.image.version=1.6.0"})
+	require.NoError(t, err)
+	require.Equal(t, map[string]interface{}{
+		"plugin": map[string]interface{}{
+			"image":    map[string]interface{}{"version": "1.6.0"},
+			"replicas": float64(2),
+		},
+	}, overrides)
+}
+
+func TestChartValuesDiff(t *testing.T) {
+	t.Parallel()
+
+	values := map[string]interface{}{
+		"plugin": map[string]interface{}{
+			"image":    map[string]interface{}{"image": "plugin", "version": "1.5.0"},
+			"password": "secret",
+			"env":      map[string]interface{}{"feature": "on"},
+		},
+		"replicas": 1,
+	}
+	env := &Environment{Config: &Config{Charts: Charts{"plugin": &HelmChart{ReleaseName: "plugin", Values: values}}}}
+	overrides := map[string]interface{}{
+		"plugin": map[string]interface{}{
+			"image":    map[string]interface{}{"version": "1.6.0"},
+			"password": "other",
+			"env":      "none",
+		},
+		"db": map[string]interface{}{"stateful": true},
+	}
+	changes, err := env.ChartValuesDiff("plugin", overrides)
+	require.NoError(t, err)
+	require.Equal(t, []ValueChange{
+		{Key: "db.stateful", New: true},
+		{Key: "plugin.env", New: "none"},
+		{Key: "plugin.env.feature", Old: "on"},
+		{Key: "plugin.image.version", Old: "1.5.0", New: "1.6.0"},
+		{Key: "plugin.password", Old: RedactedValue, New: RedactedValue},
+	}, changes)
+
+	merged := mergeValues(values, overrides)
+	require.Equal(t, "plugin", merged["plugin"].(map[string]interface{})["image"].(map[string]interface{})["image"])
+	require.Equal(t, "1.5.0", values["plugin"].(map[string]interface{})["image"].(map[string]interface{})["version"],
+		"values are not modified")
+
+	_, err = env.ChartValuesDiff("geth", overrides)
+	require.Error(t, err)
+}
@@ -24,8 +24,8 @@ func main() {
 	}
 	defer e.DeferTeardown()
 
-	time.Sleep(10 * time.Second)
-	_, err = e.ApplyChaosExperiment(&experiments.PodFailure{
+	name, err := e.ApplyChaosExperiment(&experiments.PodFailure{
+		Mode:       "one",
 		LabelKey:   "app",
 		LabelValue: "plugin-node",
 		Duration:   10 * time.Second,
@@ -34,7 +34,16 @@ func main() {
 		log.Error().Msg(err.Error())
 		return
 	}
-	time.Sleep(10 * time.Second)
+	status, err := e.Chaos.WaitInjected(name, 30*time.Second)
+	if err != nil {
+		log.Error().Msg(err.Error())
+		return
+	}
+	log.Info().Strs("Pods", status.SelectedPods()).Msg("Chaos injected")
+	if _, err := e.Chaos.WaitRecovered(name, 30*time.Second); err != nil {
+		log.Error().Msg(err.Error())
+		return
+	}
 	if err := e.Chaos.StopAll(); err != nil {
 		log.Error().Msg(err.Error())
 		return